@@ -0,0 +1,324 @@
+// Package api extracts the public API of a C library from its headers and
+// reports source- and ABI-breaking changes between two versions of the API.
+package api
+
+import (
+	"strings"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+)
+
+// anonEnum is the name of the pseudo enum holding the enumerators of
+// anonymous enums.
+const anonEnum = "enum <anonymous>"
+
+// API is the public API of a library.
+type API struct {
+	// Functions, indexed by name.
+	Funcs map[string]*Func `json:"funcs"`
+	// Global variables, indexed by name.
+	Vars map[string]*Var `json:"vars"`
+	// Struct and union types, indexed by tag name (e.g. "struct foo").
+	Records map[string]*Record `json:"records"`
+	// Enum types, indexed by tag name (e.g. "enum foo").
+	Enums map[string]*Enum `json:"enums"`
+	// Type definitions, indexed by name.
+	Typedefs map[string]*Typedef `json:"typedefs"`
+	// Macros, indexed by name.
+	Macros map[string]*Macro `json:"macros"`
+}
+
+// Func is a function of the public API.
+type Func struct {
+	// Function name.
+	Name string `json:"name"`
+	// Source location of declaration.
+	Loc string `json:"loc"`
+	// Return type.
+	RetType TypeSpec `json:"ret_type"`
+	// Parameter types.
+	Params []TypeSpec `json:"params"`
+	// Variadic function.
+	Variadic bool `json:"variadic,omitempty"`
+}
+
+// Var is a global variable of the public API.
+type Var struct {
+	// Variable name.
+	Name string `json:"name"`
+	// Source location of declaration.
+	Loc string `json:"loc"`
+	// Variable type.
+	Type TypeSpec `json:"type"`
+}
+
+// Record is a struct or union type of the public API.
+type Record struct {
+	// Tag name (e.g. "struct foo").
+	Name string `json:"name"`
+	// Source location of declaration.
+	Loc string `json:"loc"`
+	// Opaque type; layout unknown.
+	Opaque bool `json:"opaque,omitempty"`
+	// Size in bytes.
+	Size int64 `json:"size"`
+	// Alignment in bytes.
+	Align int64 `json:"align"`
+	// Fields of the record.
+	Fields []*Field `json:"fields"`
+}
+
+// Field is a field of a struct or union type.
+type Field struct {
+	// Field name.
+	Name string `json:"name"`
+	// Field type.
+	Type TypeSpec `json:"type"`
+	// Offset in bits.
+	Offset int64 `json:"offset"`
+	// Bit width of bit-field; or -1 if not a bit-field.
+	BitWidth int32 `json:"bit_width"`
+}
+
+// Enum is an enum type of the public API.
+type Enum struct {
+	// Tag name (e.g. "enum foo").
+	Name string `json:"name"`
+	// Source location of declaration.
+	Loc string `json:"loc"`
+	// Underlying integer type.
+	IntType TypeSpec `json:"int_type"`
+	// Enumerators.
+	Consts []*EnumConst `json:"consts"`
+}
+
+// EnumConst is an enumerator of an enum type.
+type EnumConst struct {
+	// Enumerator name.
+	Name string `json:"name"`
+	// Enumerator value.
+	Value int64 `json:"value"`
+}
+
+// Typedef is a type definition of the public API.
+type Typedef struct {
+	// Type name.
+	Name string `json:"name"`
+	// Source location of declaration.
+	Loc string `json:"loc"`
+	// Underlying type.
+	Type TypeSpec `json:"type"`
+	// Layout of anonymous struct or union underlying the type definition; or
+	// nil if not present.
+	Record *Record `json:"record,omitempty"`
+}
+
+// Macro is a macro of the public API.
+type Macro struct {
+	// Macro name.
+	Name string `json:"name"`
+	// Source location of definition.
+	Loc string `json:"loc"`
+	// Function-like macro.
+	FuncLike bool `json:"func_like,omitempty"`
+	// Macro definition; space-separated list of tokens following the macro
+	// name.
+	Def string `json:"def"`
+}
+
+// TypeSpec specifies a type of the public API.
+type TypeSpec struct {
+	// Type as spelled in source.
+	Spelling string `json:"spelling"`
+	// Canonical type, used to identify ABI changes.
+	Canonical string `json:"canonical"`
+}
+
+// newTypeSpec returns a new type specifier based on the given Clang type.
+func newTypeSpec(t clang.Type) TypeSpec {
+	return TypeSpec{
+		Spelling:  t.Spelling(),
+		Canonical: t.CanonicalType().Spelling(),
+	}
+}
+
+// New returns a new empty API.
+func New() *API {
+	return &API{
+		Funcs:    make(map[string]*Func),
+		Vars:     make(map[string]*Var),
+		Records:  make(map[string]*Record),
+		Enums:    make(map[string]*Enum),
+		Typedefs: make(map[string]*Typedef),
+		Macros:   make(map[string]*Macro),
+	}
+}
+
+// Extract extracts the public API declared in the given parsed headers.
+// Macros are only extracted from headers parsed with
+// cc.PreprocessingRecordArgs.
+// Declarations located in system headers are ignored, as are functions and
+// variables without external linkage.
+func Extract(files ...*cc.File) *API {
+	a := New()
+	for _, file := range files {
+		for _, n := range file.Root.Children {
+			a.add(n)
+		}
+	}
+	return a
+}
+
+// add adds the given top-level declaration to the API.
+func (a *API) add(n *cc.Node) {
+	if n.Body.Location().IsInSystemHeader() || len(n.Loc.File) == 0 {
+		return
+	}
+	name := n.Body.Spelling()
+	loc := n.Loc.String()
+	switch n.Body.Kind() {
+	case clang.Cursor_FunctionDecl:
+		if n.Linkage() != cc.LinkageExternal {
+			return
+		}
+		t := n.Body.Type()
+		f := &Func{
+			Name:     name,
+			Loc:      loc,
+			RetType:  newTypeSpec(n.Body.ResultType()),
+			Variadic: t.IsFunctionTypeVariadic(),
+		}
+		for i := int32(0); i < t.NumArgTypes(); i++ {
+			f.Params = append(f.Params, newTypeSpec(t.ArgType(uint32(i))))
+		}
+		a.Funcs[name] = f
+	case clang.Cursor_VarDecl:
+		if n.Linkage() != cc.LinkageExternal {
+			return
+		}
+		a.Vars[name] = &Var{
+			Name: name,
+			Loc:  loc,
+			Type: newTypeSpec(n.Body.Type()),
+		}
+	case clang.Cursor_StructDecl, clang.Cursor_UnionDecl:
+		if len(name) == 0 {
+			// Anonymous records are recorded by their type definition.
+			return
+		}
+		r := newRecord(n.Body)
+		// Keep the complete definition if present.
+		if prev, ok := a.Records[r.Name]; ok && !prev.Opaque && r.Opaque {
+			return
+		}
+		a.Records[r.Name] = r
+	case clang.Cursor_EnumDecl:
+		anon := len(name) == 0
+		if anon {
+			// Enumerators of anonymous enums are merged into a single pseudo
+			// enum, as anonymous enums have no identity across versions.
+			name = anonEnum
+		} else {
+			name = "enum " + name
+		}
+		e, ok := a.Enums[name]
+		if !ok || !anon {
+			e = &Enum{
+				Name:    name,
+				Loc:     loc,
+				IntType: newTypeSpec(n.Body.EnumDeclIntegerType()),
+			}
+		}
+		for _, child := range n.Children {
+			if child.Body.Kind() != clang.Cursor_EnumConstantDecl {
+				continue
+			}
+			c := &EnumConst{
+				Name:  child.Body.Spelling(),
+				Value: child.Body.EnumConstantDeclValue(),
+			}
+			e.Consts = append(e.Consts, c)
+		}
+		if len(e.Consts) == 0 {
+			// Forward declaration.
+			if _, ok := a.Enums[name]; ok {
+				return
+			}
+		}
+		a.Enums[name] = e
+	case clang.Cursor_TypedefDecl:
+		underlying := n.Body.TypedefDeclUnderlyingType()
+		td := &Typedef{
+			Name: name,
+			Loc:  loc,
+			Type: newTypeSpec(underlying),
+		}
+		decl := underlying.CanonicalType().Declaration()
+		switch decl.Kind() {
+		case clang.Cursor_StructDecl, clang.Cursor_UnionDecl:
+			if len(decl.Spelling()) == 0 {
+				td.Record = newRecord(decl)
+				td.Record.Name = name
+			}
+		}
+		a.Typedefs[name] = td
+	case clang.Cursor_MacroDefinition:
+		if n.Body.IsMacroBuiltin() {
+			return
+		}
+		toks := n.Tokens()
+		if len(toks) > 0 {
+			// Drop macro name.
+			toks = toks[1:]
+		}
+		a.Macros[name] = &Macro{
+			Name:     name,
+			Loc:      loc,
+			FuncLike: n.Body.IsMacroFunctionLike(),
+			Def:      strings.Join(toks, " "),
+		}
+	}
+}
+
+// newRecord returns the layout of the given struct or union declaration.
+func newRecord(decl clang.Cursor) *Record {
+	prefix := "struct "
+	if decl.Kind() == clang.Cursor_UnionDecl {
+		prefix = "union "
+	}
+	loc := cc.NewLocation(decl.Location())
+	r := &Record{
+		Name: prefix + decl.Spelling(),
+		Loc:  loc.String(),
+	}
+	t := decl.Type()
+	// Negative sizes and alignments denote layout errors (e.g. incomplete
+	// types).
+	size := t.SizeOf()
+	if size < 0 {
+		r.Opaque = true
+		return r
+	}
+	r.Size = size
+	if align := t.AlignOf(); align >= 0 {
+		r.Align = align
+	}
+	decl.Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		if cursor.Kind() != clang.Cursor_FieldDecl {
+			return clang.ChildVisit_Continue
+		}
+		f := &Field{
+			Name:     cursor.Spelling(),
+			Type:     newTypeSpec(cursor.Type()),
+			Offset:   cursor.OffsetOfField(),
+			BitWidth: -1,
+		}
+		if cursor.IsBitField() {
+			f.BitWidth = cursor.FieldDeclBitWidth()
+		}
+		r.Fields = append(r.Fields, f)
+		return clang.ChildVisit_Continue
+	})
+	return r
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+// extract extracts the public API of the given header source.
+func extract(t *testing.T, src string) *API {
+	dir, err := ioutil.TempDir("", "api")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	srcPath := filepath.Join(dir, "lib.h")
+	if err := ioutil.WriteFile(srcPath, []byte(src), 0644); err != nil {
+		t.Fatalf("unable to write header; %+v", err)
+	}
+	args := append([]string{"-x", "c"}, cc.PreprocessingRecordArgs...)
+	file, err := cc.ParseFile(srcPath, args...)
+	if err != nil {
+		t.Fatalf("unable to parse header; %+v", err)
+	}
+	defer file.Close()
+	return Extract(file)
+}
+
+func TestExtract(t *testing.T) {
+	const src = `
+#define VERSION 2
+struct point { int x; char c; };
+struct opaque;
+typedef struct { long a; } anon_t;
+enum color { RED, GREEN = 5 };
+extern int counter;
+static int hidden;
+int draw(struct point *p, ...);
+static int helper(void) { return hidden; }
+`
+	a := extract(t, src)
+	if _, ok := a.Funcs["helper"]; ok {
+		t.Errorf("static function %q extracted", "helper")
+	}
+	if _, ok := a.Vars["hidden"]; ok {
+		t.Errorf("static variable %q extracted", "hidden")
+	}
+	f, ok := a.Funcs["draw"]
+	if !ok {
+		t.Fatalf("unable to locate function %q", "draw")
+	}
+	if !f.Variadic || len(f.Params) != 1 {
+		t.Errorf("%q: signature mismatch; expected variadic with 1 parameter, got variadic=%v with %d parameters", "draw", f.Variadic, len(f.Params))
+	}
+	if _, ok := a.Vars["counter"]; !ok {
+		t.Errorf("unable to locate variable %q", "counter")
+	}
+	golden := []struct {
+		name   string
+		opaque bool
+		size   int64
+		fields int
+	}{
+		{name: "struct point", size: 8, fields: 2},
+		{name: "struct opaque", opaque: true},
+	}
+	for _, g := range golden {
+		r, ok := a.Records[g.name]
+		if !ok {
+			t.Errorf("unable to locate record %q", g.name)
+			continue
+		}
+		if r.Opaque != g.opaque {
+			t.Errorf("%q: opaque mismatch; expected %v, got %v", g.name, g.opaque, r.Opaque)
+		}
+		if r.Size != g.size {
+			t.Errorf("%q: size mismatch; expected %d, got %d", g.name, g.size, r.Size)
+		}
+		if len(r.Fields) != g.fields {
+			t.Errorf("%q: number of fields mismatch; expected %d, got %d", g.name, g.fields, len(r.Fields))
+		}
+	}
+	if td, ok := a.Typedefs["anon_t"]; !ok || td.Record == nil {
+		t.Errorf("%q: layout of anonymous struct not recorded", "anon_t")
+	}
+	e, ok := a.Enums["enum color"]
+	if !ok || len(e.Consts) != 2 || e.Consts[1].Value != 5 {
+		t.Errorf("%q: enumerators mismatch", "enum color")
+	}
+	if m, ok := a.Macros["VERSION"]; !ok || m.Def != "2" {
+		t.Errorf("%q: macro definition mismatch", "VERSION")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	golden := []struct {
+		old, new string
+		// Expected changes, by symbol.
+		want map[string]ChangeKind
+		src  bool
+		abi  bool
+	}{
+		{
+			old:  "int f(int x);",
+			new:  "int f(int x);",
+			want: map[string]ChangeKind{},
+		},
+		{
+			old:  "int f(int x);",
+			new:  "int f(long x);",
+			want: map[string]ChangeKind{"f": Changed},
+			src:  true,
+			abi:  true,
+		},
+		{
+			old:  "typedef int myint; int f(int x);",
+			new:  "typedef int myint; int f(myint x);",
+			want: map[string]ChangeKind{"f": Changed},
+		},
+		{
+			old:  "int f(void); int g(void);",
+			new:  "int f(void);",
+			want: map[string]ChangeKind{"g": Removed},
+			src:  true,
+			abi:  true,
+		},
+		{
+			old:  "struct s { int a; };",
+			new:  "struct s { int a; int b; };",
+			want: map[string]ChangeKind{"struct s": Changed, "struct s.b": Added},
+			abi:  true,
+		},
+		{
+			old:  "enum e { A, B };",
+			new:  "enum e { A, C, B };",
+			want: map[string]ChangeKind{"B": Changed, "C": Added},
+			abi:  true,
+		},
+		{
+			old:  "#define N 1\n",
+			new:  "#define N 2\n",
+			want: map[string]ChangeKind{"N": Changed},
+		},
+		{
+			// The definition of N does not include the following line.
+			old:  "#define N 1\n#define M 1\n",
+			new:  "#define N 1\n#define M 2\n",
+			want: map[string]ChangeKind{"M": Changed},
+		},
+		{
+			old:  "#define F(x) (x)\n",
+			new:  "#define F 1\n",
+			want: map[string]ChangeKind{"F": Changed},
+			src:  true,
+		},
+	}
+	for i, g := range golden {
+		r := Compare(extract(t, g.old), extract(t, g.new))
+		got := make(map[string]ChangeKind)
+		for _, c := range r.Changes {
+			got[c.Symbol] = c.Kind
+		}
+		if len(got) != len(g.want) {
+			t.Errorf("test %d: changes mismatch; expected %v, got %v", i, g.want, r.Changes)
+			continue
+		}
+		for sym, kind := range g.want {
+			if got[sym] != kind {
+				t.Errorf("test %d: change of %q mismatch; expected %v, got %v", i, sym, kind, got[sym])
+			}
+		}
+		if r.SourceBreaking != g.src {
+			t.Errorf("test %d: source breaking mismatch; expected %v, got %v", i, g.src, r.SourceBreaking)
+		}
+		if r.ABIBreaking != g.abi {
+			t.Errorf("test %d: ABI breaking mismatch; expected %v, got %v", i, g.abi, r.ABIBreaking)
+		}
+	}
+}
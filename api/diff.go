@@ -0,0 +1,322 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangeKind specifies the kind of an API change.
+type ChangeKind string
+
+// API change kinds.
+const (
+	// Symbol added to the API.
+	Added ChangeKind = "added"
+	// Symbol removed from the API.
+	Removed ChangeKind = "removed"
+	// Symbol changed in the API.
+	Changed ChangeKind = "changed"
+)
+
+// Change is a change between two versions of an API.
+type Change struct {
+	// Kind of change.
+	Kind ChangeKind `json:"kind"`
+	// Affected symbol (e.g. "foo", "struct foo", "struct foo.bar").
+	Symbol string `json:"symbol"`
+	// Description of the change.
+	Desc string `json:"desc"`
+	// Source location of the symbol in the new version; or the old version if
+	// removed.
+	Loc string `json:"loc"`
+	// Source-breaking change; code using the old API no longer compiles.
+	SourceBreaking bool `json:"source_breaking"`
+	// ABI-breaking change; binaries built against the old API no longer work.
+	ABIBreaking bool `json:"abi_breaking"`
+}
+
+// String returns a string representation of the API change.
+func (c *Change) String() string {
+	var breaks []string
+	if c.SourceBreaking {
+		breaks = append(breaks, "source")
+	}
+	if c.ABIBreaking {
+		breaks = append(breaks, "ABI")
+	}
+	s := fmt.Sprintf("%s: %s %s: %s", c.Loc, c.Kind, c.Symbol, c.Desc)
+	if len(breaks) > 0 {
+		s += fmt.Sprintf(" (breaks %s)", strings.Join(breaks, ", "))
+	}
+	return s
+}
+
+// Report is the result of comparing two versions of an API.
+type Report struct {
+	// API changes, sorted by symbol.
+	Changes []*Change `json:"changes"`
+	// At least one change is source-breaking.
+	SourceBreaking bool `json:"source_breaking"`
+	// At least one change is ABI-breaking.
+	ABIBreaking bool `json:"abi_breaking"`
+}
+
+// Compare reports the changes between the old and new versions of an API.
+func Compare(old, new *API) *Report {
+	d := &differ{}
+	d.funcs(old.Funcs, new.Funcs)
+	d.vars(old.Vars, new.Vars)
+	d.records(old.Records, new.Records)
+	d.enums(old.Enums, new.Enums)
+	d.typedefs(old.Typedefs, new.Typedefs)
+	d.macros(old.Macros, new.Macros)
+	sort.Slice(d.changes, func(i, j int) bool {
+		if d.changes[i].Symbol != d.changes[j].Symbol {
+			return d.changes[i].Symbol < d.changes[j].Symbol
+		}
+		return d.changes[i].Desc < d.changes[j].Desc
+	})
+	r := &Report{Changes: d.changes}
+	for _, c := range r.Changes {
+		r.SourceBreaking = r.SourceBreaking || c.SourceBreaking
+		r.ABIBreaking = r.ABIBreaking || c.ABIBreaking
+	}
+	return r
+}
+
+// differ records changes between two versions of an API.
+type differ struct {
+	// API changes.
+	changes []*Change
+}
+
+// add records an API change.
+func (d *differ) add(kind ChangeKind, sym, loc string, src, abi bool, format string, args ...interface{}) {
+	c := &Change{
+		Kind:           kind,
+		Symbol:         sym,
+		Desc:           fmt.Sprintf(format, args...),
+		Loc:            loc,
+		SourceBreaking: src,
+		ABIBreaking:    abi,
+	}
+	d.changes = append(d.changes, c)
+}
+
+// typ records a change of the given type, if any.
+func (d *differ) typ(sym, loc, what string, old, new TypeSpec) {
+	switch {
+	case old.Canonical != new.Canonical:
+		d.add(Changed, sym, loc, true, true, "%s changed from %q to %q", what, old.Spelling, new.Spelling)
+	case old.Spelling != new.Spelling:
+		// Same canonical type; e.g. a new type definition for the same type.
+		d.add(Changed, sym, loc, false, false, "%s respelled from %q to %q", what, old.Spelling, new.Spelling)
+	}
+}
+
+// funcs records changes between the old and new functions.
+func (d *differ) funcs(old, new map[string]*Func) {
+	for name, o := range old {
+		n, ok := new[name]
+		if !ok {
+			d.add(Removed, name, o.Loc, true, true, "function removed")
+			continue
+		}
+		d.typ(name, n.Loc, "return type", o.RetType, n.RetType)
+		if len(o.Params) != len(n.Params) {
+			d.add(Changed, name, n.Loc, true, true, "number of parameters changed from %d to %d", len(o.Params), len(n.Params))
+		} else {
+			for i := range o.Params {
+				d.typ(name, n.Loc, fmt.Sprintf("type of parameter %d", i+1), o.Params[i], n.Params[i])
+			}
+		}
+		if o.Variadic != n.Variadic {
+			d.add(Changed, name, n.Loc, true, true, "variadic changed from %t to %t", o.Variadic, n.Variadic)
+		}
+	}
+	for name, n := range new {
+		if _, ok := old[name]; !ok {
+			d.add(Added, name, n.Loc, false, false, "function added")
+		}
+	}
+}
+
+// vars records changes between the old and new global variables.
+func (d *differ) vars(old, new map[string]*Var) {
+	for name, o := range old {
+		n, ok := new[name]
+		if !ok {
+			d.add(Removed, name, o.Loc, true, true, "variable removed")
+			continue
+		}
+		d.typ(name, n.Loc, "type", o.Type, n.Type)
+	}
+	for name, n := range new {
+		if _, ok := old[name]; !ok {
+			d.add(Added, name, n.Loc, false, false, "variable added")
+		}
+	}
+}
+
+// records records changes between the old and new struct and union types.
+func (d *differ) records(old, new map[string]*Record) {
+	for name, o := range old {
+		n, ok := new[name]
+		if !ok {
+			d.add(Removed, name, o.Loc, true, true, "type removed")
+			continue
+		}
+		d.record(o, n)
+	}
+	for name, n := range new {
+		if _, ok := old[name]; !ok {
+			d.add(Added, name, n.Loc, false, false, "type added")
+		}
+	}
+}
+
+// record records changes between the old and new layout of a struct or union
+// type.
+func (d *differ) record(o, n *Record) {
+	name := n.Name
+	switch {
+	case o.Opaque && n.Opaque:
+		return
+	case o.Opaque:
+		d.add(Changed, name, n.Loc, false, false, "opaque type made complete")
+		return
+	case n.Opaque:
+		d.add(Changed, name, n.Loc, true, true, "complete type made opaque")
+		return
+	}
+	if o.Size != n.Size {
+		d.add(Changed, name, n.Loc, false, true, "size changed from %d to %d bytes", o.Size, n.Size)
+	}
+	if o.Align != n.Align {
+		d.add(Changed, name, n.Loc, false, true, "alignment changed from %d to %d bytes", o.Align, n.Align)
+	}
+	newFields := make(map[string]*Field)
+	for _, f := range n.Fields {
+		newFields[f.Name] = f
+	}
+	oldFields := make(map[string]*Field)
+	for _, of := range o.Fields {
+		oldFields[of.Name] = of
+		sym := name + "." + of.Name
+		nf, ok := newFields[of.Name]
+		if !ok {
+			d.add(Removed, sym, n.Loc, true, true, "field removed")
+			continue
+		}
+		d.typ(sym, n.Loc, "type", of.Type, nf.Type)
+		if of.Offset != nf.Offset {
+			d.add(Changed, sym, n.Loc, false, true, "offset changed from %d to %d bits", of.Offset, nf.Offset)
+		}
+		if of.BitWidth != nf.BitWidth {
+			d.add(Changed, sym, n.Loc, false, true, "bit width changed from %d to %d", of.BitWidth, nf.BitWidth)
+		}
+	}
+	for _, nf := range n.Fields {
+		if _, ok := oldFields[nf.Name]; !ok {
+			// Size and offset changes are reported separately.
+			d.add(Added, name+"."+nf.Name, n.Loc, false, false, "field added")
+		}
+	}
+}
+
+// enums records changes between the old and new enum types.
+func (d *differ) enums(old, new map[string]*Enum) {
+	for name, o := range old {
+		n, ok := new[name]
+		if !ok {
+			if name != anonEnum {
+				d.add(Removed, name, o.Loc, true, false, "type removed")
+			}
+			n = &Enum{Name: name, Loc: o.Loc, IntType: o.IntType}
+		}
+		d.enum(o, n)
+	}
+	for name, n := range new {
+		if _, ok := old[name]; !ok {
+			if name != anonEnum {
+				d.add(Added, name, n.Loc, false, false, "type added")
+			}
+			d.enum(&Enum{Name: name, IntType: n.IntType}, n)
+		}
+	}
+}
+
+// enum records changes between the old and new enumerators of an enum type.
+func (d *differ) enum(o, n *Enum) {
+	d.typ(n.Name, n.Loc, "underlying type", o.IntType, n.IntType)
+	newConsts := make(map[string]*EnumConst)
+	for _, c := range n.Consts {
+		newConsts[c.Name] = c
+	}
+	oldConsts := make(map[string]*EnumConst)
+	for _, oc := range o.Consts {
+		oldConsts[oc.Name] = oc
+		nc, ok := newConsts[oc.Name]
+		if !ok {
+			d.add(Removed, oc.Name, o.Loc, true, false, "enumerator of %s removed", n.Name)
+			continue
+		}
+		if oc.Value != nc.Value {
+			d.add(Changed, oc.Name, n.Loc, false, true, "value changed from %d to %d", oc.Value, nc.Value)
+		}
+	}
+	for _, nc := range n.Consts {
+		if _, ok := oldConsts[nc.Name]; !ok {
+			d.add(Added, nc.Name, n.Loc, false, false, "enumerator of %s added", n.Name)
+		}
+	}
+}
+
+// typedefs records changes between the old and new type definitions.
+func (d *differ) typedefs(old, new map[string]*Typedef) {
+	for name, o := range old {
+		n, ok := new[name]
+		if !ok {
+			d.add(Removed, name, o.Loc, true, false, "type definition removed")
+			continue
+		}
+		switch {
+		case o.Record != nil && n.Record != nil:
+			d.record(o.Record, n.Record)
+		case o.Record != nil || n.Record != nil:
+			d.add(Changed, name, n.Loc, true, true, "type changed from %q to %q", o.Type.Spelling, n.Type.Spelling)
+		default:
+			d.typ(name, n.Loc, "type", o.Type, n.Type)
+		}
+	}
+	for name, n := range new {
+		if _, ok := old[name]; !ok {
+			d.add(Added, name, n.Loc, false, false, "type definition added")
+		}
+	}
+}
+
+// macros records changes between the old and new macros.
+func (d *differ) macros(old, new map[string]*Macro) {
+	for name, o := range old {
+		n, ok := new[name]
+		if !ok {
+			d.add(Removed, name, o.Loc, true, false, "macro removed")
+			continue
+		}
+		switch {
+		case o.FuncLike != n.FuncLike:
+			d.add(Changed, name, n.Loc, true, false, "function-like changed from %t to %t", o.FuncLike, n.FuncLike)
+		case o.Def != n.Def:
+			// Macro values (e.g. version numbers) commonly change between
+			// releases, and are thus not considered breaking.
+			d.add(Changed, name, n.Loc, false, false, "definition changed from %q to %q", o.Def, n.Def)
+		}
+	}
+	for name, n := range new {
+		if _, ok := old[name]; !ok {
+			d.add(Added, name, n.Loc, false, false, "macro added")
+		}
+	}
+}
@@ -0,0 +1,508 @@
+// Package astdiff computes structural differences between two ASTs.
+//
+// The matching algorithm is based on GumTree (Falleri et al., Fine-grained and
+// Accurate Source Code Differencing, 2014). Isomorphic subtrees are first
+// matched top-down, declarations are then matched by name, and the remaining
+// nodes are matched bottom-up based on the proportion of matched descendants.
+// An edit script of insert, delete, update and move actions is derived from the
+// resulting mapping.
+package astdiff
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+)
+
+// Op specifies the kind of an edit action.
+type Op uint8
+
+// Edit actions.
+const (
+	// Node inserted in new tree.
+	Insert Op = iota + 1
+	// Node deleted from old tree.
+	Delete
+	// Value (e.g. name or literal) of node updated.
+	Update
+	// Node moved to a different parent or position.
+	Move
+)
+
+// String returns a string representation of the edit action kind.
+func (op Op) String() string {
+	switch op {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	case Update:
+		return "update"
+	case Move:
+		return "move"
+	}
+	return fmt.Sprintf("Op(%d)", uint8(op))
+}
+
+// Action is an action of an edit script.
+//
+// Insert and delete actions are only reported for the root of inserted or
+// deleted subtrees.
+type Action struct {
+	// Kind of edit action.
+	Op Op
+	// Node of old tree; nil for insert actions.
+	Old *cc.Node
+	// Node of new tree; nil for delete actions.
+	New *cc.Node
+	// Parent node in new tree of inserted or moved node.
+	Parent *cc.Node
+	// Child index in parent node of inserted or moved node.
+	Pos int
+}
+
+// String returns a string representation of the edit action.
+func (a *Action) String() string {
+	switch a.Op {
+	case Insert:
+		return fmt.Sprintf("%s: insert %s into %s at position %d", a.New.Loc, describe(a.New), describe(a.Parent), a.Pos)
+	case Delete:
+		return fmt.Sprintf("%s: delete %s", a.Old.Loc, describe(a.Old))
+	case Update:
+		return fmt.Sprintf("%s: update %s to %q", a.New.Loc, describe(a.Old), value(a.New))
+	case Move:
+		return fmt.Sprintf("%s: move %s (from %s) into %s at position %d", a.New.Loc, describe(a.New), a.Old.Loc, describe(a.Parent), a.Pos)
+	}
+	return fmt.Sprintf("%s: %v", a.Op, a.New)
+}
+
+// describe returns a short description of the given node.
+func describe(n *cc.Node) string {
+	kind := n.Body.Kind().String()
+	if v := value(n); len(v) > 0 {
+		return fmt.Sprintf("%s %q", kind, v)
+	}
+	return kind
+}
+
+// Diff returns the edit script transforming the old AST into the new AST.
+func Diff(old, new *cc.Node) []*Action {
+	src := newTree(old, nil)
+	dst := newTree(new, nil)
+	m := newMapping()
+	m.link(src, dst)
+	matchTopDown(m, src, dst)
+	matchNames(m, src, dst)
+	matchBottomUp(m, src)
+	return editScript(m, src, dst)
+}
+
+// tree is a node of an AST annotated with matching information.
+type tree struct {
+	// AST node.
+	n *cc.Node
+	// Parent tree node; or nil if root.
+	parent *tree
+	// Child tree nodes.
+	children []*tree
+	// Node label; node kind.
+	label string
+	// Node value; e.g. name of declaration or literal value.
+	value string
+	// Structural hash of subtree, including labels and values.
+	hash uint64
+	// Height of subtree; 1 for leaves.
+	height int
+	// Number of nodes in subtree.
+	size int
+}
+
+// newTree returns a new tree node based on the given AST node.
+func newTree(n *cc.Node, parent *tree) *tree {
+	t := &tree{
+		n:      n,
+		parent: parent,
+		label:  n.Body.Kind().String(),
+		value:  value(n),
+		height: 1,
+		size:   1,
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%s\x00", t.label, t.value)
+	for _, child := range n.Children {
+		c := newTree(child, t)
+		t.children = append(t.children, c)
+		if c.height+1 > t.height {
+			t.height = c.height + 1
+		}
+		t.size += c.size
+		fmt.Fprintf(h, "%x\x00", c.hash)
+	}
+	t.hash = h.Sum64()
+	return t
+}
+
+// value returns the value of the given AST node; e.g. the name of declarations
+// and references, the operator of operator nodes or the spelling of literals.
+func value(n *cc.Node) string {
+	kind := n.Body.Kind()
+	switch {
+	case kind.IsDeclaration(), kind.IsReference(), kind == clang.Cursor_DeclRefExpr, kind == clang.Cursor_MemberRefExpr, kind == clang.Cursor_CallExpr:
+		return n.Body.Spelling()
+	}
+	switch kind {
+	case clang.Cursor_BinaryOperator, clang.Cursor_CompoundAssignOperator, clang.Cursor_UnaryOperator:
+		return n.Operator()
+	case clang.Cursor_IntegerLiteral, clang.Cursor_FloatingLiteral, clang.Cursor_CharacterLiteral, clang.Cursor_StringLiteral:
+		return strings.Join(n.Tokens(), " ")
+	}
+	return ""
+}
+
+// walk invokes f for each node of the given tree in pre-order.
+func (t *tree) walk(f func(t *tree)) {
+	f(t)
+	for _, c := range t.children {
+		c.walk(f)
+	}
+}
+
+// isDescendantOf reports whether the tree node is a descendant of the given
+// ancestor.
+func (t *tree) isDescendantOf(ancestor *tree) bool {
+	for p := t.parent; p != nil; p = p.parent {
+		if p == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// mapping is a bidirectional mapping between nodes of the old and new tree.
+type mapping struct {
+	// Maps from old to new tree nodes.
+	srcToDst map[*tree]*tree
+	// Maps from new to old tree nodes.
+	dstToSrc map[*tree]*tree
+}
+
+// newMapping returns a new empty mapping.
+func newMapping() *mapping {
+	return &mapping{
+		srcToDst: make(map[*tree]*tree),
+		dstToSrc: make(map[*tree]*tree),
+	}
+}
+
+// link links the given nodes of the old and new tree.
+func (m *mapping) link(src, dst *tree) {
+	m.srcToDst[src] = dst
+	m.dstToSrc[dst] = src
+}
+
+// linkSubtrees links the nodes of the given isomorphic subtrees.
+func (m *mapping) linkSubtrees(src, dst *tree) {
+	m.link(src, dst)
+	for i := range src.children {
+		m.linkSubtrees(src.children[i], dst.children[i])
+	}
+}
+
+// minHeight is the minimum height of isomorphic subtrees matched top-down;
+// smaller subtrees are matched bottom-up.
+const minHeight = 2
+
+// minDice is the minimum proportion of common matched descendants for nodes to
+// be matched bottom-up.
+const minDice = 0.5
+
+// matchTopDown matches the largest isomorphic subtrees of the old and new tree.
+func matchTopDown(m *mapping, src, dst *tree) {
+	// Index candidate subtrees of the old tree by hash.
+	candidates := make(map[uint64][]*tree)
+	src.walk(func(t *tree) {
+		if t.height >= minHeight {
+			candidates[t.hash] = append(candidates[t.hash], t)
+		}
+	})
+	// Visit subtrees of the new tree from largest to smallest.
+	var dsts []*tree
+	dst.walk(func(t *tree) {
+		if t.height >= minHeight {
+			dsts = append(dsts, t)
+		}
+	})
+	sort.SliceStable(dsts, func(i, j int) bool {
+		return dsts[i].height > dsts[j].height
+	})
+	for _, d := range dsts {
+		if _, ok := m.dstToSrc[d]; ok {
+			continue
+		}
+		var best *tree
+		for _, s := range candidates[d.hash] {
+			if _, ok := m.srcToDst[s]; ok {
+				continue
+			}
+			if best == nil {
+				best = s
+			}
+			// Prefer candidates with matching parents.
+			if s.parent != nil && d.parent != nil && m.srcToDst[s.parent] == d.parent {
+				best = s
+				break
+			}
+		}
+		if best != nil {
+			m.linkSubtrees(best, d)
+		}
+	}
+}
+
+// matchNames matches unmatched declarations of the old and new tree with
+// identical kind and name, if unique in both trees.
+func matchNames(m *mapping, src, dst *tree) {
+	declKey := func(t *tree) string {
+		if !t.n.Body.Kind().IsDeclaration() || len(t.value) == 0 {
+			return ""
+		}
+		key := t.label + "\x00" + t.value
+		// Local declarations are only unique within their enclosing
+		// declaration.
+		for p := t.parent; p != nil; p = p.parent {
+			if p.n.Body.Kind().IsDeclaration() {
+				key = p.label + "\x00" + p.value + "\x00" + key
+				break
+			}
+		}
+		return key
+	}
+	index := func(root *tree) map[string][]*tree {
+		decls := make(map[string][]*tree)
+		root.walk(func(t *tree) {
+			if key := declKey(t); len(key) > 0 {
+				decls[key] = append(decls[key], t)
+			}
+		})
+		return decls
+	}
+	srcDecls, dstDecls := index(src), index(dst)
+	dst.walk(func(d *tree) {
+		if _, ok := m.dstToSrc[d]; ok {
+			return
+		}
+		key := declKey(d)
+		if len(key) == 0 || len(srcDecls[key]) != 1 || len(dstDecls[key]) != 1 {
+			return
+		}
+		s := srcDecls[key][0]
+		if _, ok := m.srcToDst[s]; !ok {
+			m.link(s, d)
+		}
+	})
+}
+
+// matchBottomUp matches the remaining nodes of the old tree in post-order,
+// based on the proportion of common matched descendants.
+func matchBottomUp(m *mapping, src *tree) {
+	var visit func(s *tree)
+	visit = func(s *tree) {
+		for _, c := range s.children {
+			visit(c)
+		}
+		d, ok := m.srcToDst[s]
+		if !ok && len(s.children) > 0 {
+			d = bestCandidate(m, s)
+			if d != nil {
+				m.link(s, d)
+			}
+		}
+		if d != nil {
+			recoverChildren(m, s, d)
+		}
+	}
+	visit(src)
+}
+
+// bestCandidate returns the unmatched node of the new tree with the highest
+// proportion of common matched descendants of the given old tree node; or nil
+// if none exceeds the threshold.
+func bestCandidate(m *mapping, s *tree) *tree {
+	var best *tree
+	bestDice := 0.0
+	seen := make(map[*tree]bool)
+	s.walk(func(t *tree) {
+		if t == s {
+			return
+		}
+		d, ok := m.srcToDst[t]
+		if !ok {
+			return
+		}
+		for p := d.parent; p != nil; p = p.parent {
+			if seen[p] {
+				continue
+			}
+			seen[p] = true
+			if p.label != s.label {
+				continue
+			}
+			if _, ok := m.dstToSrc[p]; ok {
+				continue
+			}
+			if dice := diceCoefficient(m, s, p); dice > bestDice {
+				best, bestDice = p, dice
+			}
+		}
+	})
+	if bestDice < minDice {
+		return nil
+	}
+	return best
+}
+
+// diceCoefficient returns the proportion of common matched descendants of the
+// given old and new tree nodes.
+func diceCoefficient(m *mapping, s, d *tree) float64 {
+	common := 0
+	s.walk(func(t *tree) {
+		if t == s {
+			return
+		}
+		if dt, ok := m.srcToDst[t]; ok && dt.isDescendantOf(d) {
+			common++
+		}
+	})
+	return 2 * float64(common) / float64(s.size-1+d.size-1)
+}
+
+// recoverChildren matches unmatched children of the given matched nodes with
+// identical labels, preferring identical values.
+func recoverChildren(m *mapping, s, d *tree) {
+	for _, sameValue := range []bool{true, false} {
+		for _, sc := range s.children {
+			if _, ok := m.srcToDst[sc]; ok {
+				continue
+			}
+			for _, dc := range d.children {
+				if _, ok := m.dstToSrc[dc]; ok {
+					continue
+				}
+				if sc.label != dc.label || (sameValue && sc.value != dc.value) {
+					continue
+				}
+				if sc.hash == dc.hash {
+					m.linkSubtrees(sc, dc)
+				} else {
+					m.link(sc, dc)
+					recoverChildren(m, sc, dc)
+				}
+				break
+			}
+		}
+	}
+}
+
+// editScript returns the edit script derived from the given mapping between
+// the old and new tree.
+func editScript(m *mapping, src, dst *tree) []*Action {
+	var actions []*Action
+	dst.walk(func(d *tree) {
+		s, ok := m.dstToSrc[d]
+		if !ok {
+			if d.parent != nil {
+				if _, ok := m.dstToSrc[d.parent]; ok {
+					actions = append(actions, &Action{Op: Insert, New: d.n, Parent: d.parent.n, Pos: index(d)})
+				}
+			}
+			return
+		}
+		if s.value != d.value {
+			actions = append(actions, &Action{Op: Update, Old: s.n, New: d.n})
+		}
+		if d.parent != nil && m.srcToDst[s.parent] != d.parent {
+			actions = append(actions, &Action{Op: Move, Old: s.n, New: d.n, Parent: d.parent.n, Pos: index(d)})
+		}
+		// Detect reordered children.
+		for _, dc := range misaligned(m, s, d) {
+			actions = append(actions, &Action{Op: Move, Old: m.dstToSrc[dc].n, New: dc.n, Parent: d.n, Pos: index(dc)})
+		}
+	})
+	src.walk(func(s *tree) {
+		if _, ok := m.srcToDst[s]; ok || s.parent == nil {
+			return
+		}
+		if _, ok := m.srcToDst[s.parent]; ok {
+			actions = append(actions, &Action{Op: Delete, Old: s.n})
+		}
+	})
+	return actions
+}
+
+// misaligned returns the children of the new tree node which are matched to
+// children of the old tree node but not part of the longest common subsequence
+// of matched children; i.e. children moved within the same parent.
+func misaligned(m *mapping, s, d *tree) []*tree {
+	var xs, ys []*tree
+	for _, sc := range s.children {
+		if dc, ok := m.srcToDst[sc]; ok && dc.parent == d {
+			xs = append(xs, sc)
+		}
+	}
+	for _, dc := range d.children {
+		if sc, ok := m.dstToSrc[dc]; ok && sc.parent == s {
+			ys = append(ys, dc)
+		}
+	}
+	// Longest common subsequence of matched children.
+	lcs := make([][]int, len(xs)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(ys)+1)
+	}
+	for i := len(xs) - 1; i >= 0; i-- {
+		for j := len(ys) - 1; j >= 0; j-- {
+			switch {
+			case m.srcToDst[xs[i]] == ys[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	aligned := make(map[*tree]bool)
+	for i, j := 0, 0; i < len(xs) && j < len(ys); {
+		switch {
+		case m.srcToDst[xs[i]] == ys[j]:
+			aligned[ys[j]] = true
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	var moved []*tree
+	for _, dc := range ys {
+		if !aligned[dc] {
+			moved = append(moved, dc)
+		}
+	}
+	return moved
+}
+
+// index returns the child index of the given tree node within its parent.
+func index(t *tree) int {
+	for i, c := range t.parent.children {
+		if c == t {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,93 @@
+package astdiff
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+// parse parses the given source, written to a.c of a temporary directory.
+func parse(t *testing.T, src string) *cc.File {
+	dir, err := ioutil.TempDir("", "astdiff")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	srcPath := filepath.Join(dir, "a.c")
+	if err := ioutil.WriteFile(srcPath, []byte(src), 0644); err != nil {
+		t.Fatalf("unable to write source file; %+v", err)
+	}
+	file, err := cc.ParseFile(srcPath)
+	if err != nil {
+		t.Fatalf("unable to parse %q; %+v", src, err)
+	}
+	return file
+}
+
+func TestDiff(t *testing.T) {
+	golden := []struct {
+		old, new string
+		// Expected number of actions, by kind.
+		want map[Op]int
+	}{
+		// Identical sources.
+		{
+			old:  "int f(void) { return 1; }",
+			new:  "int f(void) { return 1; }",
+			want: map[Op]int{},
+		},
+		// Updated literal.
+		{
+			old:  "int f(void) { return 1; }",
+			new:  "int f(void) { return 2; }",
+			want: map[Op]int{Update: 1},
+		},
+		// Updated operator.
+		{
+			old:  "int f(int x) { return x + 1; }",
+			new:  "int f(int x) { return x - 1; }",
+			want: map[Op]int{Update: 1},
+		},
+		// Inserted function.
+		{
+			old:  "int f(void) { return 1; }",
+			new:  "int f(void) { return 1; }\nvoid g(void) {}",
+			want: map[Op]int{Insert: 1},
+		},
+		// Deleted statement.
+		{
+			old:  "void f(int x) { x++; x--; }",
+			new:  "void f(int x) { x++; }",
+			want: map[Op]int{Delete: 1},
+		},
+		// Reordered functions.
+		{
+			old:  "int f(void) { return 1; }\nint g(void) { return 2; }",
+			new:  "int g(void) { return 2; }\nint f(void) { return 1; }",
+			want: map[Op]int{Move: 1},
+		},
+	}
+	for i, g := range golden {
+		old := parse(t, g.old)
+		new := parse(t, g.new)
+		got := make(map[Op]int)
+		actions := Diff(old.Root, new.Root)
+		for _, a := range actions {
+			got[a.Op]++
+		}
+		if len(got) != len(g.want) {
+			t.Errorf("test %d: edit script mismatch; expected %v, got %v", i, g.want, actions)
+		} else {
+			for op, n := range g.want {
+				if got[op] != n {
+					t.Errorf("test %d: number of %v actions mismatch; expected %d, got %d (%v)", i, op, n, got[op], actions)
+				}
+			}
+		}
+		old.Close()
+		new.Close()
+	}
+}
@@ -0,0 +1,181 @@
+// Package callgraph implements call graphs of C and C++ programs.
+package callgraph
+
+import (
+	"sort"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+)
+
+// Graph is a call graph of a program.
+type Graph struct {
+	// Functions of the program, indexed by USR.
+	Funcs map[string]*Func
+}
+
+// Func is a function of a call graph.
+type Func struct {
+	// Function name.
+	Name string
+	// Unified Symbol Resolution of the function.
+	USR string
+	// Function definition; or nil if not defined in the program.
+	Def *cc.Node
+	// Path of the translation unit containing the function definition.
+	TU string
+	// Outgoing calls.
+	Callees []*Call
+	// Incoming calls.
+	Callers []*Call
+	// Call sites with unknown targets (e.g. calls through function pointers).
+	Indirect []*cc.Node
+}
+
+// Call is a call edge of a call graph.
+type Call struct {
+	// Calling function.
+	Caller *Func
+	// Called function.
+	Callee *Func
+	// Call expression.
+	Site *cc.Node
+}
+
+// New returns the call graph of the program consisting of the given
+// translation units.
+func New(files ...*cc.File) *Graph {
+	g := &Graph{
+		Funcs: make(map[string]*Func),
+	}
+	for _, file := range files {
+		tu := file.Root.Body.Spelling()
+		for _, n := range file.Root.Children {
+			g.addDecl(n, tu)
+		}
+	}
+	return g
+}
+
+// addDecl adds the given top-level declaration to the call graph.
+func (g *Graph) addDecl(n *cc.Node, tu string) {
+	switch n.Body.Kind() {
+	case clang.Cursor_FunctionDecl, clang.Cursor_CXXMethod:
+		f := g.Func(n.Body)
+		if !n.Body.IsCursorDefinition() || f.Def != nil {
+			// Declaration or definition already visited (e.g. inline function
+			// definition of header included by several translation units).
+			return
+		}
+		f.Def = n
+		f.TU = tu
+		cc.Walk(n, func(n *cc.Node) {
+			if n.Body.Kind() != clang.Cursor_CallExpr {
+				return
+			}
+			callee := n.Body.Referenced()
+			switch callee.Kind() {
+			case clang.Cursor_FunctionDecl, clang.Cursor_CXXMethod:
+				g.AddCall(f, g.Func(callee), n)
+			default:
+				f.Indirect = append(f.Indirect, n)
+			}
+		})
+	case clang.Cursor_Namespace, clang.Cursor_StructDecl, clang.Cursor_ClassDecl:
+		for _, child := range n.Children {
+			g.addDecl(child, tu)
+		}
+	}
+}
+
+// Func returns the call graph function of the given function declaration,
+// creating a new function if not already present.
+func (g *Graph) Func(decl clang.Cursor) *Func {
+	usr := decl.USR()
+	if f, ok := g.Funcs[usr]; ok {
+		return f
+	}
+	f := &Func{
+		Name: decl.Spelling(),
+		USR:  usr,
+	}
+	g.Funcs[usr] = f
+	return f
+}
+
+// AddCall adds a call edge from caller to callee at the given call site.
+func (g *Graph) AddCall(caller, callee *Func, site *cc.Node) {
+	call := &Call{
+		Caller: caller,
+		Callee: callee,
+		Site:   site,
+	}
+	caller.Callees = append(caller.Callees, call)
+	callee.Callers = append(callee.Callers, call)
+}
+
+// Sorted returns the functions of the call graph sorted by name.
+func (g *Graph) Sorted() []*Func {
+	var fs []*Func
+	for _, f := range g.Funcs {
+		fs = append(fs, f)
+	}
+	sortFuncs(fs)
+	return fs
+}
+
+// Callers returns the transitive callers of the given functions, sorted by
+// name. The given functions are not included unless called recursively.
+func Callers(fs ...*Func) []*Func {
+	return reach(fs, func(f *Func) []*Func {
+		var callers []*Func
+		for _, call := range f.Callers {
+			callers = append(callers, call.Caller)
+		}
+		return callers
+	})
+}
+
+// Callees returns the transitive callees of the given functions, sorted by
+// name. The given functions are not included unless called recursively.
+func Callees(fs ...*Func) []*Func {
+	return reach(fs, func(f *Func) []*Func {
+		var callees []*Func
+		for _, call := range f.Callees {
+			callees = append(callees, call.Callee)
+		}
+		return callees
+	})
+}
+
+// reach returns the functions transitively reachable from the given functions
+// through the given successor function, sorted by name.
+func reach(fs []*Func, succs func(f *Func) []*Func) []*Func {
+	visited := make(map[*Func]bool)
+	var reached []*Func
+	queue := append([]*Func(nil), fs...)
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		for _, succ := range succs(f) {
+			if visited[succ] {
+				continue
+			}
+			visited[succ] = true
+			reached = append(reached, succ)
+			queue = append(queue, succ)
+		}
+	}
+	sortFuncs(reached)
+	return reached
+}
+
+// sortFuncs sorts the given functions by name.
+func sortFuncs(fs []*Func) {
+	sort.Slice(fs, func(i, j int) bool {
+		if fs[i].Name != fs[j].Name {
+			return fs[i].Name < fs[j].Name
+		}
+		return fs[i].USR < fs[j].USR
+	})
+}
@@ -0,0 +1,87 @@
+package callgraph
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+// names returns the comma-separated names of the given functions.
+func names(fs []*Func) string {
+	var ss []string
+	for _, f := range fs {
+		ss = append(ss, f.Name)
+	}
+	return strings.Join(ss, ",")
+}
+
+// parse parses the given source, written to a temporary file.
+func parse(t *testing.T, src string) *cc.File {
+	dir, err := ioutil.TempDir("", "callgraph")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "calls.c")
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatalf("unable to write source file; %+v", err)
+	}
+	file, err := cc.ParseFile(path)
+	if err != nil {
+		file.Close()
+		t.Fatalf("unable to parse source; %+v", err)
+	}
+	return file
+}
+
+func TestGraph(t *testing.T) {
+	const src = `
+int ext(int);
+static int leaf(int x) { return ext(x); }
+static int mid(int x) { return leaf(x) + leaf(x + 1); }
+int top(int (*fp)(int)) { return mid(1) + fp(2); }
+int top(int (*fp)(int));
+`
+	file := parse(t, src)
+	defer file.Close()
+	g := New(file)
+	golden := []struct {
+		name     string
+		defined  bool
+		callers  string
+		callees  string
+		indirect int
+	}{
+		{name: "ext", callers: "leaf,mid,top"},
+		{name: "leaf", defined: true, callers: "mid,top", callees: "ext"},
+		{name: "mid", defined: true, callers: "top", callees: "ext,leaf"},
+		{name: "top", defined: true, callees: "ext,leaf,mid", indirect: 1},
+	}
+	fs := g.Sorted()
+	if got := names(fs); got != "ext,leaf,mid,top" {
+		t.Fatalf("functions mismatch; expected %q, got %q", "ext,leaf,mid,top", got)
+	}
+	for i, want := range golden {
+		f := fs[i]
+		if got := f.Def != nil; got != want.defined {
+			t.Errorf("%q: defined mismatch; expected %v, got %v", want.name, want.defined, got)
+		}
+		if got := names(Callers(f)); got != want.callers {
+			t.Errorf("%q: callers mismatch; expected %q, got %q", want.name, want.callers, got)
+		}
+		if got := names(Callees(f)); got != want.callees {
+			t.Errorf("%q: callees mismatch; expected %q, got %q", want.name, want.callees, got)
+		}
+		if got := len(f.Indirect); got != want.indirect {
+			t.Errorf("%q: number of indirect calls mismatch; expected %d, got %d", want.name, want.indirect, got)
+		}
+	}
+	// Two call sites of leaf in mid.
+	if got := len(g.Funcs[fs[1].USR].Callers); got != 2 {
+		t.Errorf("%q: number of call sites mismatch; expected 2, got %d", "leaf", got)
+	}
+}
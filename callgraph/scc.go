@@ -0,0 +1,82 @@
+package callgraph
+
+import "sort"
+
+// SCCs returns the strongly connected components of the call graph, in reverse
+// topological order (callees before callers). The functions of each component
+// are sorted by name.
+func (g *Graph) SCCs() [][]*Func {
+	// Tarjan's strongly connected components algorithm.
+	var (
+		sccs    [][]*Func
+		stack   []*Func
+		index   = make(map[*Func]int)
+		lowlink = make(map[*Func]int)
+		onStack = make(map[*Func]bool)
+	)
+	var strongConnect func(f *Func)
+	strongConnect = func(f *Func) {
+		index[f] = len(index)
+		lowlink[f] = index[f]
+		stack = append(stack, f)
+		onStack[f] = true
+		for _, call := range f.Callees {
+			callee := call.Callee
+			if _, ok := index[callee]; !ok {
+				strongConnect(callee)
+				if lowlink[callee] < lowlink[f] {
+					lowlink[f] = lowlink[callee]
+				}
+			} else if onStack[callee] && index[callee] < lowlink[f] {
+				lowlink[f] = index[callee]
+			}
+		}
+		if lowlink[f] != index[f] {
+			return
+		}
+		var scc []*Func
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			scc = append(scc, top)
+			if top == f {
+				break
+			}
+		}
+		sortFuncs(scc)
+		sccs = append(sccs, scc)
+	}
+	for _, f := range g.Sorted() {
+		if _, ok := index[f]; !ok {
+			strongConnect(f)
+		}
+	}
+	return sccs
+}
+
+// Recursive returns the strongly connected components of the call graph which
+// contain cycles; i.e. groups of directly or mutually recursive functions,
+// sorted by the name of their first function.
+func (g *Graph) Recursive() [][]*Func {
+	var rec [][]*Func
+	for _, scc := range g.SCCs() {
+		if len(scc) > 1 || calls(scc[0], scc[0]) {
+			rec = append(rec, scc)
+		}
+	}
+	sort.Slice(rec, func(i, j int) bool {
+		return rec[i][0].Name < rec[j][0].Name
+	})
+	return rec
+}
+
+// calls reports whether the caller directly calls the callee.
+func calls(caller, callee *Func) bool {
+	for _, call := range caller.Callees {
+		if call.Callee == callee {
+			return true
+		}
+	}
+	return false
+}
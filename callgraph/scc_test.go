@@ -0,0 +1,45 @@
+package callgraph
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSCCs(t *testing.T) {
+	const src = `
+void a(void);
+void b(void);
+void c(void);
+void d(void);
+void a(void) { b(); }
+void b(void) { a(); c(); }
+void c(void) { c(); d(); }
+void d(void) {}
+void e(void) { d(); }
+`
+	file := parse(t, src)
+	defer file.Close()
+	g := New(file)
+	// sccNames returns the names of the given components.
+	sccNames := func(sccs [][]*Func) string {
+		var ss []string
+		for _, scc := range sccs {
+			ss = append(ss, names(scc))
+		}
+		return strings.Join(ss, "; ")
+	}
+	golden := []struct {
+		desc string
+		got  [][]*Func
+		want string
+	}{
+		// Callees before callers.
+		{desc: "strongly connected components", got: g.SCCs(), want: "d; c; a,b; e"},
+		{desc: "recursive components", got: g.Recursive(), want: "a,b; c"},
+	}
+	for _, want := range golden {
+		if got := sccNames(want.got); got != want.want {
+			t.Errorf("%s mismatch; expected %q, got %q", want.desc, want.want, got)
+		}
+	}
+}
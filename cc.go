@@ -28,13 +28,52 @@ func (file *File) Close() {
 	file.idx.Dispose()
 }
 
+// Errors returns the error diagnostics of the parsed source file, prefixed by
+// source location.
+func (file *File) Errors() []string {
+	var errs []string
+	for _, d := range file.tu.Diagnostics() {
+		switch d.Severity() {
+		case clang.Diagnostic_Error, clang.Diagnostic_Fatal:
+			errs = append(errs, fmt.Sprintf("%s: %s", NewLocation(d.Location()), d.Spelling()))
+		}
+	}
+	return errs
+}
+
+// PreprocessingRecordArgs are Clang arguments enabling the detailed
+// preprocessing record, which exposes macro definitions, macro expansions and
+// inclusion directives as nodes of the AST.
+var PreprocessingRecordArgs = []string{"-Xclang", "-detailed-preprocessing-record"}
+
 // ParseFile parses the given source file, returning the root node of the AST.
 // Note, a (partial) AST is returned even when an error is encountered.
 func ParseFile(srcPath string, clangArgs ...string) (*File, error) {
+	return parse(srcPath, clangArgs, nil)
+}
+
+// ParseSource parses the given source code as if it were the contents of the
+// given source file, returning the root node of the AST. The source file need
+// not exist. Note, a (partial) AST is returned even when a parse error is
+// encountered.
+func ParseSource(srcPath, src string, clangArgs ...string) (*File, error) {
+	uf := newUnsavedFile(srcPath, src)
+	// The contents of unsaved files are copied by Clang during parsing.
+	defer uf.dispose()
+	u, err := uf.clangUnsavedFile()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return parse(srcPath, clangArgs, []clang.UnsavedFile{u})
+}
+
+// parse parses the given source file, using the given unsaved files in place
+// of the corresponding files on disk.
+func parse(srcPath string, clangArgs []string, unsaved []clang.UnsavedFile) (*File, error) {
 	// Create index.
 	idx := clang.NewIndex(0, 1)
 	// Create translation unit.
-	tu := idx.ParseTranslationUnit(srcPath, clangArgs, nil, 0)
+	tu := idx.ParseTranslationUnit(srcPath, clangArgs, unsaved, 0)
 	// Record errors.
 	diagnostics := tu.Diagnostics()
 	var err error
@@ -95,6 +134,31 @@ type Node struct {
 	Children []*Node
 }
 
+// Extent returns the start and end location of the source range of the node.
+func (n *Node) Extent() (start, end Location) {
+	r := n.Body.Extent()
+	return NewLocation(r.Start()), NewLocation(r.End())
+}
+
+// Tokens returns the spelling of the tokens covered by the source range of the
+// node.
+func (n *Node) Tokens() []string {
+	tu := n.Body.TranslationUnit()
+	r := n.Body.Extent()
+	toks := tu.Tokenize(r)
+	defer tu.DisposeTokens(toks)
+	_, _, _, end := r.End().FileLocation()
+	var ss []string
+	for _, tok := range toks {
+		// Clang 3.9 includes the token following the source range.
+		if _, _, _, off := tu.TokenLocation(tok).FileLocation(); off >= end {
+			break
+		}
+		ss = append(ss, tu.TokenSpelling(tok))
+	}
+	return ss
+}
+
 // Location denotes a location in a source file.
 type Location struct {
 	// Source file.
@@ -0,0 +1,393 @@
+// Package cfg constructs control flow graphs of C and C++ function bodies.
+//
+// Basic blocks hold the statement-level nodes of a function body in evaluation
+// order: expression statements, declaration statements, return, break,
+// continue and goto statements, and the conditions of control structures.
+// Short-circuit and conditional operators are not split into separate blocks.
+//
+// Blocks ending in a two-way branch record the branch condition, with the true
+// successor first and the false successor second. Paths ending in a call to a
+// function which does not return (e.g. exit) have no successors.
+package cfg
+
+import (
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+)
+
+// NoReturn holds the names of functions which do not return to their caller.
+var NoReturn = map[string]bool{
+	"abort":         true,
+	"exit":          true,
+	"_exit":         true,
+	"_Exit":         true,
+	"quick_exit":    true,
+	"longjmp":       true,
+	"siglongjmp":    true,
+	"pthread_exit":  true,
+	"__assert_fail": true,
+}
+
+// Graph is the control flow graph of a function.
+type Graph struct {
+	// Function definition.
+	Func *cc.Node
+	// Entry block.
+	Entry *Block
+	// Exit block; empty, and reached by return statements and the end of the
+	// function body.
+	Exit *Block
+	// Basic blocks of the function, including the entry and exit blocks.
+	Blocks []*Block
+}
+
+// Block is a basic block of a control flow graph.
+type Block struct {
+	// Index of the block in the graph.
+	Index int
+	// Statement-level nodes of the block, in evaluation order.
+	Nodes []*cc.Node
+	// Branch condition of two-way branches; or nil. The true successor is
+	// Succs[0] and the false successor is Succs[1].
+	Cond *cc.Node
+	// Successor blocks.
+	Succs []*Block
+	// Predecessor blocks.
+	Preds []*Block
+}
+
+// New returns the control flow graph of the given function definition; or nil
+// if the function has no body.
+func New(fn *cc.Node) *Graph {
+	var body *cc.Node
+	for _, child := range fn.Children {
+		if child.Body.Kind() == clang.Cursor_CompoundStmt {
+			body = child
+		}
+	}
+	if body == nil {
+		return nil
+	}
+	g := &Graph{Func: fn}
+	b := &builder{g: g, labels: make(map[string]*Block)}
+	g.Entry = b.newBlock()
+	g.Exit = b.newBlock()
+	b.cur = g.Entry
+	b.stmt(body)
+	b.jump(g.Exit)
+	for _, p := range b.gotos {
+		if target, ok := b.labels[p.label]; ok {
+			addEdge(p.from, target)
+		}
+	}
+	return g
+}
+
+// builder tracks the state of control flow graph construction.
+type builder struct {
+	// Control flow graph under construction.
+	g *Graph
+	// Current block; or nil if unreachable.
+	cur *Block
+	// Target block of break statements.
+	breakTarget *Block
+	// Target block of continue statements.
+	continueTarget *Block
+	// Header block of the innermost switch statement.
+	switchBlock *Block
+	// Innermost switch statement has a default label.
+	hasDefault bool
+	// Blocks of labels, keyed by label location.
+	labels map[string]*Block
+	// Pending goto statements.
+	gotos []*pendingGoto
+}
+
+// pendingGoto is a goto statement whose target label may not yet be located.
+type pendingGoto struct {
+	// Block of the goto statement.
+	from *Block
+	// Location of the target label.
+	label string
+}
+
+// newBlock appends a new basic block to the graph.
+func (b *builder) newBlock() *Block {
+	block := &Block{Index: len(b.g.Blocks)}
+	b.g.Blocks = append(b.g.Blocks, block)
+	return block
+}
+
+// add appends the given node to the current block. A new block without
+// predecessors is started if the current block is unreachable.
+func (b *builder) add(n *cc.Node) {
+	if b.cur == nil {
+		b.cur = b.newBlock()
+	}
+	b.cur.Nodes = append(b.cur.Nodes, n)
+}
+
+// jump adds an edge from the current block to the given block, after which the
+// current block is unreachable.
+func (b *builder) jump(to *Block) {
+	if b.cur != nil {
+		addEdge(b.cur, to)
+	}
+	b.cur = nil
+}
+
+// branch ends the current block in a two-way branch on the given condition.
+func (b *builder) branch(cond *cc.Node, t, f *Block) {
+	b.add(cond)
+	b.cur.Cond = cond
+	addEdge(b.cur, t)
+	addEdge(b.cur, f)
+	b.cur = nil
+}
+
+// addEdge adds an edge between the given blocks.
+func addEdge(from, to *Block) {
+	from.Succs = append(from.Succs, to)
+	to.Preds = append(to.Preds, from)
+}
+
+// stmt adds the control flow of the given statement to the graph.
+func (b *builder) stmt(n *cc.Node) {
+	switch n.Body.Kind() {
+	case clang.Cursor_CompoundStmt:
+		for _, child := range n.Children {
+			b.stmt(child)
+		}
+	case clang.Cursor_IfStmt:
+		// cond, then [, else]
+		if len(n.Children) < 2 {
+			b.add(n)
+			return
+		}
+		then, after := b.newBlock(), b.newBlock()
+		els := after
+		if len(n.Children) > 2 {
+			els = b.newBlock()
+		}
+		b.branch(n.Children[0], then, els)
+		b.cur = then
+		b.stmt(n.Children[1])
+		b.jump(after)
+		if len(n.Children) > 2 {
+			b.cur = els
+			b.stmt(n.Children[2])
+			b.jump(after)
+		}
+		b.cur = after
+	case clang.Cursor_WhileStmt:
+		// cond, body
+		if len(n.Children) < 2 {
+			b.add(n)
+			return
+		}
+		header, body, after := b.newBlock(), b.newBlock(), b.newBlock()
+		b.jump(header)
+		b.cur = header
+		b.branch(n.Children[0], body, after)
+		b.cur = body
+		b.loopBody(n.Children[1], after, header)
+		b.jump(header)
+		b.cur = after
+	case clang.Cursor_DoStmt:
+		// body, cond
+		if len(n.Children) < 2 {
+			b.add(n)
+			return
+		}
+		body, cond, after := b.newBlock(), b.newBlock(), b.newBlock()
+		b.jump(body)
+		b.cur = body
+		b.loopBody(n.Children[0], after, cond)
+		b.jump(cond)
+		b.cur = cond
+		b.branch(n.Children[1], body, after)
+		b.cur = after
+	case clang.Cursor_ForStmt:
+		init, cond, inc, body := ForParts(n)
+		if init != nil {
+			b.stmt(init)
+		}
+		header, bodyBlock, incBlock, after := b.newBlock(), b.newBlock(), b.newBlock(), b.newBlock()
+		b.jump(header)
+		b.cur = header
+		if cond != nil {
+			b.branch(cond, bodyBlock, after)
+		} else {
+			b.jump(bodyBlock)
+		}
+		b.cur = bodyBlock
+		if body != nil {
+			b.loopBody(body, after, incBlock)
+		}
+		b.jump(incBlock)
+		b.cur = incBlock
+		if inc != nil {
+			b.add(inc)
+		}
+		b.jump(header)
+		b.cur = after
+	case clang.Cursor_SwitchStmt:
+		// cond, body
+		if len(n.Children) < 2 {
+			b.add(n)
+			return
+		}
+		b.add(n.Children[0])
+		header, after := b.cur, b.newBlock()
+		savedSwitch, savedDefault, savedBreak := b.switchBlock, b.hasDefault, b.breakTarget
+		b.switchBlock, b.hasDefault, b.breakTarget = header, false, after
+		b.cur = nil
+		b.stmt(n.Children[1])
+		b.jump(after)
+		if !b.hasDefault {
+			addEdge(header, after)
+		}
+		b.switchBlock, b.hasDefault, b.breakTarget = savedSwitch, savedDefault, savedBreak
+		b.cur = after
+	case clang.Cursor_CaseStmt, clang.Cursor_DefaultStmt:
+		// CaseStmt: value, stmt; DefaultStmt: stmt.
+		label := b.newBlock()
+		b.jump(label)
+		if b.switchBlock != nil {
+			addEdge(b.switchBlock, label)
+		}
+		if n.Body.Kind() == clang.Cursor_DefaultStmt {
+			b.hasDefault = true
+		}
+		b.cur = label
+		sub := 1
+		if n.Body.Kind() == clang.Cursor_DefaultStmt {
+			sub = 0
+		}
+		if sub < len(n.Children) {
+			b.stmt(n.Children[sub])
+		}
+	case clang.Cursor_BreakStmt:
+		b.add(n)
+		if b.breakTarget != nil {
+			b.jump(b.breakTarget)
+		}
+	case clang.Cursor_ContinueStmt:
+		b.add(n)
+		if b.continueTarget != nil {
+			b.jump(b.continueTarget)
+		}
+	case clang.Cursor_ReturnStmt:
+		b.add(n)
+		b.jump(b.g.Exit)
+	case clang.Cursor_GotoStmt:
+		b.add(n)
+		for _, child := range n.Children {
+			if child.Body.Kind() == clang.Cursor_LabelRef {
+				label := cc.NewLocation(child.Body.Referenced().Location()).String()
+				b.gotos = append(b.gotos, &pendingGoto{from: b.cur, label: label})
+			}
+		}
+		b.cur = nil
+	case clang.Cursor_IndirectGotoStmt:
+		// Unknown target.
+		b.add(n)
+		b.cur = nil
+	case clang.Cursor_LabelStmt:
+		label := b.newBlock()
+		b.jump(label)
+		b.labels[n.Loc.String()] = label
+		b.cur = label
+		for _, child := range n.Children {
+			b.stmt(child)
+		}
+	case clang.Cursor_NullStmt:
+		// nothing to do.
+	default:
+		b.add(n)
+		if callsNoReturn(n) {
+			b.cur = nil
+		}
+	}
+}
+
+// loopBody adds the control flow of the given loop body to the graph, with the
+// given targets of break and continue statements.
+func (b *builder) loopBody(body *cc.Node, breakTarget, continueTarget *Block) {
+	savedBreak, savedContinue := b.breakTarget, b.continueTarget
+	b.breakTarget, b.continueTarget = breakTarget, continueTarget
+	b.stmt(body)
+	b.breakTarget, b.continueTarget = savedBreak, savedContinue
+}
+
+// ForParts returns the init statement, condition, increment and body of the
+// given for statement. Omitted parts are nil.
+//
+// Clang omits the null parts of for statements from the children of the node,
+// so the parts are located based on the semicolons of the for header.
+func ForParts(n *cc.Node) (init, cond, inc, body *cc.Node) {
+	if len(n.Children) == 0 {
+		return nil, nil, nil, nil
+	}
+	tu := n.Body.TranslationUnit()
+	var semis []uint32
+	var rparen uint32
+	depth := 0
+	toks := tu.Tokenize(n.Body.Extent())
+	defer tu.DisposeTokens(toks)
+loop:
+	for _, tok := range toks {
+		switch tu.TokenSpelling(tok) {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				rparen = offset(tu.TokenLocation(tok))
+				break loop
+			}
+		case ";":
+			if depth == 1 {
+				semis = append(semis, offset(tu.TokenLocation(tok)))
+			}
+		}
+	}
+	if len(semis) < 2 {
+		// Range-based for statement; only the body is considered.
+		return nil, nil, nil, n.Children[len(n.Children)-1]
+	}
+	for _, child := range n.Children {
+		off := offset(child.Body.Location())
+		switch {
+		case off > rparen:
+			body = child
+		case off > semis[1]:
+			inc = child
+		case off > semis[0]:
+			cond = child
+		default:
+			// The semicolon of declaration statements is part of the init
+			// statement.
+			init = child
+		}
+	}
+	return init, cond, inc, body
+}
+
+// offset returns the file offset of the given source location.
+func offset(loc clang.SourceLocation) uint32 {
+	_, _, _, off := loc.FileLocation()
+	return off
+}
+
+// callsNoReturn reports whether the given statement calls a function which
+// does not return.
+func callsNoReturn(n *cc.Node) bool {
+	found := false
+	cc.Walk(n, func(n *cc.Node) {
+		if n.Body.Kind() == clang.Cursor_CallExpr && NoReturn[n.Body.Referenced().Spelling()] {
+			found = true
+		}
+	})
+	return found
+}
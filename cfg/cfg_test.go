@@ -0,0 +1,132 @@
+package cfg
+
+import (
+	"testing"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+)
+
+// parseFunc parses the given source, returning the definition of function f.
+func parseFunc(t *testing.T, src string) (*cc.File, *cc.Node) {
+	file, err := cc.ParseSource("cfg.c", src)
+	if err != nil {
+		t.Fatalf("unable to parse %q; %+v", src, err)
+	}
+	for _, n := range file.Root.Children {
+		if n.Body.Kind() == clang.Cursor_FunctionDecl && n.Body.Spelling() == "f" && n.Body.IsCursorDefinition() {
+			return file, n
+		}
+	}
+	file.Close()
+	t.Fatalf("unable to locate definition of f in %q", src)
+	return nil, nil
+}
+
+// reachable reports whether the exit block of the given graph is reachable
+// from the entry block.
+func reachable(g *Graph) bool {
+	visited := make(map[*Block]bool)
+	var visit func(b *Block) bool
+	visit = func(b *Block) bool {
+		if b == g.Exit {
+			return true
+		}
+		if visited[b] {
+			return false
+		}
+		visited[b] = true
+		for _, succ := range b.Succs {
+			if visit(succ) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(g.Entry)
+}
+
+func TestNew(t *testing.T) {
+	golden := []struct {
+		src string
+		// Number of blocks with a branch condition.
+		conds int
+		// Exit block reachable from entry block.
+		reachable bool
+	}{
+		{src: "int f(int x) { return x; }", conds: 0, reachable: true},
+		{src: "int f(int x) { if (x) return 1; return 0; }", conds: 1, reachable: true},
+		{src: "void f(int x) { while (x) x--; }", conds: 1, reachable: true},
+		{src: "void f(int x) { do x--; while (x); }", conds: 1, reachable: true},
+		{src: "void f(int x) { for (int i = 0; i < x; i++) if (i == 3) break; }", conds: 2, reachable: true},
+		{src: "void f(int x) { switch (x) { case 1: break; default: x++; } }", conds: 0, reachable: true},
+		{src: "void f(int x) { l: if (x) goto l; }", conds: 1, reachable: true},
+		{src: "void exit(int); void f(void) { exit(1); }", conds: 0, reachable: false},
+		{src: "void f(void) { for (;;) {} }", conds: 0, reachable: false},
+	}
+	for _, g := range golden {
+		file, fn := parseFunc(t, g.src)
+		graph := New(fn)
+		conds := 0
+		for _, b := range graph.Blocks {
+			if b.Cond != nil {
+				conds++
+				if len(b.Succs) != 2 {
+					t.Errorf("%q: number of successors of conditional block mismatch; expected 2, got %d", g.src, len(b.Succs))
+				}
+			}
+			for _, succ := range b.Succs {
+				found := false
+				for _, pred := range succ.Preds {
+					if pred == b {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("%q: block %d missing from predecessors of block %d", g.src, b.Index, succ.Index)
+				}
+			}
+		}
+		if conds != g.conds {
+			t.Errorf("%q: number of conditional blocks mismatch; expected %d, got %d", g.src, g.conds, conds)
+		}
+		if got := reachable(graph); got != g.reachable {
+			t.Errorf("%q: exit reachability mismatch; expected %v, got %v", g.src, g.reachable, got)
+		}
+		file.Close()
+	}
+}
+
+func TestForParts(t *testing.T) {
+	golden := []struct {
+		src string
+		// Kinds of init, cond, inc and body; or 0 if omitted.
+		init, cond, inc, body clang.CursorKind
+	}{
+		{src: "void f(int n) { for (int i = 0; i < n; i++) {} }", init: clang.Cursor_DeclStmt, cond: clang.Cursor_BinaryOperator, inc: clang.Cursor_UnaryOperator, body: clang.Cursor_CompoundStmt},
+		{src: "void f(int n) { for (; n; ) n--; }", cond: clang.Cursor_UnexposedExpr, body: clang.Cursor_UnaryOperator},
+		{src: "void f(int n) { for (n = 0; ; n++) {} }", init: clang.Cursor_BinaryOperator, inc: clang.Cursor_UnaryOperator, body: clang.Cursor_CompoundStmt},
+		{src: "void f(void) { for (;;) {} }", body: clang.Cursor_CompoundStmt},
+	}
+	// kind returns the kind of the given node; or 0 if nil.
+	kind := func(n *cc.Node) clang.CursorKind {
+		if n == nil {
+			return 0
+		}
+		return n.Body.Kind()
+	}
+	for _, g := range golden {
+		file, fn := parseFunc(t, g.src)
+		var forStmt *cc.Node
+		cc.Walk(fn, func(n *cc.Node) {
+			if n.Body.Kind() == clang.Cursor_ForStmt {
+				forStmt = n
+			}
+		})
+		init, cond, inc, body := ForParts(forStmt)
+		if kind(init) != g.init || kind(cond) != g.cond || kind(inc) != g.inc || kind(body) != g.body {
+			t.Errorf("%q: parts mismatch; expected %v, %v, %v, %v, got %v, %v, %v, %v", g.src, g.init, g.cond, g.inc, g.body, kind(init), kind(cond), kind(inc), kind(body))
+		}
+		file.Close()
+	}
+}
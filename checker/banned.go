@@ -0,0 +1,127 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+)
+
+// BannedConfig is the configuration of the banned and deprecated API checker.
+type BannedConfig struct {
+	// Banned functions.
+	Functions []*APIRule `json:"functions"`
+	// Project-deprecated symbols (functions, variables, types, enum constants
+	// and macros).
+	Deprecated []*APIRule `json:"deprecated"`
+	// Report uses of symbols declared with the deprecated attribute.
+	DeprecatedAttr bool `json:"deprecated_attr"`
+}
+
+// APIRule is a rule of the banned and deprecated API checker.
+type APIRule struct {
+	// Name of the banned function or deprecated symbol.
+	Name string `json:"name"`
+	// Suggested replacement; may be empty.
+	Replacement string `json:"replacement"`
+	// Reason the function or symbol is banned or deprecated; may be empty.
+	Reason string `json:"reason"`
+}
+
+// DefaultBannedConfig is the default configuration of the banned and deprecated
+// API checker.
+var DefaultBannedConfig = &BannedConfig{
+	Functions: []*APIRule{
+		{Name: "gets", Replacement: "fgets", Reason: "no bounds checking"},
+		{Name: "strcpy", Replacement: "strlcpy or snprintf", Reason: "no bounds checking"},
+		{Name: "strcat", Replacement: "strlcat or snprintf", Reason: "no bounds checking"},
+		{Name: "sprintf", Replacement: "snprintf", Reason: "no bounds checking"},
+		{Name: "vsprintf", Replacement: "vsnprintf", Reason: "no bounds checking"},
+		{Name: "strtok", Replacement: "strtok_r", Reason: "not reentrant"},
+		{Name: "tmpnam", Replacement: "mkstemp", Reason: "race condition"},
+		{Name: "mktemp", Replacement: "mkstemp", Reason: "race condition"},
+		{Name: "atoi", Replacement: "strtol", Reason: "no error detection"},
+		{Name: "atol", Replacement: "strtol", Reason: "no error detection"},
+	},
+	DeprecatedAttr: true,
+}
+
+// Banned is a checker which reports uses of banned functions and deprecated
+// symbols.
+type Banned struct {
+	// Checker configuration; or nil to use DefaultBannedConfig.
+	Config *BannedConfig
+}
+
+// Name returns the name of the checker.
+func (c *Banned) Name() string {
+	return "banned"
+}
+
+// Check checks the given translation units, returning the diagnostics.
+//
+// Uses of banned functions and deprecated macros are only detected within
+// macro expansions if the translation units were parsed with
+// cc.PreprocessingRecordArgs.
+func (c *Banned) Check(files []*cc.File) []*Diagnostic {
+	config := c.Config
+	if config == nil {
+		config = DefaultBannedConfig
+	}
+	banned := make(map[string]*APIRule)
+	for _, rule := range config.Functions {
+		banned[rule.Name] = rule
+	}
+	deprecated := make(map[string]*APIRule)
+	for _, rule := range config.Deprecated {
+		deprecated[rule.Name] = rule
+	}
+	var diags []*Diagnostic
+	report := func(n *cc.Node, rule, desc string, r *APIRule) {
+		d := &Diagnostic{
+			Loc:      n.Loc,
+			Rule:     rule,
+			Severity: SeverityWarning,
+			Message:  desc,
+		}
+		if r != nil {
+			if len(r.Reason) > 0 {
+				d.Message += fmt.Sprintf(" (%s)", r.Reason)
+			}
+			if len(r.Replacement) > 0 {
+				d.Suggestion = fmt.Sprintf("use %s instead", r.Replacement)
+			}
+		}
+		diags = append(diags, d)
+	}
+	walkUser(files, func(n *cc.Node) {
+		var name string
+		// Macro expansions have no referenced declaration.
+		ref := clang.NewNullCursor()
+		switch n.Body.Kind() {
+		case clang.Cursor_DeclRefExpr, clang.Cursor_MemberRefExpr, clang.Cursor_TypeRef:
+			ref = n.Body.Referenced()
+			if ref.IsNull() {
+				return
+			}
+			name = ref.Spelling()
+		case clang.Cursor_MacroExpansion:
+			name = n.Body.Spelling()
+		default:
+			return
+		}
+		isFunc := n.Body.Kind() == clang.Cursor_MacroExpansion || ref.Kind() == clang.Cursor_FunctionDecl
+		if rule, ok := banned[name]; ok && isFunc {
+			report(n, "banned-function", fmt.Sprintf("use of banned function %s", name), rule)
+			return
+		}
+		if rule, ok := deprecated[name]; ok {
+			report(n, "deprecated-symbol", fmt.Sprintf("use of deprecated symbol %s", name), rule)
+			return
+		}
+		if config.DeprecatedAttr && !ref.IsNull() && ref.Availability() == clang.Availability_Deprecated {
+			report(n, "deprecated-symbol", fmt.Sprintf("use of deprecated symbol %s", name), nil)
+		}
+	})
+	return diags
+}
@@ -0,0 +1,56 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+func TestBanned(t *testing.T) {
+	const src = `
+char *strcpy(char *dst, const char *src);
+int atoi(const char *s);
+__attribute__((deprecated)) int old(void);
+int legacy(void);
+#define gets(s) strcpy(s, "")
+#define ZERO 0
+int strcpy_count;
+void f(char *d, const char *s) {
+	strcpy(d, s);
+	atoi(s);
+	old();
+	legacy();
+	gets(d);
+	strcpy_count = ZERO;
+}
+`
+	golden := []struct {
+		config *BannedConfig
+		want   []string
+	}{
+		{
+			config: nil,
+			want: []string{
+				"10 banned-function",
+				"11 banned-function",
+				"12 deprecated-symbol",
+				"14 banned-function",
+				"14 banned-function",
+			},
+		},
+		{
+			config: &BannedConfig{
+				Functions:  []*APIRule{{Name: "atoi"}},
+				Deprecated: []*APIRule{{Name: "legacy"}, {Name: "ZERO"}},
+			},
+			want: []string{
+				"11 banned-function",
+				"13 deprecated-symbol",
+				"15 deprecated-symbol",
+			},
+		},
+	}
+	for _, g := range golden {
+		checkDiags(t, &Banned{Config: g.config}, src, g.want, cc.PreprocessingRecordArgs...)
+	}
+}
@@ -0,0 +1,303 @@
+// Package checker implements static checkers of C and C++ source code.
+//
+// Checkers report diagnostics on the ASTs of translation units. Diagnostics
+// may be suppressed in source code by a comment containing "cc:ignore" on the
+// same line as the diagnostic or on the line before it. The comment may list
+// the rules to suppress, otherwise all rules are suppressed.
+//
+//	p = strcpy(dst, src); // cc:ignore(banned-function)
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/rewrite"
+	"github.com/mewspring/cc/taint"
+	"github.com/pkg/errors"
+)
+
+// Checker is a static checker of translation units.
+type Checker interface {
+	// Name returns the name of the checker.
+	Name() string
+	// Check checks the given translation units, returning the diagnostics.
+	Check(files []*cc.File) []*Diagnostic
+}
+
+// Fixer is a checker which can fix the issues it reports.
+type Fixer interface {
+	Checker
+	// Fix returns a rewriter fixing the issues of the given translation units.
+	Fix(files []*cc.File) (*rewrite.Rewriter, error)
+}
+
+// Severity is the severity of a diagnostic.
+type Severity uint8
+
+// Diagnostic severities.
+const (
+	SeverityNote Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+// String returns the string representation of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityNote:
+		return "note"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", uint8(s))
+}
+
+// MarshalText encodes the severity as text.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes the severity from text.
+func (s *Severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "note":
+		*s = SeverityNote
+	case "warning":
+		*s = SeverityWarning
+	case "error":
+		*s = SeverityError
+	default:
+		return errors.Errorf("invalid severity %q", text)
+	}
+	return nil
+}
+
+// Diagnostic is a diagnostic reported by a checker.
+type Diagnostic struct {
+	// Source location of the diagnostic.
+	Loc cc.Location
+	// Rule of the diagnostic (e.g. "banned-function").
+	Rule string
+	// Severity of the diagnostic.
+	Severity Severity
+	// Diagnostic message.
+	Message string
+	// Suggested fix; may be empty.
+	Suggestion string
+	// Related locations (e.g. the path to a leak), in order; may be empty.
+	Trace []*Note
+}
+
+// Note is a source location related to a diagnostic.
+type Note struct {
+	// Source location of the note.
+	Loc cc.Location
+	// Note message.
+	Message string
+}
+
+// String returns a string representation of the diagnostic.
+func (d *Diagnostic) String() string {
+	s := fmt.Sprintf("%s: %s: %s [%s]", d.Loc, d.Severity, d.Message, d.Rule)
+	if len(d.Suggestion) > 0 {
+		s += fmt.Sprintf("\n\tsuggestion: %s", d.Suggestion)
+	}
+	for _, note := range d.Trace {
+		s += fmt.Sprintf("\n\t%s: note: %s", note.Loc, note.Message)
+	}
+	return s
+}
+
+// Config is the configuration of the checkers. Checkers with a nil
+// configuration use their default configuration.
+type Config struct {
+	// Banned and deprecated API checker configuration.
+	Banned *BannedConfig `json:"banned"`
+	// Format string checker configuration.
+	Format *FormatConfig `json:"format"`
+	// Resource pairing checker configuration.
+	Resource *ResourceConfig `json:"resource"`
+	// Unchecked return value checker configuration.
+	Unchecked *UncheckedConfig `json:"unchecked"`
+	// Naming convention checker configuration.
+	Naming *NamingConfig `json:"naming"`
+	// MISRA C checker configuration.
+	Misra *MisraConfig `json:"misra"`
+	// Taint analysis configuration.
+	Taint *taint.Config `json:"taint"`
+	// Integer conversion and overflow checker configuration.
+	Integer *IntegerConfig `json:"integer"`
+}
+
+// LoadConfig loads the given JSON checker configuration.
+func LoadConfig(path string) (*Config, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	config := &Config{}
+	if err := json.Unmarshal(buf, config); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse checker configuration %q", path)
+	}
+	return config, nil
+}
+
+// Checkers returns the checkers of the given names, as configured by the
+// configuration. All checkers are returned if no names are given.
+func (config *Config) Checkers(names ...string) ([]Checker, error) {
+	all := []Checker{
+		&Banned{Config: config.Banned},
+		&Format{Config: config.Format},
+		&Resource{Config: config.Resource},
+		&Unchecked{Config: config.Unchecked},
+		&Switch{},
+		&ScopeHygiene{},
+		&Naming{Config: config.Naming},
+		&Misra{Config: config.Misra},
+		&Taint{Config: config.Taint},
+		&Integer{Config: config.Integer},
+	}
+	if len(names) == 0 {
+		return all, nil
+	}
+	var checkers []Checker
+	for _, name := range names {
+		found := false
+		for _, c := range all {
+			if c.Name() == name {
+				checkers = append(checkers, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("unknown checker %q", name)
+		}
+	}
+	return checkers, nil
+}
+
+// Run runs the given checkers on the given translation units, returning the
+// diagnostics sorted by location. Duplicate diagnostics (e.g. of headers
+// included by several translation units) and suppressed diagnostics are
+// omitted.
+func Run(files []*cc.File, checkers ...Checker) []*Diagnostic {
+	var diags []*Diagnostic
+	seen := make(map[string]bool)
+	sup := newSuppressions()
+	for _, c := range checkers {
+		for _, d := range c.Check(files) {
+			key := fmt.Sprintf("%s %s %s", d.Loc, d.Rule, d.Message)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if sup.suppressed(d) {
+				continue
+			}
+			diags = append(diags, d)
+		}
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Loc, diags[j].Loc
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return diags
+}
+
+// suppressions tracks the suppression comments of source files.
+type suppressions struct {
+	// Source lines of source files; nil if unreadable.
+	lines map[string][]string
+}
+
+// newSuppressions returns a new suppression comment tracker.
+func newSuppressions() *suppressions {
+	return &suppressions{lines: make(map[string][]string)}
+}
+
+// suppressed reports whether the given diagnostic is suppressed by a comment on
+// the same line or on the line before.
+func (sup *suppressions) suppressed(d *Diagnostic) bool {
+	lines, ok := sup.lines[d.Loc.File]
+	if !ok {
+		if buf, err := ioutil.ReadFile(d.Loc.File); err == nil {
+			lines = strings.Split(string(buf), "\n")
+		}
+		sup.lines[d.Loc.File] = lines
+	}
+	for _, line := range []uint32{d.Loc.Line, d.Loc.Line - 1} {
+		if line < 1 || int(line) > len(lines) {
+			continue
+		}
+		if suppresses(lines[line-1], d.Rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// suppresses reports whether the given source line contains a suppression
+// comment of the given rule.
+func suppresses(line, rule string) bool {
+	const marker = "cc:ignore"
+	pos := strings.Index(line, marker)
+	if pos == -1 {
+		return false
+	}
+	rest := line[pos+len(marker):]
+	if !strings.HasPrefix(rest, "(") {
+		// Suppress all rules.
+		return true
+	}
+	end := strings.Index(rest, ")")
+	if end == -1 {
+		return false
+	}
+	for _, r := range strings.Split(rest[1:end], ",") {
+		if strings.TrimSpace(r) == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// walkUser walks the ASTs of the given translation units, invoking f for each
+// node visited. Top-level declarations located in system headers are skipped.
+func walkUser(files []*cc.File, f func(n *cc.Node)) {
+	for _, file := range files {
+		for _, n := range file.Root.Children {
+			if n.Body.Location().IsInSystemHeader() {
+				continue
+			}
+			cc.Walk(n, f)
+		}
+	}
+}
+
+// walkFuncDefs invokes f for each function definition of the given translation
+// units. Functions defined in system headers are skipped.
+func walkFuncDefs(files []*cc.File, f func(fn *cc.Node)) {
+	walkUser(files, func(n *cc.Node) {
+		switch n.Body.Kind() {
+		case clang.Cursor_FunctionDecl, clang.Cursor_CXXMethod, clang.Cursor_Constructor, clang.Cursor_Destructor:
+			if n.Body.IsCursorDefinition() {
+				f(n)
+			}
+		}
+	})
+}
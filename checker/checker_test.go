@@ -0,0 +1,53 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+// check runs the given checker on the given source, returning the diagnostics
+// as "LINE RULE" strings. Compiler warnings are disabled, as fixtures
+// deliberately contain hazards.
+func check(t *testing.T, c Checker, src string, clangArgs ...string) []string {
+	file, err := cc.ParseSource("input.c", src, append([]string{"-w"}, clangArgs...)...)
+	if err != nil {
+		t.Fatalf("unable to parse source; %+v", err)
+	}
+	defer file.Close()
+	var diags []string
+	for _, d := range Run([]*cc.File{file}, c) {
+		diags = append(diags, fmt.Sprintf("%d %s", d.Loc.Line, d.Rule))
+	}
+	return diags
+}
+
+// checkDiags checks that the diagnostics of the given checker on the given
+// source match the expected "LINE RULE" strings.
+func checkDiags(t *testing.T, c Checker, src string, want []string, clangArgs ...string) {
+	got := check(t, c, src, clangArgs...)
+	if strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("%s: diagnostics mismatch of\n%s\nexpected %q, got %q", c.Name(), src, want, got)
+	}
+}
+
+func TestSuppresses(t *testing.T) {
+	golden := []struct {
+		line string
+		rule string
+		want bool
+	}{
+		{line: "p = strcpy(dst, src);", rule: "banned-function", want: false},
+		{line: "p = strcpy(dst, src); // cc:ignore", rule: "banned-function", want: true},
+		{line: "p = strcpy(dst, src); // cc:ignore(banned-function)", rule: "banned-function", want: true},
+		{line: "p = strcpy(dst, src); // cc:ignore(format, banned-function)", rule: "banned-function", want: true},
+		{line: "p = strcpy(dst, src); // cc:ignore(format)", rule: "banned-function", want: false},
+	}
+	for _, g := range golden {
+		if got := suppresses(g.line, g.rule); got != g.want {
+			t.Errorf("%q: suppression of %q mismatch; expected %v, got %v", g.line, g.rule, g.want, got)
+		}
+	}
+}
@@ -0,0 +1,508 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+)
+
+// FormatConfig is the configuration of the format string checker.
+type FormatConfig struct {
+	// Project-specific format functions (e.g. logging functions), in addition
+	// to the printf and scanf family of the C standard library and functions
+	// declared with the format attribute.
+	Funcs []*FormatFunc `json:"funcs"`
+}
+
+// FormatFunc is a function with a printf or scanf style format string.
+type FormatFunc struct {
+	// Function name.
+	Name string `json:"name"`
+	// Format style ("printf" or "scanf").
+	Style string `json:"style"`
+	// Argument index of the format string (1-indexed).
+	FormatArg int `json:"format_arg"`
+	// Argument index of the first data argument (1-indexed); or 0 if data
+	// arguments are passed as a va_list.
+	FirstArg int `json:"first_arg"`
+}
+
+// stdFormatFuncs are the format functions of the C standard library and POSIX.
+var stdFormatFuncs = []*FormatFunc{
+	{Name: "printf", Style: "printf", FormatArg: 1, FirstArg: 2},
+	{Name: "fprintf", Style: "printf", FormatArg: 2, FirstArg: 3},
+	{Name: "dprintf", Style: "printf", FormatArg: 2, FirstArg: 3},
+	{Name: "sprintf", Style: "printf", FormatArg: 2, FirstArg: 3},
+	{Name: "snprintf", Style: "printf", FormatArg: 3, FirstArg: 4},
+	{Name: "asprintf", Style: "printf", FormatArg: 2, FirstArg: 3},
+	{Name: "syslog", Style: "printf", FormatArg: 2, FirstArg: 3},
+	{Name: "vprintf", Style: "printf", FormatArg: 1},
+	{Name: "vfprintf", Style: "printf", FormatArg: 2},
+	{Name: "vdprintf", Style: "printf", FormatArg: 2},
+	{Name: "vsprintf", Style: "printf", FormatArg: 2},
+	{Name: "vsnprintf", Style: "printf", FormatArg: 3},
+	{Name: "vasprintf", Style: "printf", FormatArg: 2},
+	{Name: "vsyslog", Style: "printf", FormatArg: 2},
+	{Name: "scanf", Style: "scanf", FormatArg: 1, FirstArg: 2},
+	{Name: "fscanf", Style: "scanf", FormatArg: 2, FirstArg: 3},
+	{Name: "sscanf", Style: "scanf", FormatArg: 2, FirstArg: 3},
+	{Name: "vscanf", Style: "scanf", FormatArg: 1},
+	{Name: "vfscanf", Style: "scanf", FormatArg: 2},
+	{Name: "vsscanf", Style: "scanf", FormatArg: 2},
+}
+
+// Format is a checker which validates the format strings of printf and scanf
+// style functions against the types of their arguments.
+type Format struct {
+	// Checker configuration; or nil for no project-specific format functions.
+	Config *FormatConfig
+}
+
+// Name returns the name of the checker.
+func (c *Format) Name() string {
+	return "format"
+}
+
+// Check checks the given translation units, returning the diagnostics.
+func (c *Format) Check(files []*cc.File) []*Diagnostic {
+	funcs := make(map[string]*FormatFunc)
+	for _, f := range stdFormatFuncs {
+		funcs[f.Name] = f
+	}
+	// Functions declared with the format attribute.
+	for _, file := range files {
+		cc.Walk(file.Root, func(n *cc.Node) {
+			if n.Body.Kind() != clang.Cursor_FunctionDecl {
+				return
+			}
+			if f := formatAttr(n); f != nil {
+				funcs[f.Name] = f
+			}
+		})
+	}
+	if c.Config != nil {
+		for _, f := range c.Config.Funcs {
+			funcs[f.Name] = f
+		}
+	}
+	var diags []*Diagnostic
+	walkUser(files, func(n *cc.Node) {
+		if n.Body.Kind() != clang.Cursor_CallExpr {
+			return
+		}
+		callee := n.Body.Referenced()
+		if callee.IsNull() || callee.Kind() != clang.Cursor_FunctionDecl {
+			return
+		}
+		f, ok := funcs[callee.Spelling()]
+		if !ok {
+			return
+		}
+		diags = append(diags, checkFormatCall(n, f)...)
+	})
+	return diags
+}
+
+// formatAttr returns the format function specified by the format attribute of
+// the given function declaration; or nil if not present.
+func formatAttr(decl *cc.Node) *FormatFunc {
+	for _, child := range decl.Children {
+		if !child.Body.Kind().IsAttribute() {
+			continue
+		}
+		// format(printf, 2, 3)
+		toks := child.Tokens()
+		if len(toks) < 7 || (toks[0] != "format" && toks[0] != "__format__") {
+			continue
+		}
+		style := strings.Trim(toks[2], "_")
+		if style != "printf" && style != "scanf" {
+			continue
+		}
+		f := &FormatFunc{Name: decl.Body.Spelling(), Style: style}
+		if _, err := fmt.Sscan(toks[4], &f.FormatArg); err != nil {
+			continue
+		}
+		if _, err := fmt.Sscan(toks[6], &f.FirstArg); err != nil {
+			continue
+		}
+		return f
+	}
+	return nil
+}
+
+// checkFormatCall checks the format string of the given call to a format
+// function.
+func checkFormatCall(call *cc.Node, f *FormatFunc) []*Diagnostic {
+	if len(call.Children) == 0 {
+		return nil
+	}
+	// The first child of a call expression is the callee.
+	args := call.Children[1:]
+	if len(args) != int(call.Body.NumArguments()) || f.FormatArg < 1 || f.FormatArg > len(args) {
+		return nil
+	}
+	var diags []*Diagnostic
+	report := func(loc cc.Location, rule, format string, a ...interface{}) {
+		d := &Diagnostic{
+			Loc:      loc,
+			Rule:     rule,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf(format, a...),
+		}
+		diags = append(diags, d)
+	}
+	name := f.Name
+	formatArg := args[f.FormatArg-1]
+	lit := stripImplicit(formatArg)
+	if lit.Body.Kind() != clang.Cursor_StringLiteral {
+		if f.FirstArg != 0 && len(args) < f.FirstArg {
+			report(formatArg.Loc, "format-nonliteral", "format string of %s is not a string literal and has no arguments", name)
+		}
+		return diags
+	}
+	value, ok := literalValue(lit)
+	if !ok {
+		// Format strings containing macro expansions (e.g. PRId64) are not
+		// checked.
+		return diags
+	}
+	directives, err := parseFormat(f.Style, value)
+	if err != nil {
+		report(lit.Loc, "format-invalid", "invalid format string of %s: %v", name, err)
+		return diags
+	}
+	if f.FirstArg == 0 {
+		// Data arguments passed as a va_list; only the format string is
+		// checked.
+		for _, d := range directives {
+			if d.conv == 'n' && f.Style == "printf" {
+				report(lit.Loc, "format-n", "%%n in format string of %s may be used to write to memory", name)
+			}
+		}
+		return diags
+	}
+	argIndex := f.FirstArg - 1
+	for _, d := range directives {
+		if d.conv == 'n' && f.Style == "printf" {
+			report(lit.Loc, "format-n", "%%n in format string of %s may be used to write to memory", name)
+		}
+		if f.Style == "scanf" && (d.conv == 's' || d.conv == '[') && !d.width && !d.suppress && !d.alloc {
+			report(lit.Loc, "format-unbounded-scan", "%s without field width in format string of %s may overflow buffer", d.text, name)
+		}
+		// Field width and precision arguments.
+		for i := 0; i < d.stars; i++ {
+			if argIndex >= len(args) {
+				report(call.Loc, "format-missing-arg", "missing field width or precision argument for %s in call to %s", d.text, name)
+				return diags
+			}
+			if !isIntegerType(args[argIndex].Body.Type()) {
+				report(args[argIndex].Loc, "format-type", "field width or precision of %s expects int, but argument %d has type %s", d.text, argIndex+1, args[argIndex].Body.Type().Spelling())
+			}
+			argIndex++
+		}
+		if d.conv == '%' || d.conv == 'm' || d.suppress {
+			continue
+		}
+		if argIndex >= len(args) {
+			report(call.Loc, "format-missing-arg", "missing argument for %s in call to %s", d.text, name)
+			return diags
+		}
+		arg := args[argIndex]
+		if d.alloc {
+			// Pointer to allocated buffer; not checked.
+			argIndex++
+			continue
+		}
+		if want, ok := checkFormatArg(f.Style, d, arg.Body.Type()); !ok {
+			report(arg.Loc, "format-type", "%s expects %s, but argument %d has type %s", d.text, want, argIndex+1, arg.Body.Type().Spelling())
+		}
+		argIndex++
+	}
+	if argIndex < len(args) {
+		report(args[argIndex].Loc, "format-extra-arg", "%d extra argument(s) in call to %s not used by format string", len(args)-argIndex, name)
+	}
+	return diags
+}
+
+// stripImplicit returns the given expression with implicit casts and
+// parentheses removed.
+func stripImplicit(n *cc.Node) *cc.Node {
+	for len(n.Children) == 1 && (n.Body.Kind() == clang.Cursor_UnexposedExpr || n.Body.Kind() == clang.Cursor_ParenExpr) {
+		n = n.Children[0]
+	}
+	return n
+}
+
+// literalValue returns the contents of the given string literal, concatenating
+// adjacent string literals. Backslashes and the characters they escape are
+// replaced by a space, so escaped characters never start a conversion
+// specification. The boolean return value is false if the string literal
+// contains macro expansions (e.g. "%" PRId64), the values of which are not
+// known from the tokens of the literal.
+func literalValue(lit *cc.Node) (string, bool) {
+	var sb strings.Builder
+	for _, tok := range lit.Tokens() {
+		start := strings.Index(tok, `"`)
+		end := strings.LastIndex(tok, `"`)
+		if start == -1 || end <= start {
+			return "", false
+		}
+		s := tok[start+1 : end]
+		for i := 0; i < len(s); i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				sb.WriteByte(' ')
+				i++
+				continue
+			}
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String(), true
+}
+
+// directive is a conversion specification of a format string.
+type directive struct {
+	// Text of the conversion specification (e.g. "%-08ld").
+	text string
+	// Length modifier (e.g. "l", "hh").
+	length string
+	// Conversion specifier.
+	conv byte
+	// Number of '*' field width and precision arguments (printf).
+	stars int
+	// Assignment suppression (scanf).
+	suppress bool
+	// Field width specified.
+	width bool
+	// Assignment-allocation modifier (scanf); e.g. "%ms".
+	alloc bool
+}
+
+// parseFormat parses the given printf or scanf style format string.
+func parseFormat(style, s string) ([]*directive, error) {
+	var directives []*directive
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		start := i
+		i++
+		d := &directive{}
+		// Positional arguments (e.g. "%1$d") are not checked.
+		if j := i; j < len(s) {
+			for j < len(s) && '0' <= s[j] && s[j] <= '9' {
+				j++
+			}
+			if j > i && j < len(s) && s[j] == '$' {
+				return nil, nil
+			}
+		}
+		if style == "scanf" {
+			if i < len(s) && s[i] == '*' {
+				d.suppress = true
+				i++
+			}
+			for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+				d.width = true
+				i++
+			}
+			if i < len(s) && s[i] == 'm' {
+				d.alloc = true
+				i++
+			}
+		} else {
+			// Flags.
+			for i < len(s) && strings.IndexByte("-+ #0'", s[i]) != -1 {
+				i++
+			}
+			// Field width.
+			if i < len(s) && s[i] == '*' {
+				d.stars++
+				i++
+			}
+			for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+				d.width = true
+				i++
+			}
+			// Precision.
+			if i < len(s) && s[i] == '.' {
+				i++
+				if i < len(s) && s[i] == '*' {
+					d.stars++
+					i++
+				}
+				for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+					i++
+				}
+			}
+		}
+		// Length modifier.
+		for _, length := range []string{"hh", "ll", "h", "l", "j", "z", "t", "L", "q"} {
+			if strings.HasPrefix(s[i:], length) {
+				d.length = length
+				i += len(length)
+				break
+			}
+		}
+		if i >= len(s) {
+			return nil, fmt.Errorf("incomplete conversion specification %q", s[start:])
+		}
+		d.conv = s[i]
+		if d.conv == '[' && style == "scanf" {
+			// Scan set; a leading ']' is part of the set.
+			j := i + 1
+			if j < len(s) && s[j] == '^' {
+				j++
+			}
+			if j < len(s) && s[j] == ']' {
+				j++
+			}
+			end := strings.IndexByte(s[j:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated scan set %q", s[start:])
+			}
+			i = j + end
+		}
+		d.text = s[start : i+1]
+		if !validConv(style, d.conv) {
+			return nil, fmt.Errorf("invalid conversion specifier %q", d.text)
+		}
+		directives = append(directives, d)
+	}
+	return directives, nil
+}
+
+// validConv reports whether the given conversion specifier is valid for the
+// given format style. The GNU %m conversion of printf (strerror(errno)) is
+// accepted.
+func validConv(style string, conv byte) bool {
+	const common = "%diouxXcspnaAeEfFgG"
+	if style == "scanf" {
+		return strings.IndexByte(common+"[", conv) != -1
+	}
+	return strings.IndexByte(common+"m", conv) != -1
+}
+
+// Integer type kinds by printf length modifier, after default argument
+// promotion.
+var (
+	intKinds      = []clang.TypeKind{clang.Type_Int, clang.Type_UInt, clang.Type_Bool, clang.Type_Char_S, clang.Type_Char_U, clang.Type_SChar, clang.Type_UChar, clang.Type_Short, clang.Type_UShort, clang.Type_WChar, clang.Type_Char16, clang.Type_Char32, clang.Type_Enum}
+	longKinds     = []clang.TypeKind{clang.Type_Long, clang.Type_ULong}
+	longLongKinds = []clang.TypeKind{clang.Type_LongLong, clang.Type_ULongLong}
+	// intmax_t, size_t and ptrdiff_t are target dependent.
+	sizeKinds = []clang.TypeKind{clang.Type_Int, clang.Type_UInt, clang.Type_Long, clang.Type_ULong, clang.Type_LongLong, clang.Type_ULongLong}
+	charKinds = []clang.TypeKind{clang.Type_Char_S, clang.Type_Char_U, clang.Type_SChar, clang.Type_UChar}
+)
+
+// Integer type kinds by scanf length modifier, pointed to by arguments.
+var (
+	scanIntKinds   = []clang.TypeKind{clang.Type_Int, clang.Type_UInt, clang.Type_Enum}
+	scanShortKinds = []clang.TypeKind{clang.Type_Short, clang.Type_UShort}
+)
+
+// checkFormatArg reports whether the given argument type matches the given
+// conversion specification. If not, a description of the expected type is
+// returned.
+func checkFormatArg(style string, d *directive, t clang.Type) (string, bool) {
+	t = t.CanonicalType()
+	kind := t.Kind()
+	pointee := clang.Type_Invalid
+	if kind == clang.Type_Pointer {
+		pointee = t.PointeeType().CanonicalType().Kind()
+	}
+	// integerKinds returns the expected integer type kinds of the directive.
+	integerKinds := func(scan bool) (string, []clang.TypeKind) {
+		switch d.length {
+		case "hh":
+			if scan {
+				return "char", charKinds
+			}
+		case "h":
+			if scan {
+				return "short", scanShortKinds
+			}
+		case "l":
+			return "long", longKinds
+		case "ll", "q", "L":
+			return "long long", longLongKinds
+		case "j", "z", "t":
+			return "intmax_t, size_t or ptrdiff_t", sizeKinds
+		}
+		if scan {
+			return "int", scanIntKinds
+		}
+		return "int", intKinds
+	}
+	switch d.conv {
+	case 'd', 'i', 'o', 'u', 'x', 'X':
+		if style == "scanf" {
+			desc, kinds := integerKinds(true)
+			return desc + " *", hasKind(kinds, pointee)
+		}
+		desc, kinds := integerKinds(false)
+		return desc, hasKind(kinds, kind)
+	case 'n':
+		desc, kinds := integerKinds(true)
+		return desc + " *", hasKind(kinds, pointee)
+	case 'a', 'A', 'e', 'E', 'f', 'F', 'g', 'G':
+		if style == "scanf" {
+			switch d.length {
+			case "l":
+				return "double *", pointee == clang.Type_Double
+			case "L":
+				return "long double *", pointee == clang.Type_LongDouble
+			}
+			return "float *", pointee == clang.Type_Float
+		}
+		if d.length == "L" {
+			return "long double", kind == clang.Type_LongDouble
+		}
+		return "double", kind == clang.Type_Double || kind == clang.Type_Float
+	case 'c':
+		if style == "scanf" {
+			if d.length == "l" {
+				return "wchar_t *", pointee == clang.Type_WChar || isIntegerKind(pointee)
+			}
+			return "char *", hasKind(charKinds, pointee)
+		}
+		return "int", isIntegerKind(kind)
+	case 's', '[':
+		if d.length == "l" {
+			return "wchar_t *", pointee == clang.Type_WChar || isIntegerKind(pointee)
+		}
+		return "char *", hasKind(charKinds, pointee)
+	case 'p':
+		if style == "scanf" {
+			return "void **", pointee == clang.Type_Pointer
+		}
+		return "pointer", kind == clang.Type_Pointer || kind == clang.Type_NullPtr
+	}
+	return "", true
+}
+
+// hasKind reports whether the given type kinds contain kind.
+func hasKind(kinds []clang.TypeKind, kind clang.TypeKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// isIntegerKind reports whether the given type kind is an integer type.
+func isIntegerKind(kind clang.TypeKind) bool {
+	switch kind {
+	case clang.Type_Bool, clang.Type_Char_U, clang.Type_UChar, clang.Type_Char16, clang.Type_Char32, clang.Type_UShort, clang.Type_UInt, clang.Type_ULong, clang.Type_ULongLong, clang.Type_UInt128, clang.Type_Char_S, clang.Type_SChar, clang.Type_WChar, clang.Type_Short, clang.Type_Int, clang.Type_Long, clang.Type_LongLong, clang.Type_Int128, clang.Type_Enum:
+		return true
+	}
+	return false
+}
+
+// isIntegerType reports whether the given type is an integer type.
+func isIntegerType(t clang.Type) bool {
+	return isIntegerKind(t.CanonicalType().Kind())
+}
@@ -0,0 +1,125 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	golden := []struct {
+		style string
+		s     string
+		// Conversion specifications as "TEXT:LENGTH:STARS", or error.
+		want string
+	}{
+		{style: "printf", s: "%d %s", want: "%d::0 %s::0"},
+		{style: "printf", s: "%-08ld%%", want: "%-08ld:l:0 %%::0"},
+		{style: "printf", s: "%*.*f", want: "%*.*f::2"},
+		{style: "printf", s: "%hhu %zu", want: "%hhu:hh:0 %zu:z:0"},
+		{style: "printf", s: "%1$d", want: ""},
+		{style: "printf", s: "%y", want: `error: invalid conversion specifier "%y"`},
+		{style: "printf", s: "%l", want: `error: incomplete conversion specification "%l"`},
+		{style: "scanf", s: "%*d %15s", want: "%*d::0 %15s::0"},
+		{style: "scanf", s: "%[^]a]", want: "%[^]a]::0"},
+		{style: "scanf", s: "%[abc", want: `error: unterminated scan set "%[abc"`},
+		{style: "printf", s: "%m: %s", want: "%m::0 %s::0"},
+		{style: "scanf", s: "%ms %m[a-z]", want: "%ms::0 %m[a-z]::0"},
+		{style: "scanf", s: "%m", want: `error: incomplete conversion specification "%m"`},
+	}
+	for _, g := range golden {
+		directives, err := parseFormat(g.style, g.s)
+		var got string
+		if err != nil {
+			got = "error: " + err.Error()
+		} else {
+			var ss []string
+			for _, d := range directives {
+				ss = append(ss, fmt.Sprintf("%s:%s:%d", d.text, d.length, d.stars))
+			}
+			got = strings.Join(ss, " ")
+		}
+		if got != g.want {
+			t.Errorf("%s %q: directives mismatch; expected %q, got %q", g.style, g.s, g.want, got)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	const src = `
+int printf(const char *fmt, ...);
+int scanf(const char *fmt, ...);
+void logmsg(int level, const char *fmt, ...) __attribute__((format(printf, 2, 3)));
+void mylog(const char *fmt, ...);
+void f(int i, long l, char *s, const char *fmt, double d) {
+	printf("%d %s\n", i, s);
+	printf("%d\n", l);
+	printf("%s %d\n", s);
+	printf("%d\n", i, i);
+	printf(fmt);
+	printf("%n", &i);
+	scanf("%s", s);
+	scanf("%15s", s);
+	logmsg(1, "%f %*d\n", d, i, i);
+	logmsg(1, "%y", i);
+	printf("%5.2f%%\n", d);
+	mylog("%d", s);
+}
+`
+	golden := []struct {
+		config *FormatConfig
+		want   []string
+	}{
+		{
+			config: nil,
+			want: []string{
+				"8 format-type",
+				"9 format-missing-arg",
+				"10 format-extra-arg",
+				"11 format-nonliteral",
+				"12 format-n",
+				"13 format-unbounded-scan",
+				"16 format-invalid",
+			},
+		},
+		{
+			config: &FormatConfig{
+				Funcs: []*FormatFunc{{Name: "mylog", Style: "printf", FormatArg: 1, FirstArg: 2}},
+			},
+			want: []string{
+				"8 format-type",
+				"9 format-missing-arg",
+				"10 format-extra-arg",
+				"11 format-nonliteral",
+				"12 format-n",
+				"13 format-unbounded-scan",
+				"16 format-invalid",
+				"18 format-type",
+			},
+		},
+	}
+	for _, g := range golden {
+		checkDiags(t, &Format{Config: g.config}, src, g.want)
+	}
+}
+
+func TestFormatExtensions(t *testing.T) {
+	const src = `
+#define PRId64 "ld"
+#define FMT "%d\n"
+int printf(const char *fmt, ...);
+int scanf(const char *fmt, ...);
+void f(long l, char *s) {
+	printf("%" PRId64 "\n", l);
+	printf(FMT, s);
+	printf("%m\n");
+	printf("%s: %m\n", s);
+	scanf("%ms", &s);
+	printf("%m %d\n", s);
+}
+`
+	// Format strings containing macro expansions are not checked, and %m
+	// consumes no argument.
+	want := []string{"12 format-type"}
+	checkDiags(t, &Format{}, src, want)
+}
@@ -0,0 +1,339 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+)
+
+// IntegerConfig is the configuration of the integer conversion and overflow
+// checker.
+type IntegerConfig struct {
+	// Severity of rules, by rule name; rules not present use their default
+	// severity.
+	Severity map[string]Severity `json:"severity"`
+	// Disabled rules.
+	Disabled []string `json:"disabled"`
+	// Index of the size argument of allocation functions, by function name; or
+	// nil to use the allocation functions of DefaultIntegerConfig.
+	AllocFuncs map[string]int `json:"alloc_funcs"`
+}
+
+// DefaultIntegerConfig is the default configuration of the integer conversion
+// and overflow checker.
+var DefaultIntegerConfig = &IntegerConfig{
+	AllocFuncs: map[string]int{
+		"malloc":        0,
+		"realloc":       1,
+		"alloca":        0,
+		"valloc":        0,
+		"aligned_alloc": 1,
+		"memalign":      1,
+	},
+}
+
+// defaultIntegerSeverity is the default severity of the rules of the integer
+// conversion and overflow checker.
+var defaultIntegerSeverity = map[string]Severity{
+	"integer-narrowing":      SeverityWarning,
+	"integer-sign-compare":   SeverityWarning,
+	"integer-shift-width":    SeverityError,
+	"integer-alloc-overflow": SeverityWarning,
+}
+
+// Integer is a checker which reports integer conversion and overflow hazards:
+// implicit narrowing conversions, comparisons between signed and unsigned
+// integers, shift amounts exceeding the width of the shifted operand, and
+// multiplications used as the size of allocations.
+//
+// Implicit conversions are located by the implicit cast nodes of expressions.
+// Conversions of constant expressions are not reported, nor narrowing
+// conversions of values which fit in the destination type (e.g. "x & 0xFF"
+// assigned to unsigned char).
+type Integer struct {
+	// Checker configuration; or nil to use DefaultIntegerConfig.
+	Config *IntegerConfig
+}
+
+// Name returns the name of the checker.
+func (c *Integer) Name() string {
+	return "integer"
+}
+
+// Check checks the given translation units, returning the diagnostics.
+func (c *Integer) Check(files []*cc.File) []*Diagnostic {
+	config := c.Config
+	if config == nil {
+		config = DefaultIntegerConfig
+	}
+	allocFuncs := config.AllocFuncs
+	if allocFuncs == nil {
+		allocFuncs = DefaultIntegerConfig.AllocFuncs
+	}
+	disabled := make(map[string]bool)
+	for _, rule := range config.Disabled {
+		disabled[rule] = true
+	}
+	var diags []*Diagnostic
+	report := func(n *cc.Node, rule, msg, suggestion string) {
+		if disabled[rule] {
+			return
+		}
+		severity, ok := config.Severity[rule]
+		if !ok {
+			severity = defaultIntegerSeverity[rule]
+		}
+		d := &Diagnostic{Loc: n.Loc, Rule: rule, Severity: severity, Message: msg, Suggestion: suggestion}
+		diags = append(diags, d)
+	}
+	walkUser(files, func(n *cc.Node) {
+		switch n.Body.Kind() {
+		case clang.Cursor_UnexposedExpr:
+			if msg, ok := checkNarrowing(n); ok {
+				report(n, "integer-narrowing", msg, "add an explicit cast or widen the destination type")
+			}
+		case clang.Cursor_BinaryOperator, clang.Cursor_CompoundAssignOperator:
+			if len(n.Children) != 2 {
+				return
+			}
+			switch op := n.Operator(); op {
+			case "==", "!=", "<", ">", "<=", ">=":
+				if msg, ok := checkSignCompare(n); ok {
+					report(n, "integer-sign-compare", msg, "cast the operands to a common type after checking the sign of the signed operand")
+				}
+			case "<<", ">>", "<<=", ">>=":
+				if msg, ok := checkShiftWidth(n, op); ok {
+					report(n.Children[1], "integer-shift-width", msg, "")
+				}
+			}
+		case clang.Cursor_CallExpr:
+			name := n.Body.Referenced().Spelling()
+			i, ok := allocFuncs[name]
+			if !ok || i+1 >= len(n.Children) {
+				return
+			}
+			size := n.Children[i+1]
+			if mul := findMul(size); mul != nil {
+				msg := fmt.Sprintf("multiplication in size argument of %s may overflow", name)
+				report(mul, "integer-alloc-overflow", msg, "use calloc or reallocarray, or check the multiplication for overflow")
+			}
+		}
+	})
+	return diags
+}
+
+// intType is the signedness and size of an integer type.
+type intType struct {
+	// Signed integer type.
+	signed bool
+	// Size in bytes.
+	size int64
+}
+
+// integerType returns the signedness and size of the given integer type.
+func integerType(t clang.Type) (intType, bool) {
+	canon := t.CanonicalType()
+	kind := canon.Kind()
+	if !isIntegerKind(kind) || kind == clang.Type_Bool {
+		return intType{}, false
+	}
+	size := canon.SizeOf()
+	if size < 0 {
+		return intType{}, false
+	}
+	signed := false
+	switch kind {
+	case clang.Type_Char_S, clang.Type_SChar, clang.Type_WChar, clang.Type_Short, clang.Type_Int, clang.Type_Long, clang.Type_LongLong, clang.Type_Int128:
+		signed = true
+	case clang.Type_Enum:
+		it, ok := integerType(canon.Declaration().EnumDeclIntegerType())
+		signed = ok && it.signed
+	}
+	return intType{signed: signed, size: size}, true
+}
+
+// isFloatingType reports whether the given type is a floating-point type.
+func isFloatingType(t clang.Type) bool {
+	switch t.CanonicalType().Kind() {
+	case clang.Type_Float, clang.Type_Double, clang.Type_LongDouble:
+		return true
+	}
+	return false
+}
+
+// checkNarrowing checks whether the given implicit cast node is a narrowing
+// conversion of an integer or floating-point value, returning a description of
+// the conversion.
+func checkNarrowing(cast *cc.Node) (string, bool) {
+	if len(cast.Children) != 1 || !cast.Children[0].Body.Kind().IsExpression() || isConstExpr(cast.Children[0]) {
+		return "", false
+	}
+	dst, src := cast.Body.Type(), cast.Children[0].Body.Type()
+	dstSize, srcSize := dst.CanonicalType().SizeOf(), src.CanonicalType().SizeOf()
+	if dstSize < 0 || srcSize < 0 {
+		return "", false
+	}
+	dstType, dstInt := integerType(dst)
+	_, srcInt := integerType(src)
+	switch {
+	case dstInt && srcInt && dstSize < srcSize && !fits(cast.Children[0], dstType):
+		return fmt.Sprintf("implicit conversion from %s to %s may truncate value", src.Spelling(), dst.Spelling()), true
+	case dstInt && isFloatingType(src):
+		return fmt.Sprintf("implicit conversion from %s to %s discards fractional part", src.Spelling(), dst.Spelling()), true
+	case isFloatingType(dst) && isFloatingType(src) && dstSize < srcSize:
+		return fmt.Sprintf("implicit conversion from %s to %s loses precision", src.Spelling(), dst.Spelling()), true
+	}
+	return "", false
+}
+
+// fits reports whether the value of the given integer expression fits in the
+// given integer type; i.e. constants within the range of the type, operands
+// not wider than the type, and masks or arithmetic of such values (e.g. "c + 1"
+// assigned to char c after integer promotion, or "x & 0xFF" assigned to
+// unsigned char). Overflow of arithmetic operators is not considered.
+func fits(expr *cc.Node, t intType) bool {
+	expr = stripImplicit(expr)
+	if v, ok := caseValue(expr); ok {
+		bits := uint(t.size * 8)
+		switch {
+		case bits >= 64:
+			return true
+		case t.signed:
+			return v >= -1<<(bits-1) && v < 1<<(bits-1)
+		default:
+			return v >= 0 && v < 1<<bits
+		}
+	}
+	if it, ok := integerType(expr.Body.Type()); ok && it.size <= t.size {
+		return true
+	}
+	switch expr.Body.Kind() {
+	case clang.Cursor_BinaryOperator:
+		if len(expr.Children) != 2 {
+			return false
+		}
+		switch expr.Operator() {
+		case "&":
+			return fits(expr.Children[0], t) || fits(expr.Children[1], t)
+		case "+", "-", "*", "|", "^":
+			return fits(expr.Children[0], t) && fits(expr.Children[1], t)
+		case ">>":
+			return fits(expr.Children[0], t)
+		}
+	case clang.Cursor_ConditionalOperator:
+		// cond, true, false
+		return len(expr.Children) == 3 && fits(expr.Children[1], t) && fits(expr.Children[2], t)
+	}
+	return false
+}
+
+// checkSignCompare checks whether the given comparison compares a signed and
+// an unsigned integer, where the signed operand is converted to unsigned.
+func checkSignCompare(cmp *cc.Node) (string, bool) {
+	lhs, rhs := stripImplicit(cmp.Children[0]), stripImplicit(cmp.Children[1])
+	x, ok := integerType(lhs.Body.Type())
+	if !ok {
+		return "", false
+	}
+	y, ok := integerType(rhs.Body.Type())
+	if !ok || x.signed == y.signed {
+		return "", false
+	}
+	signed, unsigned := x, y
+	signedExpr := lhs
+	if y.signed {
+		signed, unsigned = y, x
+		signedExpr = rhs
+	}
+	// Integer promotions convert small unsigned operands to int.
+	if unsigned.size < 4 || signed.size > unsigned.size {
+		return "", false
+	}
+	if v, ok := caseValue(signedExpr); ok && v >= 0 {
+		// Non-negative constant.
+		return "", false
+	}
+	return fmt.Sprintf("comparison of signed %s with unsigned %s converts the signed operand to unsigned", signedExpr.Body.Type().Spelling(), stripImplicit(otherOperand(cmp, signedExpr)).Body.Type().Spelling()), true
+}
+
+// otherOperand returns the operand of the given binary operator which is not
+// the given stripped operand.
+func otherOperand(op, operand *cc.Node) *cc.Node {
+	if stripImplicit(op.Children[0]) == operand {
+		return op.Children[1]
+	}
+	return op.Children[0]
+}
+
+// checkShiftWidth checks whether the constant shift amount of the given shift
+// operator is negative or not less than the width of the promoted left
+// operand.
+func checkShiftWidth(shift *cc.Node, op string) (string, bool) {
+	amount, ok := caseValue(shift.Children[1])
+	if !ok {
+		return "", false
+	}
+	if amount < 0 {
+		return fmt.Sprintf("shift by negative amount %d", amount), true
+	}
+	t := shift.Body.Type()
+	if op == "<<=" || op == ">>=" {
+		t = shift.Children[0].Body.Type()
+	}
+	it, ok := integerType(t)
+	if !ok {
+		return "", false
+	}
+	width := it.size * 8
+	if width < 32 {
+		// Integer promotion to int.
+		width = 32
+	}
+	if amount >= width {
+		return fmt.Sprintf("shift amount %d is not less than the width of the %d-bit operand", amount, width), true
+	}
+	return "", false
+}
+
+// findMul returns the non-constant multiplication of the given allocation size
+// expression; or nil if not present.
+func findMul(size *cc.Node) *cc.Node {
+	size = stripCasts(size)
+	if size.Body.Kind() != clang.Cursor_BinaryOperator || len(size.Children) != 2 {
+		return nil
+	}
+	switch size.Operator() {
+	case "*":
+		if !isConstExpr(size) {
+			return size
+		}
+	case "+", "-":
+		if mul := findMul(size.Children[0]); mul != nil {
+			return mul
+		}
+		return findMul(size.Children[1])
+	}
+	return nil
+}
+
+// isConstExpr reports whether the given expression is an integer or
+// floating-point constant expression, consisting of literals, enumerators,
+// sizeof expressions and operators.
+func isConstExpr(n *cc.Node) bool {
+	n = stripCasts(n)
+	switch n.Body.Kind() {
+	case clang.Cursor_IntegerLiteral, clang.Cursor_FloatingLiteral, clang.Cursor_CharacterLiteral, clang.Cursor_UnaryExpr:
+		return true
+	case clang.Cursor_DeclRefExpr:
+		return n.Body.Referenced().Kind() == clang.Cursor_EnumConstantDecl
+	case clang.Cursor_UnaryOperator, clang.Cursor_BinaryOperator, clang.Cursor_ConditionalOperator:
+		for _, child := range n.Children {
+			if !isConstExpr(child) {
+				return false
+			}
+		}
+		return len(n.Children) > 0
+	}
+	return false
+}
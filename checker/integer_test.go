@@ -0,0 +1,53 @@
+package checker
+
+import "testing"
+
+func TestInteger(t *testing.T) {
+	golden := []struct {
+		src  string
+		want []string
+	}{
+		// Narrowing conversions.
+		{src: "void f(int x) { char c = x; }", want: []string{"1 integer-narrowing"}},
+		{src: "void f(long l) { int i = l; }", want: []string{"1 integer-narrowing"}},
+		{src: "void f(double d) { int i = d; }", want: []string{"1 integer-narrowing"}},
+		{src: "void f(double d) { float x = d; }", want: []string{"1 integer-narrowing"}},
+		{src: "void f(int x) { long l = x; }", want: nil},
+		{src: "void f(void) { char c = 1000 / 10; }", want: nil},
+		// Promoted operands of the destination width.
+		{src: "void f(char c) { c = c + 1; }", want: nil},
+		{src: "void f(unsigned char c) { c = c * 2 - 1; }", want: nil},
+		{src: "void f(char c, char d) { c = c ^ d; }", want: nil},
+		{src: "void f(short s, int x) { s = s + x; }", want: []string{"1 integer-narrowing"}},
+		{src: "void f(char c) { c = c + 1000; }", want: []string{"1 integer-narrowing"}},
+		// Masked values.
+		{src: "void f(unsigned x) { unsigned char c = x & 0xFF; }", want: nil},
+		{src: "void f(unsigned x) { unsigned char c = 0xFF & x; }", want: nil},
+		{src: "void f(unsigned x, unsigned char b) { unsigned char c = (x & 0xF) | b; }", want: nil},
+		{src: "void f(unsigned x) { unsigned char c = x & 0x1FF; }", want: []string{"1 integer-narrowing"}},
+		{src: "void f(int x) { signed char c = x & 0x7F; }", want: nil},
+		{src: "void f(int x) { signed char c = x & 0xFF; }", want: []string{"1 integer-narrowing"}},
+		{src: "void f(unsigned x) { unsigned char c = x | 0xFF; }", want: []string{"1 integer-narrowing"}},
+		// Sign comparisons.
+		{src: "int f(int x, unsigned u) { return x < u; }", want: []string{"1 integer-sign-compare"}},
+		{src: "int f(int x, unsigned short u) { return x < u; }", want: nil},
+		{src: "int f(unsigned u) { return u > 0; }", want: nil},
+		// Shift widths.
+		{src: "int f(int x) { return x << 32; }", want: []string{"1 integer-shift-width"}},
+		{src: "int f(int x) { return x >> -1; }", want: []string{"1 integer-shift-width"}},
+		{src: "int f(char c) { return c << 16; }", want: nil},
+		{src: "long long f(long long x) { return x << 32; }", want: nil},
+		// Allocation sizes.
+		{src: "void *malloc(unsigned long); void *f(unsigned long n) { return malloc(n * 4); }", want: []string{"1 integer-alloc-overflow"}},
+		{src: "void *malloc(unsigned long); void *f(void) { return malloc(4 * 8); }", want: nil},
+	}
+	for _, g := range golden {
+		checkDiags(t, &Integer{}, g.src, g.want)
+	}
+}
+
+func TestIntegerDisabled(t *testing.T) {
+	const src = "void f(int x) { char c = x; }"
+	c := &Integer{Config: &IntegerConfig{Disabled: []string{"integer-narrowing"}}}
+	checkDiags(t, c, src, nil)
+}
@@ -0,0 +1,424 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/callgraph"
+	"github.com/mewspring/cc/cfg"
+	"github.com/mewspring/cc/pointsto"
+)
+
+// MisraConfig is the configuration of the MISRA C checker.
+type MisraConfig struct {
+	// Enabled rules (e.g. "15.1"); or empty to enable all supported rules.
+	Rules []string `json:"rules"`
+}
+
+// Misra is a checker which reports violations of a decidable subset of the
+// MISRA C:2012 rules.
+//
+// Supported rules:
+//
+//   - 10.3: the value of an expression shall not be assigned to an object of a
+//     narrower essential type or of a different essential type category
+//   - 15.1: the goto statement should not be used
+//   - 15.5: a function should have a single point of exit at the end
+//   - 15.6: the body of an iteration or selection statement shall be a
+//     compound statement
+//   - 17.2: functions shall not call themselves, either directly or
+//     indirectly
+//
+// Rule 10.3 is checked for assignments, compound assignments, initializers,
+// return statements and function arguments. Assignments of literal constants
+// are not reported. Enumeration constants of named enums have the essential
+// type of their enum.
+type Misra struct {
+	// Checker configuration; or nil to enable all supported rules.
+	Config *MisraConfig
+}
+
+// Name returns the name of the checker.
+func (c *Misra) Name() string {
+	return "misra"
+}
+
+// misraRules maps from MISRA C:2012 rule number to the function checking the
+// rule.
+var misraRules = map[string]func(files []*cc.File) []*Diagnostic{
+	"10.3": checkMisraAssignment,
+	"15.1": checkMisraGoto,
+	"15.5": checkMisraSingleExit,
+	"15.6": checkMisraCompoundBody,
+	"17.2": checkMisraRecursion,
+}
+
+// misraRuleOrder is the order in which MISRA C rules are checked.
+var misraRuleOrder = []string{"10.3", "15.1", "15.5", "15.6", "17.2"}
+
+// Check checks the given translation units, returning the diagnostics.
+func (c *Misra) Check(files []*cc.File) []*Diagnostic {
+	rules := misraRuleOrder
+	if c.Config != nil && len(c.Config.Rules) > 0 {
+		rules = c.Config.Rules
+	}
+	var diags []*Diagnostic
+	for _, rule := range rules {
+		check, ok := misraRules[rule]
+		if !ok {
+			d := &Diagnostic{Rule: "misra", Severity: SeverityError, Message: fmt.Sprintf("unsupported MISRA C:2012 rule %q", rule)}
+			diags = append(diags, d)
+			continue
+		}
+		diags = append(diags, check(files)...)
+	}
+	return diags
+}
+
+// misraDiagnostic returns a diagnostic of the given MISRA C:2012 rule.
+func misraDiagnostic(loc cc.Location, rule, msg string) *Diagnostic {
+	return &Diagnostic{
+		Loc:      loc,
+		Rule:     "misra-c2012-" + rule,
+		Severity: SeverityError,
+		Message:  msg,
+	}
+}
+
+// checkMisraGoto checks MISRA C:2012 rule 15.1; the goto statement should not
+// be used.
+func checkMisraGoto(files []*cc.File) []*Diagnostic {
+	var diags []*Diagnostic
+	walkUser(files, func(n *cc.Node) {
+		switch n.Body.Kind() {
+		case clang.Cursor_GotoStmt, clang.Cursor_IndirectGotoStmt:
+			diags = append(diags, misraDiagnostic(n.Loc, "15.1", "use of goto statement"))
+		}
+	})
+	return diags
+}
+
+// checkMisraSingleExit checks MISRA C:2012 rule 15.5; a function should have a
+// single point of exit at the end.
+func checkMisraSingleExit(files []*cc.File) []*Diagnostic {
+	var diags []*Diagnostic
+	walkFuncDefs(files, func(fn *cc.Node) {
+		if len(fn.Children) == 0 {
+			return
+		}
+		var last *cc.Node
+		if body := fn.Children[len(fn.Children)-1]; body.Body.Kind() == clang.Cursor_CompoundStmt && len(body.Children) > 0 {
+			last = body.Children[len(body.Children)-1]
+		}
+		cc.Walk(fn, func(n *cc.Node) {
+			if n.Body.Kind() == clang.Cursor_ReturnStmt && n != last {
+				msg := fmt.Sprintf("function %s has a point of exit which is not at the end of the function", fn.Body.Spelling())
+				diags = append(diags, misraDiagnostic(n.Loc, "15.5", msg))
+			}
+		})
+	})
+	return diags
+}
+
+// checkMisraCompoundBody checks MISRA C:2012 rule 15.6; the body of an
+// iteration or selection statement shall be a compound statement. The else
+// branch of an if statement may be another if statement.
+func checkMisraCompoundBody(files []*cc.File) []*Diagnostic {
+	var diags []*Diagnostic
+	check := func(body *cc.Node, desc string) {
+		if body == nil || body.Body.Kind() == clang.Cursor_CompoundStmt {
+			return
+		}
+		msg := fmt.Sprintf("%s is not a compound statement", desc)
+		diags = append(diags, misraDiagnostic(body.Loc, "15.6", msg))
+	}
+	walkUser(files, func(n *cc.Node) {
+		switch n.Body.Kind() {
+		case clang.Cursor_IfStmt:
+			if len(n.Children) >= 2 {
+				check(n.Children[1], "body of if statement")
+			}
+			if len(n.Children) >= 3 && n.Children[2].Body.Kind() != clang.Cursor_IfStmt {
+				check(n.Children[2], "else branch of if statement")
+			}
+		case clang.Cursor_WhileStmt:
+			if len(n.Children) >= 2 {
+				check(n.Children[1], "body of while statement")
+			}
+		case clang.Cursor_DoStmt:
+			if len(n.Children) >= 1 {
+				check(n.Children[0], "body of do statement")
+			}
+		case clang.Cursor_SwitchStmt:
+			if len(n.Children) >= 2 {
+				check(n.Children[1], "body of switch statement")
+			}
+		case clang.Cursor_ForStmt:
+			_, _, _, body := cfg.ForParts(n)
+			check(body, "body of for statement")
+		}
+	})
+	return diags
+}
+
+// checkMisraRecursion checks MISRA C:2012 rule 17.2; functions shall not call
+// themselves, either directly or indirectly. The targets of calls through
+// function pointers are resolved by points-to analysis.
+func checkMisraRecursion(files []*cc.File) []*Diagnostic {
+	g := callgraph.New(files...)
+	pointsto.Analyze(files...).ResolveCalls(g)
+	var diags []*Diagnostic
+	for _, scc := range g.Recursive() {
+		inSCC := make(map[*callgraph.Func]bool)
+		for _, f := range scc {
+			inSCC[f] = true
+		}
+		for _, f := range scc {
+			if f.Def == nil || f.Def.Body.Location().IsInSystemHeader() {
+				continue
+			}
+			msg := fmt.Sprintf("function %s is recursive", f.Name)
+			if len(scc) > 1 {
+				msg = fmt.Sprintf("function %s is mutually recursive with %d other functions", f.Name, len(scc)-1)
+			}
+			d := misraDiagnostic(f.Def.Loc, "17.2", msg)
+			for _, call := range f.Callees {
+				if inSCC[call.Callee] {
+					d.Trace = append(d.Trace, &Note{Loc: call.Site.Loc, Message: fmt.Sprintf("call to %s", call.Callee.Name)})
+				}
+			}
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
+
+// checkMisraAssignment checks MISRA C:2012 rule 10.3; the value of an
+// expression shall not be assigned to an object with a narrower essential type
+// or of a different essential type category.
+func checkMisraAssignment(files []*cc.File) []*Diagnostic {
+	var diags []*Diagnostic
+	check := func(dst clang.Type, src *cc.Node, context string) {
+		if msg, ok := checkEssentialAssign(dst, src); !ok {
+			diags = append(diags, misraDiagnostic(src.Loc, "10.3", fmt.Sprintf("%s in %s", msg, context)))
+		}
+	}
+	walkFuncDefs(files, func(fn *cc.Node) {
+		cc.Walk(fn, func(n *cc.Node) {
+			switch n.Body.Kind() {
+			case clang.Cursor_BinaryOperator:
+				if n.Operator() == "=" && len(n.Children) == 2 {
+					check(n.Children[0].Body.Type(), n.Children[1], "assignment")
+				}
+			case clang.Cursor_CompoundAssignOperator:
+				// E1 op= E2 is checked as E1 = E1 op E2.
+				if len(n.Children) != 2 || isConstantLiteral(n.Children[1]) {
+					return
+				}
+				dst, src := n.Children[0], n.Children[1]
+				op := strings.TrimSuffix(n.Operator(), "=")
+				s, ok := binaryEssential(op, exprEssential(dst), exprEssential(src))
+				if !ok {
+					// Operands of different essential type categories.
+					s = exprEssential(src)
+				}
+				if msg, ok := checkEssential(dst.Body.Type(), s); !ok {
+					diags = append(diags, misraDiagnostic(src.Loc, "10.3", fmt.Sprintf("%s in compound assignment", msg)))
+				}
+			case clang.Cursor_VarDecl:
+				if len(n.Children) == 0 {
+					return
+				}
+				init := n.Children[len(n.Children)-1]
+				if init.Body.Kind().IsExpression() && init.Body.Kind() != clang.Cursor_InitListExpr {
+					check(n.Body.Type(), init, "initialization")
+				}
+			case clang.Cursor_ReturnStmt:
+				if len(n.Children) == 1 {
+					check(fn.Body.ResultType(), n.Children[0], "return statement")
+				}
+			case clang.Cursor_CallExpr:
+				callee := n.Body.Referenced()
+				if callee.Kind() != clang.Cursor_FunctionDecl || len(n.Children) == 0 {
+					return
+				}
+				// Only arguments of parameters with prototypes are checked.
+				t := callee.Type().CanonicalType()
+				for i, arg := range n.Children[1:] {
+					if i >= int(t.NumArgTypes()) {
+						break
+					}
+					check(t.ArgType(uint32(i)), arg, fmt.Sprintf("argument %d of call to %s", i+1, callee.Spelling()))
+				}
+			}
+		})
+	})
+	return diags
+}
+
+// Essential type categories of MISRA C:2012.
+const (
+	essentialBoolean   = "boolean"
+	essentialCharacter = "character"
+	essentialSigned    = "signed"
+	essentialUnsigned  = "unsigned"
+	essentialEnum      = "enum"
+	essentialFloating  = "floating"
+)
+
+// essentialType is the essential type of an expression.
+type essentialType struct {
+	// Essential type category; or empty if not an arithmetic type.
+	category string
+	// Size in bytes; or negative if unknown.
+	size int64
+	// Type name.
+	name string
+	// USR of the enum declaration of enum types.
+	enum string
+}
+
+// typeEssential returns the essential type of the given type.
+func typeEssential(t clang.Type) essentialType {
+	canon := t.CanonicalType()
+	et := essentialType{name: t.Spelling()}
+	et.size = canon.SizeOf()
+	switch canon.Kind() {
+	case clang.Type_Bool:
+		et.category = essentialBoolean
+	case clang.Type_Char_S, clang.Type_Char_U:
+		et.category = essentialCharacter
+	case clang.Type_SChar, clang.Type_Short, clang.Type_Int, clang.Type_Long, clang.Type_LongLong, clang.Type_Int128:
+		et.category = essentialSigned
+	case clang.Type_UChar, clang.Type_UShort, clang.Type_UInt, clang.Type_ULong, clang.Type_ULongLong, clang.Type_UInt128:
+		et.category = essentialUnsigned
+	case clang.Type_Enum:
+		et.category = essentialEnum
+		et.enum = canon.Declaration().USR()
+	case clang.Type_Float, clang.Type_Double, clang.Type_LongDouble:
+		et.category = essentialFloating
+	}
+	return et
+}
+
+// exprEssential returns the essential type of the given expression. Unlike its
+// C type, the essential type of an arithmetic expression is not subject to
+// integer promotion.
+func exprEssential(n *cc.Node) essentialType {
+	n = stripImplicit(n)
+	switch n.Body.Kind() {
+	case clang.Cursor_BinaryOperator:
+		if len(n.Children) != 2 {
+			break
+		}
+		switch op := n.Operator(); op {
+		case "==", "!=", "<", ">", "<=", ">=", "&&", "||":
+			return essentialType{category: essentialBoolean, size: 1, name: "_Bool"}
+		default:
+			if et, ok := binaryEssential(op, exprEssential(n.Children[0]), exprEssential(n.Children[1])); ok {
+				return et
+			}
+		}
+	case clang.Cursor_DeclRefExpr:
+		// The essential type of an enumeration constant of a named enum is the
+		// enum; the constants of anonymous enums are essentially signed.
+		ref := n.Body.Referenced()
+		if ref.Kind() == clang.Cursor_EnumConstantDecl {
+			if decl := ref.SemanticParent(); len(decl.Spelling()) > 0 {
+				return typeEssential(decl.Type())
+			}
+		}
+	case clang.Cursor_UnaryOperator:
+		if len(n.Children) != 1 {
+			break
+		}
+		switch n.Operator() {
+		case "!":
+			return essentialType{category: essentialBoolean, size: 1, name: "_Bool"}
+		case "-", "+", "~":
+			if x := exprEssential(n.Children[0]); x.category == essentialSigned || x.category == essentialUnsigned {
+				return x
+			}
+		}
+	case clang.Cursor_ConditionalOperator:
+		if len(n.Children) != 3 {
+			break
+		}
+		x, y := exprEssential(n.Children[1]), exprEssential(n.Children[2])
+		if x.category == y.category {
+			if y.size > x.size {
+				return y
+			}
+			return x
+		}
+	}
+	return typeEssential(n.Body.Type())
+}
+
+// binaryEssential returns the essential type of a binary arithmetic, bitwise or
+// shift operation with the given operator and operands of the given essential
+// types. The essential type of operations on operands of different essential
+// type categories is not defined.
+func binaryEssential(op string, x, y essentialType) (essentialType, bool) {
+	switch op {
+	case "<<", ">>":
+		return x, true
+	case "+", "-", "*", "/", "%", "&", "|", "^":
+		if x.category != y.category || (x.category != essentialSigned && x.category != essentialUnsigned) {
+			return essentialType{}, false
+		}
+		if y.size > x.size {
+			return y, true
+		}
+		return x, true
+	}
+	return essentialType{}, false
+}
+
+// checkEssentialAssign checks the assignment of the given expression to an
+// object of the given type, returning a description of the violation if the
+// expression has a narrower essential type or a different essential type
+// category.
+func checkEssentialAssign(dst clang.Type, src *cc.Node) (string, bool) {
+	if isConstantLiteral(src) {
+		return "", true
+	}
+	return checkEssential(dst, exprEssential(src))
+}
+
+// checkEssential checks the assignment of an expression of the given essential
+// type to an object of the given type, returning a description of the
+// violation if the expression has a narrower essential type or a different
+// essential type category.
+func checkEssential(dst clang.Type, s essentialType) (string, bool) {
+	d := typeEssential(dst)
+	if len(d.category) == 0 || len(s.category) == 0 {
+		return "", true
+	}
+	if d.category != s.category {
+		return fmt.Sprintf("%s expression of type %s assigned to %s type %s", s.category, s.name, d.category, d.name), false
+	}
+	if d.category == essentialEnum && d.enum != s.enum {
+		return fmt.Sprintf("expression of enum type %s assigned to different enum type %s", s.name, d.name), false
+	}
+	if d.size > 0 && s.size > d.size {
+		return fmt.Sprintf("expression of type %s assigned to narrower type %s", s.name, d.name), false
+	}
+	return "", true
+}
+
+// isConstantLiteral reports whether the given expression is an integer,
+// character or floating-point literal, optionally negated.
+func isConstantLiteral(n *cc.Node) bool {
+	n = stripImplicit(n)
+	if n.Body.Kind() == clang.Cursor_UnaryOperator && len(n.Children) == 1 && n.Operator() == "-" {
+		n = stripImplicit(n.Children[0])
+	}
+	switch n.Body.Kind() {
+	case clang.Cursor_IntegerLiteral, clang.Cursor_CharacterLiteral, clang.Cursor_FloatingLiteral:
+		return true
+	}
+	return false
+}
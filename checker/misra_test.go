@@ -0,0 +1,83 @@
+package checker
+
+import "testing"
+
+func TestMisra(t *testing.T) {
+	const src = `
+typedef unsigned char u8;
+typedef int s32;
+typedef unsigned int u32;
+enum color { RED, GREEN };
+enum shape { SQUARE };
+enum { ANON = 3 };
+int g(int);
+void f(u8 a, s32 b, u32 c) {
+	enum color col = RED;
+	enum color bad = SQUARE;
+	s32 i = RED;
+	s32 j = ANON;
+	a += b;
+	a += 1;
+	c += a;
+	a += c;
+	a = c;
+	b = g(b);
+	col = GREEN;
+}
+int h(int x) {
+	if (x)
+		return 1;
+l:
+	x--;
+	if (x > 0) {
+		goto l;
+	}
+	return h(x);
+}
+`
+	golden := []struct {
+		rules []string
+		want  []string
+	}{
+		{
+			want: []string{
+				"11 misra-c2012-10.3",
+				"12 misra-c2012-10.3",
+				"14 misra-c2012-10.3",
+				"17 misra-c2012-10.3",
+				"18 misra-c2012-10.3",
+				"22 misra-c2012-17.2",
+				"24 misra-c2012-15.5",
+				"24 misra-c2012-15.6",
+				"28 misra-c2012-15.1",
+			},
+		},
+		{
+			rules: []string{"15.1"},
+			want:  []string{"28 misra-c2012-15.1"},
+		},
+		{
+			rules: []string{"1.1"},
+			want:  []string{"0 misra"},
+		},
+	}
+	for _, g := range golden {
+		checkDiags(t, &Misra{Config: &MisraConfig{Rules: g.rules}}, src, g.want)
+	}
+}
+
+func TestMisraIndirectRecursion(t *testing.T) {
+	const src = `
+void rec(int x);
+static void (*handler)(int);
+void setup(void) { handler = rec; }
+void rec(int x) {
+	if (x > 0) {
+		handler(x - 1);
+	}
+}
+`
+	// The call through handler is resolved to rec by points-to analysis.
+	want := []string{"5 misra-c2012-17.2"}
+	checkDiags(t, &Misra{Config: &MisraConfig{Rules: []string{"17.2"}}}, src, want)
+}
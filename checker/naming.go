@@ -0,0 +1,344 @@
+package checker
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/index"
+	"github.com/mewspring/cc/rewrite"
+	"github.com/pkg/errors"
+)
+
+// NamingConfig is the configuration of the naming convention checker.
+type NamingConfig struct {
+	// Naming rules per declaration category.
+	Rules []*NamingRule `json:"rules"`
+	// Prefix requirements per directory.
+	Prefixes []*PrefixRule `json:"prefixes"`
+}
+
+// NamingRule is a naming rule of a declaration category.
+//
+// Declaration categories:
+//
+//   - function: functions with external linkage (and static functions, unless
+//     a static_function rule is present)
+//   - static_function: functions with internal linkage
+//   - global: global and static variables declared at file scope
+//   - local: local variables
+//   - parameter: function parameters
+//   - macro: macros
+//   - type: struct, union and enum tags and type definitions
+//   - enum_constant: enum constants
+//   - field: struct and union fields
+type NamingRule struct {
+	// Declaration category.
+	Category string `json:"category"`
+	// Case style ("snake_case", "UPPER_CASE", "camelCase" or "PascalCase");
+	// or empty to not check the case style.
+	Style string `json:"style"`
+	// Regular expression names must match; or empty to not check names
+	// against a regular expression.
+	Pattern string `json:"pattern"`
+}
+
+// PrefixRule is a name prefix requirement of the declarations of a directory.
+type PrefixRule struct {
+	// Directory of source files.
+	Dir string `json:"dir"`
+	// Required name prefix, compared case-insensitively.
+	Prefix string `json:"prefix"`
+	// Declaration categories of the requirement; or empty for functions,
+	// globals, macros and types.
+	Categories []string `json:"categories"`
+}
+
+// Naming is a checker which reports declarations violating the naming
+// conventions of a project. Names violating a case style or prefix
+// requirement may be fixed automatically.
+type Naming struct {
+	// Checker configuration; or nil for no naming rules.
+	Config *NamingConfig
+}
+
+// Name returns the name of the checker.
+func (c *Naming) Name() string {
+	return "naming"
+}
+
+// namingViolation is a symbol violating the naming conventions.
+type namingViolation struct {
+	// Symbol of the declaration.
+	sym *index.Symbol
+	// Diagnostic of the violation.
+	diag *Diagnostic
+	// Suggested name; or empty if not fixable.
+	fix string
+}
+
+// Check checks the given translation units, returning the diagnostics.
+func (c *Naming) Check(files []*cc.File) []*Diagnostic {
+	violations, err := c.violations(index.New(files...))
+	if err != nil {
+		d := &Diagnostic{Rule: "naming", Severity: SeverityError, Message: err.Error()}
+		return []*Diagnostic{d}
+	}
+	var diags []*Diagnostic
+	for _, v := range violations {
+		diags = append(diags, v.diag)
+	}
+	return diags
+}
+
+// Fix returns a rewriter renaming the declarations and references of symbols
+// violating the case style or prefix requirements of the naming conventions.
+// Symbols with occurrences which cannot be rewritten (e.g. names formed within
+// macro expansions) are left unfixed.
+func (c *Naming) Fix(files []*cc.File) (*rewrite.Rewriter, error) {
+	violations, err := c.violations(index.New(files...))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r := rewrite.New()
+	for _, v := range violations {
+		if len(v.fix) == 0 {
+			continue
+		}
+		// Verify that all occurrences of the symbol can be rewritten.
+		sr := rewrite.New()
+		if err := sr.Rename(v.sym, v.fix); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		skipped, err := sr.Skipped()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if len(skipped) > 0 {
+			continue
+		}
+		if err := r.Rename(v.sym, v.fix); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return r, nil
+}
+
+// violations returns the symbols of the given index violating the naming
+// conventions, sorted by name.
+func (c *Naming) violations(idx *index.Index) ([]*namingViolation, error) {
+	if c.Config == nil {
+		return nil, nil
+	}
+	rules := make(map[string]*NamingRule)
+	patterns := make(map[string]*regexp.Regexp)
+	for _, rule := range c.Config.Rules {
+		rules[rule.Category] = rule
+		if len(rule.Pattern) > 0 {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid pattern of %s naming rule", rule.Category)
+			}
+			patterns[rule.Category] = re
+		}
+		if len(rule.Style) > 0 && styleRegexps[rule.Style] == nil {
+			return nil, errors.Errorf("invalid case style %q of %s naming rule", rule.Style, rule.Category)
+		}
+	}
+	var violations []*namingViolation
+	// Names proposed by fixes of symbols.
+	renamed := make(map[string]bool)
+	for _, sym := range idx.Sorted() {
+		if len(sym.Name) == 0 || len(sym.Decls) == 0 {
+			continue
+		}
+		decl := sym.Decls[0].Node
+		if defs := sym.Defs(); len(defs) > 0 {
+			decl = defs[0].Node
+		}
+		if inSystemHeader(sym) || decl.Body.IsMacroBuiltin() || sym.Name == "main" {
+			continue
+		}
+		category := namingCategory(decl)
+		if len(category) == 0 {
+			continue
+		}
+		ruleCategory := category
+		if _, ok := rules[ruleCategory]; !ok && category == "static_function" {
+			ruleCategory = "function"
+		}
+		rule := rules[ruleCategory]
+		var problems []string
+		fix := sym.Name
+		if prefix, ok := c.requiredPrefix(decl.Loc.File, category); ok && !strings.HasPrefix(strings.ToLower(sym.Name), strings.ToLower(prefix)) {
+			problems = append(problems, fmt.Sprintf("missing prefix %q", prefix))
+			fix = prefix + fix
+		}
+		if rule != nil && len(rule.Style) > 0 {
+			fix = toStyle(fix, rule.Style)
+			if !styleRegexps[rule.Style].MatchString(strings.TrimLeft(sym.Name, "_")) {
+				problems = append(problems, fmt.Sprintf("not in %s", rule.Style))
+			}
+		}
+		if re, ok := patterns[ruleCategory]; ok && !re.MatchString(sym.Name) {
+			problems = append(problems, fmt.Sprintf("does not match %q", rule.Pattern))
+			if !re.MatchString(fix) {
+				fix = ""
+			}
+		}
+		if len(problems) == 0 {
+			continue
+		}
+		if fix == sym.Name || len(idx.Lookup(fix)) > 0 || renamed[fix] {
+			// Not fixable, or renaming would conflict with an existing symbol
+			// or with the fix of another symbol.
+			fix = ""
+		}
+		if len(fix) > 0 {
+			renamed[fix] = true
+		}
+		d := &Diagnostic{
+			Loc:      decl.Loc,
+			Rule:     "naming",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("%s name %s violates naming convention: %s", strings.Replace(category, "_", " ", -1), sym.Name, strings.Join(problems, ", ")),
+		}
+		if len(fix) > 0 {
+			d.Suggestion = fmt.Sprintf("rename to %s", fix)
+		}
+		violations = append(violations, &namingViolation{sym: sym, diag: d, fix: fix})
+	}
+	return violations, nil
+}
+
+// requiredPrefix returns the name prefix required of declarations of the given
+// category in the given source file.
+func (c *Naming) requiredPrefix(path, category string) (string, bool) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	for _, p := range c.Config.Prefixes {
+		dir, err := filepath.Abs(p.Dir)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(dir, absPath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		categories := p.Categories
+		if len(categories) == 0 {
+			categories = []string{"function", "global", "macro", "type"}
+		}
+		for _, cat := range categories {
+			if cat == category || (cat == "function" && category == "static_function") {
+				return p.Prefix, true
+			}
+		}
+	}
+	return "", false
+}
+
+// inSystemHeader reports whether a declaration of the given symbol is located
+// in a system header.
+func inSystemHeader(sym *index.Symbol) bool {
+	for _, decl := range sym.Decls {
+		if decl.Node.Body.Location().IsInSystemHeader() {
+			return true
+		}
+	}
+	return false
+}
+
+// namingCategory returns the naming rule category of the given declaration; or
+// an empty string if not covered by naming rules.
+func namingCategory(decl *cc.Node) string {
+	switch decl.Body.Kind() {
+	case clang.Cursor_FunctionDecl:
+		if decl.Linkage() == cc.LinkageInternal {
+			return "static_function"
+		}
+		return "function"
+	case clang.Cursor_VarDecl:
+		if decl.Body.SemanticParent().Kind() == clang.Cursor_TranslationUnit {
+			return "global"
+		}
+		return "local"
+	case clang.Cursor_ParmDecl:
+		return "parameter"
+	case clang.Cursor_MacroDefinition:
+		return "macro"
+	case clang.Cursor_StructDecl, clang.Cursor_UnionDecl, clang.Cursor_EnumDecl, clang.Cursor_TypedefDecl:
+		return "type"
+	case clang.Cursor_EnumConstantDecl:
+		return "enum_constant"
+	case clang.Cursor_FieldDecl:
+		return "field"
+	}
+	return ""
+}
+
+// styleRegexps maps from case style to a regular expression matching names of
+// the case style, excluding leading underscores.
+var styleRegexps = map[string]*regexp.Regexp{
+	"snake_case": regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`),
+	"UPPER_CASE": regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`),
+	"camelCase":  regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`),
+	"PascalCase": regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`),
+}
+
+// toStyle converts the given name to the given case style, preserving leading
+// underscores.
+func toStyle(name, style string) string {
+	trimmed := strings.TrimLeft(name, "_")
+	leading := name[:len(name)-len(trimmed)]
+	words := splitWords(trimmed)
+	for i, word := range words {
+		switch style {
+		case "snake_case":
+			words[i] = strings.ToLower(word)
+		case "UPPER_CASE":
+			words[i] = strings.ToUpper(word)
+		case "camelCase", "PascalCase":
+			word = strings.ToLower(word)
+			if i > 0 || style == "PascalCase" {
+				word = strings.ToUpper(word[:1]) + word[1:]
+			}
+			words[i] = word
+		}
+	}
+	switch style {
+	case "snake_case", "UPPER_CASE":
+		return leading + strings.Join(words, "_")
+	}
+	return leading + strings.Join(words, "")
+}
+
+// splitWords splits the given name into words, separated by underscores and
+// case transitions (e.g. "parseHTTPHeader" -> "parse", "HTTP", "Header").
+func splitWords(name string) []string {
+	var words []string
+	for _, part := range strings.Split(name, "_") {
+		rs := []rune(part)
+		start := 0
+		for i := 1; i < len(rs); i++ {
+			lowerToUpper := unicode.IsLower(rs[i-1]) && unicode.IsUpper(rs[i])
+			digitToLetter := unicode.IsDigit(rs[i-1]) && unicode.IsUpper(rs[i])
+			// End of acronym; e.g. "HTTPHeader".
+			acronymEnd := i+1 < len(rs) && unicode.IsUpper(rs[i-1]) && unicode.IsUpper(rs[i]) && unicode.IsLower(rs[i+1])
+			if lowerToUpper || digitToLetter || acronymEnd {
+				words = append(words, string(rs[start:i]))
+				start = i
+			}
+		}
+		if start < len(rs) {
+			words = append(words, string(rs[start:]))
+		}
+	}
+	return words
+}
@@ -0,0 +1,136 @@
+package checker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+func TestSplitWords(t *testing.T) {
+	golden := []struct {
+		name string
+		want string
+	}{
+		{name: "parseHTTPHeader", want: "parse HTTP Header"},
+		{name: "parse_http_header", want: "parse http header"},
+		{name: "ParseHeader2D", want: "Parse Header2 D"},
+		{name: "x", want: "x"},
+	}
+	for _, g := range golden {
+		if got := strings.Join(splitWords(g.name), " "); got != g.want {
+			t.Errorf("%q: words mismatch; expected %q, got %q", g.name, g.want, got)
+		}
+	}
+}
+
+func TestToStyle(t *testing.T) {
+	golden := []struct {
+		name  string
+		style string
+		want  string
+	}{
+		{name: "parseHTTPHeader", style: "snake_case", want: "parse_http_header"},
+		{name: "parse_http_header", style: "camelCase", want: "parseHttpHeader"},
+		{name: "parse_http_header", style: "PascalCase", want: "ParseHttpHeader"},
+		{name: "maxSize", style: "UPPER_CASE", want: "MAX_SIZE"},
+		{name: "__fooBar", style: "snake_case", want: "__foo_bar"},
+	}
+	for _, g := range golden {
+		if got := toStyle(g.name, g.style); got != g.want {
+			t.Errorf("%q: %s mismatch; expected %q, got %q", g.name, g.style, g.want, got)
+		}
+	}
+}
+
+func TestNaming(t *testing.T) {
+	const src = `
+int FooBar;
+int fooBar;
+int myVar;
+int my_var;
+int good_name;
+`
+	config := &NamingConfig{
+		Rules: []*NamingRule{
+			{Category: "global", Style: "snake_case"},
+		},
+	}
+	file, err := cc.ParseSource("input.c", src)
+	if err != nil {
+		t.Fatalf("unable to parse source; %+v", err)
+	}
+	defer file.Close()
+	golden := []struct {
+		name string
+		// Suggested fix; or empty if not fixable.
+		fix string
+	}{
+		// Renamed to foo_bar.
+		{name: "FooBar", fix: "rename to foo_bar"},
+		// Conflicts with the fix of FooBar.
+		{name: "fooBar"},
+		// Conflicts with my_var.
+		{name: "myVar"},
+	}
+	diags := (&Naming{Config: config}).Check([]*cc.File{file})
+	if len(diags) != len(golden) {
+		t.Fatalf("number of diagnostics mismatch; expected %d, got %d", len(golden), len(diags))
+	}
+	for i, want := range golden {
+		d := diags[i]
+		if !strings.Contains(d.Message, " "+want.name+" ") {
+			t.Errorf("diagnostic %d: message mismatch; expected name %q, got %q", i, want.name, d.Message)
+		}
+		if d.Suggestion != want.fix {
+			t.Errorf("%q: suggestion mismatch; expected %q, got %q", want.name, want.fix, d.Suggestion)
+		}
+	}
+}
+
+func TestNamingFix(t *testing.T) {
+	const src = `#define DECL(name) int name##Var
+DECL(Bad);
+int FooBar;
+int get(void) { return FooBar + BadVar; }
+`
+	config := &NamingConfig{
+		Rules: []*NamingRule{
+			{Category: "global", Style: "snake_case"},
+		},
+	}
+	dir, err := ioutil.TempDir("", "naming")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "input.c")
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatalf("unable to write source file; %+v", err)
+	}
+	file, err := cc.ParseFile(path)
+	if err != nil {
+		t.Fatalf("unable to parse source; %+v", err)
+	}
+	defer file.Close()
+	r, err := (&Naming{Config: config}).Fix([]*cc.File{file})
+	if err != nil {
+		t.Fatalf("unable to fix naming violations; %+v", err)
+	}
+	files, err := r.Files()
+	if err != nil {
+		t.Fatalf("unable to rewrite files; %+v", err)
+	}
+	// BadVar is declared within a macro expansion and left unfixed.
+	const want = `#define DECL(name) int name##Var
+DECL(Bad);
+int foo_bar;
+int get(void) { return foo_bar + BadVar; }
+`
+	if got := string(files[path]); got != want {
+		t.Errorf("rewritten source mismatch; expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,499 @@
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/cfg"
+)
+
+// ResourceConfig is the configuration of the resource pairing checker.
+type ResourceConfig struct {
+	// Acquire and release function pairs.
+	Pairs []*ResourcePair `json:"pairs"`
+}
+
+// ResourcePair is a pair of functions acquiring and releasing a resource.
+type ResourcePair struct {
+	// Resource kind (e.g. "file", "lock").
+	Name string `json:"name"`
+	// Functions acquiring the resource.
+	Acquire []string `json:"acquire"`
+	// Functions releasing the resource, passed as first argument.
+	Release []string `json:"release"`
+	// Functions reallocating the resource passed as first argument, returning
+	// the new resource (e.g. realloc). Ownership is transferred from the
+	// argument to the result, unless null is returned. Only used if Returned
+	// is set.
+	Realloc []string `json:"realloc"`
+	// The resource is returned by acquire functions (e.g. fopen); otherwise
+	// the resource is the first argument of acquire functions (e.g.
+	// pthread_mutex_lock).
+	Returned bool `json:"returned"`
+}
+
+// DefaultResourceConfig is the default configuration of the resource pairing
+// checker.
+var DefaultResourceConfig = &ResourceConfig{
+	Pairs: []*ResourcePair{
+		{Name: "file", Acquire: []string{"fopen", "fdopen", "tmpfile"}, Release: []string{"fclose"}, Returned: true},
+		{Name: "pipe", Acquire: []string{"popen"}, Release: []string{"pclose"}, Returned: true},
+		{Name: "directory", Acquire: []string{"opendir", "fdopendir"}, Release: []string{"closedir"}, Returned: true},
+		{Name: "file descriptor", Acquire: []string{"open", "openat", "creat", "socket", "accept", "dup"}, Release: []string{"close"}, Returned: true},
+		{Name: "memory", Acquire: []string{"malloc", "calloc", "strdup", "strndup"}, Release: []string{"free"}, Realloc: []string{"realloc"}, Returned: true},
+		{Name: "lock", Acquire: []string{"pthread_mutex_lock"}, Release: []string{"pthread_mutex_unlock"}},
+		{Name: "lock", Acquire: []string{"pthread_rwlock_rdlock", "pthread_rwlock_wrlock"}, Release: []string{"pthread_rwlock_unlock"}},
+		{Name: "lock", Acquire: []string{"pthread_spin_lock"}, Release: []string{"pthread_spin_unlock"}},
+	},
+}
+
+// Resource is a checker which reports resources acquired but not released on
+// all paths through a function, based on the control flow graph of the
+// function.
+//
+// The analysis is path-sensitive with respect to null checks of acquired
+// resources (e.g. "if (fp == NULL)"), but otherwise explores all paths of the
+// control flow graph. Returned resources are tracked through local variables;
+// resources which escape the function (by being returned, stored outside a
+// local variable or having their address taken) are no longer tracked.
+type Resource struct {
+	// Checker configuration; or nil to use DefaultResourceConfig.
+	Config *ResourceConfig
+}
+
+// Name returns the name of the checker.
+func (c *Resource) Name() string {
+	return "resource"
+}
+
+// maxResourceStates is the maximum number of states explored per function.
+const maxResourceStates = 10000
+
+// Check checks the given translation units, returning the diagnostics.
+func (c *Resource) Check(files []*cc.File) []*Diagnostic {
+	config := c.Config
+	if config == nil {
+		config = DefaultResourceConfig
+	}
+	ra := &resourceAnalysis{
+		acquire: make(map[string]*ResourcePair),
+		release: make(map[string][]*ResourcePair),
+		realloc: make(map[string]*ResourcePair),
+	}
+	for _, pair := range config.Pairs {
+		for _, name := range pair.Acquire {
+			ra.acquire[name] = pair
+		}
+		for _, name := range pair.Release {
+			ra.release[name] = append(ra.release[name], pair)
+		}
+		if pair.Returned {
+			for _, name := range pair.Realloc {
+				ra.realloc[name] = pair
+			}
+		}
+	}
+	var diags []*Diagnostic
+	walkFuncDefs(files, func(fn *cc.Node) {
+		g := cfg.New(fn)
+		if g == nil {
+			return
+		}
+		diags = append(diags, ra.check(g)...)
+	})
+	return diags
+}
+
+// resourceAnalysis tracks the state of the resource pairing checker.
+type resourceAnalysis struct {
+	// Resource pairs by acquire function name.
+	acquire map[string]*ResourcePair
+	// Resource pairs by release function name.
+	release map[string][]*ResourcePair
+	// Resource pairs by reallocation function name.
+	realloc map[string]*ResourcePair
+}
+
+// heldResource is a resource held on a path.
+type heldResource struct {
+	// Resource pair.
+	pair *ResourcePair
+	// Resource key; the USR of the local variable holding returned resources,
+	// or the key of the first argument of the acquire function (see argKey).
+	key string
+	// Resource description (e.g. variable name).
+	desc string
+	// Call acquiring the resource.
+	site *cc.Node
+	// Name of the acquire function.
+	acquire string
+	// Length of the path when the resource was acquired.
+	pathLen int
+	// Resource transferred to this resource by a reallocation function, which
+	// is still held if the reallocation fails; or nil if not reallocated.
+	prev *heldResource
+}
+
+// pathStep is a step of a path through a control flow graph.
+type pathStep struct {
+	// Basic block.
+	block *cfg.Block
+	// Index of the successor taken.
+	succ int
+}
+
+// check checks the given control flow graph for resources not released on all
+// paths.
+func (ra *resourceAnalysis) check(g *cfg.Graph) []*Diagnostic {
+	var diags []*Diagnostic
+	reported := make(map[string]bool)
+	visited := make(map[string]bool)
+	var dfs func(block *cfg.Block, held map[string]*heldResource, path []pathStep)
+	dfs = func(block *cfg.Block, held map[string]*heldResource, path []pathStep) {
+		key := stateKey(block, held)
+		if visited[key] || len(visited) >= maxResourceStates {
+			return
+		}
+		visited[key] = true
+		if block == g.Exit {
+			for _, h := range held {
+				siteKey := fmt.Sprintf("%s %s", h.site.Loc, h.key)
+				if reported[siteKey] {
+					continue
+				}
+				reported[siteKey] = true
+				diags = append(diags, leakDiagnostic(g, h, path))
+			}
+			return
+		}
+		held = copyHeld(held)
+		for _, n := range block.Nodes {
+			ra.transfer(n, held, len(path))
+		}
+		nullKey, nullOnTrue, isNullTest := "", false, false
+		if block.Cond != nil && len(block.Succs) == 2 {
+			nullKey, nullOnTrue, isNullTest = nullTest(block.Cond)
+		}
+		for i, succ := range block.Succs {
+			next := held
+			if isNullTest && (i == 0) == nullOnTrue {
+				if h, ok := held[nullKey]; ok {
+					// The resource was not acquired on this branch.
+					next = copyHeld(held)
+					delete(next, nullKey)
+					if h.prev != nil {
+						// Failed reallocation; the argument is still held.
+						next[h.prev.key] = h.prev
+					}
+				}
+			}
+			dfs(succ, next, append(path[:len(path):len(path)], pathStep{block: block, succ: i}))
+		}
+	}
+	dfs(g.Entry, nil, nil)
+	return diags
+}
+
+// transfer updates the held resources based on the given statement-level node.
+func (ra *resourceAnalysis) transfer(n *cc.Node, held map[string]*heldResource, pathLen int) {
+	acquireReturned := func(call *cc.Node, v clang.Cursor) {
+		name := call.Body.Referenced().Spelling()
+		var prev *heldResource
+		pair, ok := ra.acquire[name]
+		if !ok {
+			// v = realloc(p, ...);
+			if pair, ok = ra.realloc[name]; !ok {
+				return
+			}
+			prev = reallocated(call, pair, held)
+		}
+		if !pair.Returned {
+			return
+		}
+		held[v.USR()] = &heldResource{pair: pair, key: v.USR(), desc: v.Spelling(), site: call, acquire: name, pathLen: pathLen, prev: prev}
+	}
+	cc.Walk(n, func(n *cc.Node) {
+		switch n.Body.Kind() {
+		case clang.Cursor_VarDecl:
+			// T *v = acquire(...);
+			for _, child := range n.Children {
+				init := stripCasts(child)
+				if init.Body.Kind() == clang.Cursor_CallExpr && isLocalVar(n.Body) {
+					acquireReturned(init, n.Body)
+				}
+				if v, ok := heldVar(init, held); ok {
+					// Aliased by another variable.
+					delete(held, v)
+				}
+			}
+		case clang.Cursor_BinaryOperator:
+			if n.Operator() != "=" || len(n.Children) != 2 {
+				return
+			}
+			lhs, rhs := stripCasts(n.Children[0]), stripCasts(n.Children[1])
+			if rhs.Body.Kind() == clang.Cursor_CallExpr && lhs.Body.Kind() == clang.Cursor_DeclRefExpr && isLocalVar(lhs.Body.Referenced()) {
+				// v = acquire(...);
+				acquireReturned(rhs, lhs.Body.Referenced())
+			}
+			if v, ok := heldVar(rhs, held); ok {
+				// Stored outside of the variable.
+				delete(held, v)
+			}
+		case clang.Cursor_UnaryOperator:
+			if n.Operator() != "&" || len(n.Children) != 1 {
+				return
+			}
+			if v, ok := heldVar(stripCasts(n.Children[0]), held); ok {
+				// Address taken.
+				delete(held, v)
+			}
+		case clang.Cursor_ReturnStmt:
+			for _, child := range n.Children {
+				if v, ok := heldVar(stripCasts(child), held); ok {
+					// Returned to caller.
+					delete(held, v)
+				}
+			}
+		case clang.Cursor_CallExpr:
+			if len(n.Children) < 2 {
+				return
+			}
+			name := n.Body.Referenced().Spelling()
+			arg := stripCasts(n.Children[1])
+			if pair, ok := ra.realloc[name]; ok {
+				// Reallocation not assigned to a local variable; the argument
+				// is no longer tracked.
+				if h := reallocated(n, pair, held); h != nil {
+					delete(held, h.key)
+				}
+				return
+			}
+			if pair, ok := ra.acquire[name]; ok && !pair.Returned {
+				key := argKey(arg)
+				held[key] = &heldResource{pair: pair, key: key, desc: strings.Join(arg.Tokens(), ""), site: n, acquire: name, pathLen: pathLen}
+				return
+			}
+			for _, pair := range ra.release[name] {
+				key := argKey(arg)
+				if pair.Returned {
+					if arg.Body.Kind() != clang.Cursor_DeclRefExpr {
+						continue
+					}
+					key = arg.Body.Referenced().USR()
+				}
+				if h, ok := held[key]; ok && h.pair == pair {
+					delete(held, key)
+				}
+			}
+		}
+	})
+}
+
+// reallocated returns the held resource passed as first argument to the given
+// call of a reallocation function, removing it from the held resources; or nil
+// if the argument is not a held resource of the given pair.
+func reallocated(call *cc.Node, pair *ResourcePair, held map[string]*heldResource) *heldResource {
+	if len(call.Children) < 2 {
+		return nil
+	}
+	key, ok := heldVar(stripCasts(call.Children[1]), held)
+	if !ok {
+		return nil
+	}
+	h := held[key]
+	if h.pair != pair || h.site == call {
+		// Resource acquired by the call itself (e.g. "p = realloc(p, n)").
+		return nil
+	}
+	delete(held, key)
+	return h
+}
+
+// leakDiagnostic returns a diagnostic of the given resource not released on the
+// given path to the exit block of the control flow graph.
+func leakDiagnostic(g *cfg.Graph, h *heldResource, path []pathStep) *Diagnostic {
+	d := &Diagnostic{
+		Loc:      h.site.Loc,
+		Rule:     "resource-leak",
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf("%s %s acquired by %s is not released on all paths", h.pair.Name, h.desc, h.acquire),
+	}
+	d.Trace = append(d.Trace, &Note{Loc: h.site.Loc, Message: fmt.Sprintf("%s acquired by %s", h.desc, h.acquire)})
+	for _, step := range path[h.pathLen:] {
+		if step.block.Cond != nil && len(step.block.Succs) == 2 {
+			outcome := "true"
+			if step.succ == 1 {
+				outcome = "false"
+			}
+			d.Trace = append(d.Trace, &Note{Loc: step.block.Cond.Loc, Message: fmt.Sprintf("condition is %s", outcome)})
+		}
+	}
+	// Location where the path leaves the function; the return statement or
+	// the end of the function body.
+	_, end := g.Func.Extent()
+	if len(path) > 0 {
+		nodes := path[len(path)-1].block.Nodes
+		if len(nodes) > 0 && nodes[len(nodes)-1].Body.Kind() == clang.Cursor_ReturnStmt {
+			end = nodes[len(nodes)-1].Loc
+		}
+	}
+	d.Trace = append(d.Trace, &Note{Loc: end, Message: fmt.Sprintf("%s leaks when leaving %s", h.desc, g.Func.Body.Spelling())})
+	return d
+}
+
+// nullTest returns the key of the local variable tested for null (or a
+// negative file descriptor) by the given branch condition, and whether the
+// variable is null when the condition is true.
+func nullTest(cond *cc.Node) (key string, nullOnTrue, ok bool) {
+	cond = stripCasts(cond)
+	switch cond.Body.Kind() {
+	case clang.Cursor_DeclRefExpr:
+		// if (v)
+		return cond.Body.Referenced().USR(), false, true
+	case clang.Cursor_UnaryOperator:
+		// if (!v)
+		if cond.Operator() == "!" && len(cond.Children) == 1 {
+			if v := testedVar(cond.Children[0]); v != nil {
+				return v.Body.Referenced().USR(), true, true
+			}
+		}
+	case clang.Cursor_BinaryOperator:
+		if len(cond.Children) != 2 {
+			return "", false, false
+		}
+		lhs, rhs := cond.Children[0], cond.Children[1]
+		v := testedVar(lhs)
+		if v == nil || !isNullConst(rhs) {
+			v = testedVar(rhs)
+			if v == nil || !isNullConst(lhs) {
+				return "", false, false
+			}
+		}
+		switch cond.Operator() {
+		case "==", "<":
+			// if (v == NULL), if (fd < 0)
+			return v.Body.Referenced().USR(), true, true
+		case "!=", ">=":
+			// if (v != NULL), if (fd >= 0)
+			return v.Body.Referenced().USR(), false, true
+		}
+	}
+	return "", false, false
+}
+
+// testedVar returns the variable reference of the given tested expression,
+// looking through assignments (e.g. "(fp = fopen(...))"); or nil if not a
+// variable reference.
+func testedVar(n *cc.Node) *cc.Node {
+	n = stripCasts(n)
+	if n.Body.Kind() == clang.Cursor_BinaryOperator && n.Operator() == "=" && len(n.Children) == 2 {
+		n = stripCasts(n.Children[0])
+	}
+	if n.Body.Kind() == clang.Cursor_DeclRefExpr {
+		return n
+	}
+	return nil
+}
+
+// argKey returns the key of the resource passed as the given argument (e.g.
+// "&m" of pthread_mutex_lock). Argument keys are prefixed by "arg:" to be
+// distinct from the keys of returned resources.
+func argKey(arg *cc.Node) string {
+	return "arg:" + exprKey(arg)
+}
+
+// exprKey returns a key of the given expression, which is equal for
+// expressions referring to the same variables through the same operators
+// regardless of casts and parentheses (e.g. "&m" and "&(m)").
+func exprKey(n *cc.Node) string {
+	n = stripCasts(n)
+	switch n.Body.Kind() {
+	case clang.Cursor_DeclRefExpr:
+		return n.Body.Referenced().USR()
+	case clang.Cursor_UnaryOperator:
+		if len(n.Children) == 1 {
+			return n.Operator() + "(" + exprKey(n.Children[0]) + ")"
+		}
+	case clang.Cursor_MemberRefExpr:
+		if len(n.Children) == 1 {
+			return exprKey(n.Children[0]) + "." + n.Body.Spelling()
+		}
+	}
+	return strings.Join(n.Tokens(), "")
+}
+
+// isNullConst reports whether the given expression is a null pointer constant
+// or -1 (e.g. an invalid file descriptor), after removing casts and
+// parentheses (e.g. "((void *)0)").
+func isNullConst(n *cc.Node) bool {
+	n = stripCasts(n)
+	if n.Body.Kind() == clang.Cursor_CXXNullPtrLiteralExpr {
+		return true
+	}
+	res := n.Body.Evaluate()
+	defer res.Dispose()
+	if res.Kind() != clang.Eval_Int {
+		return false
+	}
+	v := res.AsInt()
+	return v == 0 || v == -1
+}
+
+// heldVar returns the key of the held resource referenced by the given
+// expression, if the expression is a reference to a local variable holding a
+// resource.
+func heldVar(n *cc.Node, held map[string]*heldResource) (string, bool) {
+	if n.Body.Kind() != clang.Cursor_DeclRefExpr {
+		return "", false
+	}
+	key := n.Body.Referenced().USR()
+	_, ok := held[key]
+	return key, ok
+}
+
+// isLocalVar reports whether the given declaration is a local variable or
+// parameter.
+func isLocalVar(decl clang.Cursor) bool {
+	switch decl.Kind() {
+	case clang.Cursor_ParmDecl:
+		return true
+	case clang.Cursor_VarDecl:
+		return decl.Linkage() == clang.Linkage_NoLinkage && decl.StorageClass() != clang.SC_Static
+	}
+	return false
+}
+
+// stripCasts returns the given expression with implicit and explicit casts and
+// parentheses removed.
+func stripCasts(n *cc.Node) *cc.Node {
+	for {
+		n = stripImplicit(n)
+		if n.Body.Kind() != clang.Cursor_CStyleCastExpr || len(n.Children) == 0 {
+			return n
+		}
+		// The operand of a cast is its last child; preceded by type references.
+		n = n.Children[len(n.Children)-1]
+	}
+}
+
+// copyHeld returns a copy of the given held resources.
+func copyHeld(held map[string]*heldResource) map[string]*heldResource {
+	c := make(map[string]*heldResource, len(held))
+	for k, v := range held {
+		c[k] = v
+	}
+	return c
+}
+
+// stateKey returns a key identifying the given basic block and held resources.
+func stateKey(block *cfg.Block, held map[string]*heldResource) string {
+	var keys []string
+	for k, h := range held {
+		keys = append(keys, fmt.Sprintf("%s@%s", k, h.site.Loc))
+	}
+	sort.Strings(keys)
+	return fmt.Sprintf("%d %s", block.Index, strings.Join(keys, ","))
+}
@@ -0,0 +1,106 @@
+package checker
+
+import "testing"
+
+func TestResource(t *testing.T) {
+	const decls = `
+typedef struct FILE FILE;
+FILE *fopen(const char *path, const char *mode);
+int fclose(FILE *fp);
+void *malloc(unsigned long size);
+void *realloc(void *p, unsigned long size);
+void free(void *p);
+typedef struct mutex mutex;
+int pthread_mutex_lock(mutex *m);
+int pthread_mutex_unlock(mutex *m);
+`
+	golden := []struct {
+		body string
+		// Resource leak diagnostics; the body starts at line 12.
+		want []string
+	}{
+		// Released on all paths.
+		{body: `
+void f(const char *path) {
+	FILE *fp = fopen(path, "r");
+	if (fp == 0)
+		return;
+	fclose(fp);
+}`},
+		// Not released on early return.
+		{body: `
+int f(const char *path, int x) {
+	FILE *fp = fopen(path, "r");
+	if (!fp)
+		return -1;
+	if (x)
+		return 1;
+	fclose(fp);
+	return 0;
+}`, want: []string{"13 resource-leak"}},
+		// Returned to caller.
+		{body: `
+void *f(void) {
+	void *p = malloc(8);
+	return p;
+}`},
+		// Lock not released.
+		{body: `
+void f(mutex *m, int x) {
+	pthread_mutex_lock(m);
+	if (x)
+		return;
+	pthread_mutex_unlock(m);
+}`, want: []string{"13 resource-leak"}},
+		// Ownership transferred by reallocation.
+		{body: `
+void f(void) {
+	char *p = malloc(8);
+	char *q = realloc(p, 16);
+	if (!q) {
+		free(p);
+		return;
+	}
+	free(q);
+}`},
+		// Argument still held if reallocation fails.
+		{body: `
+void f(void) {
+	char *p = malloc(8);
+	char *q = realloc(p, 16);
+	if (!q)
+		return;
+	free(q);
+}`, want: []string{"13 resource-leak"}},
+		// Reallocated resource not released, and original resource lost if
+		// reallocation fails.
+		{body: `
+void f(void) {
+	char *p = malloc(8);
+	p = realloc(p, 16);
+	if (p)
+		p[0] = 0;
+}`, want: []string{"13 resource-leak", "14 resource-leak"}},
+		// Null pointer constants of other spellings.
+		{body: `
+void f(const char *path) {
+	FILE *fp = fopen(path, "r");
+	if (fp == (FILE *)0L)
+		return;
+	fclose(fp);
+}`},
+		// Lock released through an argument of different spelling.
+		{body: `
+void f(mutex **mp, int x) {
+	pthread_mutex_lock(*mp);
+	if (x) {
+		pthread_mutex_unlock(*(mp));
+		return;
+	}
+	pthread_mutex_unlock((*mp));
+}`},
+	}
+	for _, g := range golden {
+		checkDiags(t, &Resource{}, decls+g.body, g.want)
+	}
+}
@@ -0,0 +1,157 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/scope"
+)
+
+// ScopeHygiene is a checker which reports local variables shadowing
+// parameters, global variables or outer local variables, unused local
+// variables and parameters, and local variables declared in a wider scope than
+// needed.
+//
+// A local variable is declared in a wider scope than needed if all its uses
+// are within a single nested block. Variables used within loop bodies, static
+// variables and variables initialized by function calls are not reported, as
+// moving their declaration may change the behaviour of the program.
+type ScopeHygiene struct{}
+
+// Name returns the name of the checker.
+func (c *ScopeHygiene) Name() string {
+	return "scope"
+}
+
+// Check checks the given translation units, returning the diagnostics.
+func (c *ScopeHygiene) Check(files []*cc.File) []*Diagnostic {
+	var diags []*Diagnostic
+	for _, file := range files {
+		loopBodies := make(map[*cc.Node]bool)
+		cc.Walk(file.Root, func(n *cc.Node) {
+			switch n.Body.Kind() {
+			case clang.Cursor_ForStmt, clang.Cursor_WhileStmt, clang.Cursor_DoStmt:
+				for _, child := range n.Children {
+					loopBodies[child] = true
+				}
+			}
+		})
+		scope.Build(file).Walk(func(s *scope.Scope) {
+			if s.Kind == scope.KindFile {
+				return
+			}
+			for _, decl := range s.Decls {
+				if decl.Node.Body.Location().IsInSystemHeader() {
+					continue
+				}
+				switch decl.Node.Body.Kind() {
+				case clang.Cursor_VarDecl:
+					diags = append(diags, checkShadow(decl)...)
+					diags = append(diags, checkUnused(decl, "variable", "unused-variable", SeverityWarning)...)
+					diags = append(diags, checkScopeWidth(decl, loopBodies)...)
+				case clang.Cursor_ParmDecl:
+					if s.Node.Body.Spelling() == "main" {
+						continue
+					}
+					diags = append(diags, checkUnused(decl, "parameter", "unused-parameter", SeverityNote)...)
+				}
+			}
+		})
+	}
+	return diags
+}
+
+// checkShadow checks whether the given local variable shadows a parameter,
+// global variable or outer local variable.
+func checkShadow(decl *scope.Decl) []*Diagnostic {
+	shadowed := decl.Shadows
+	if shadowed == nil || shadowed.Node.Body.Location().IsInSystemHeader() {
+		return nil
+	}
+	var desc string
+	switch {
+	case shadowed.Node.Body.Kind() == clang.Cursor_ParmDecl:
+		desc = "parameter"
+	case shadowed.Node.Body.Kind() != clang.Cursor_VarDecl:
+		return nil
+	case shadowed.Scope.Kind == scope.KindFile:
+		desc = "global variable"
+	default:
+		desc = "outer local variable"
+	}
+	d := &Diagnostic{
+		Loc:      decl.Node.Loc,
+		Rule:     "shadow",
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf("local variable %s shadows %s %s", decl.Name, desc, shadowed.Name),
+		Trace:    []*Note{{Loc: shadowed.Node.Loc, Message: "shadowed declaration"}},
+	}
+	return []*Diagnostic{d}
+}
+
+// checkUnused checks whether the given variable or parameter is unused.
+// Declarations with the unused attribute are not reported.
+func checkUnused(decl *scope.Decl, kind, rule string, severity Severity) []*Diagnostic {
+	if len(decl.Refs) > 0 || hasAttr(decl.Node, "unused", "maybe_unused") {
+		return nil
+	}
+	d := &Diagnostic{
+		Loc:      decl.Node.Loc,
+		Rule:     rule,
+		Severity: severity,
+		Message:  fmt.Sprintf("unused %s %s", kind, decl.Name),
+	}
+	return []*Diagnostic{d}
+}
+
+// checkScopeWidth checks whether the given local variable may be declared in a
+// narrower scope.
+func checkScopeWidth(decl *scope.Decl, loopBodies map[*cc.Node]bool) []*Diagnostic {
+	if len(decl.Refs) == 0 || decl.Node.StorageClass() == cc.StorageStatic || decl.Node.StorageClass() == cc.StorageExtern {
+		return nil
+	}
+	initByCall := false
+	cc.Walk(decl.Node, func(n *cc.Node) {
+		if n.Body.Kind() == clang.Cursor_CallExpr {
+			initByCall = true
+		}
+	})
+	if initByCall {
+		return nil
+	}
+	// Locate the innermost scope containing all uses.
+	inner := decl.Refs[0].Scope
+	for _, ref := range decl.Refs[1:] {
+		for inner != nil && !inner.Contains(ref.Scope) {
+			inner = inner.Parent
+		}
+	}
+	if inner == nil || inner == decl.Scope || !decl.Scope.Contains(inner) {
+		return nil
+	}
+	// Declarations may only be moved into compound statements.
+	for inner != decl.Scope && inner.Node.Body.Kind() != clang.Cursor_CompoundStmt {
+		inner = inner.Parent
+	}
+	if inner == decl.Scope {
+		return nil
+	}
+	for s := inner; s != decl.Scope; s = s.Parent {
+		switch s.Node.Body.Kind() {
+		case clang.Cursor_ForStmt, clang.Cursor_WhileStmt:
+			return nil
+		}
+		if loopBodies[s.Node] {
+			return nil
+		}
+	}
+	d := &Diagnostic{
+		Loc:      decl.Node.Loc,
+		Rule:     "scope-too-wide",
+		Severity: SeverityNote,
+		Message:  fmt.Sprintf("variable %s can be declared in a narrower scope", decl.Name),
+		Trace:    []*Note{{Loc: inner.Node.Loc, Message: fmt.Sprintf("all uses of %s are within this block", decl.Name)}},
+	}
+	return []*Diagnostic{d}
+}
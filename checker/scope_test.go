@@ -0,0 +1,35 @@
+package checker
+
+import "testing"
+
+func TestScopeHygiene(t *testing.T) {
+	const src = `
+int g;
+void use(int);
+int f(int p, int unused) {
+	int g = p;
+	int x = 0;
+	int y;
+	if (p) {
+		int p = x;
+		use(p);
+	}
+	return g;
+}
+int main(int argc, char **argv) {
+	for (int i = 0; i < 3; i++) {
+		int j = i;
+		use(j);
+	}
+	return 0;
+}
+`
+	want := []string{
+		"4 unused-parameter",
+		"5 shadow",
+		"6 scope-too-wide",
+		"7 unused-variable",
+		"9 shadow",
+	}
+	checkDiags(t, &ScopeHygiene{}, src, want)
+}
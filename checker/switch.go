@@ -0,0 +1,276 @@
+package checker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/cfg"
+)
+
+// Switch is a checker which reports switch statements on enums missing
+// enumerators without a default label, case labels unreachable for the enum,
+// and case labels reached by falling through from the previous case without a
+// fallthrough annotation.
+//
+// Fallthrough is annotated by a comment matching "fall through" or
+// "fallthrough" (case insensitive), or a fallthrough attribute, between the
+// last statement of the previous case and the case label.
+type Switch struct{}
+
+// Name returns the name of the checker.
+func (c *Switch) Name() string {
+	return "switch"
+}
+
+// fallthroughRegexp matches fallthrough annotations.
+var fallthroughRegexp = regexp.MustCompile(`(?i)fall[ -]?(through|thru)`)
+
+// Check checks the given translation units, returning the diagnostics.
+func (c *Switch) Check(files []*cc.File) []*Diagnostic {
+	var diags []*Diagnostic
+	lines := make(map[string][]string)
+	walkUser(files, func(n *cc.Node) {
+		if n.Body.Kind() != clang.Cursor_SwitchStmt || len(n.Children) < 2 {
+			return
+		}
+		items := switchItems(n.Children[1])
+		diags = append(diags, checkEnumSwitch(n, items)...)
+		diags = append(diags, checkFallthrough(items, lines)...)
+	})
+	return diags
+}
+
+// switchItem is a case label or statement of the body of a switch statement.
+type switchItem struct {
+	// Case label or statement.
+	node *cc.Node
+	// Case or default label.
+	isLabel bool
+}
+
+// switchItems returns the case labels and statements of the given switch body,
+// in source order. Nested switch statements are not traversed.
+func switchItems(body *cc.Node) []*switchItem {
+	var items []*switchItem
+	var add func(n *cc.Node)
+	add = func(n *cc.Node) {
+		switch n.Body.Kind() {
+		case clang.Cursor_CaseStmt, clang.Cursor_DefaultStmt:
+			items = append(items, &switchItem{node: n, isLabel: true})
+			// The sub-statement is the last child.
+			sub := 1
+			if n.Body.Kind() == clang.Cursor_DefaultStmt {
+				sub = 0
+			}
+			if sub < len(n.Children) {
+				add(n.Children[sub])
+			}
+		default:
+			items = append(items, &switchItem{node: n})
+		}
+	}
+	if body.Body.Kind() != clang.Cursor_CompoundStmt {
+		add(body)
+		return items
+	}
+	for _, child := range body.Children {
+		add(child)
+	}
+	return items
+}
+
+// enumerator is an enumerator of an enum type.
+type enumerator struct {
+	// Enumerator name.
+	name string
+	// Enumerator value.
+	value int64
+}
+
+// checkEnumSwitch checks the case labels of the given switch statement on an
+// enum-typed expression.
+func checkEnumSwitch(sw *cc.Node, items []*switchItem) []*Diagnostic {
+	cond := stripImplicit(sw.Children[0])
+	t := cond.Body.Type().CanonicalType()
+	if t.Kind() != clang.Type_Enum {
+		return nil
+	}
+	decl := t.Declaration()
+	var enumerators []*enumerator
+	values := make(map[int64]bool)
+	decl.Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		if cursor.Kind() == clang.Cursor_EnumConstantDecl {
+			e := &enumerator{name: cursor.Spelling(), value: cursor.EnumConstantDeclValue()}
+			enumerators = append(enumerators, e)
+			values[e.value] = true
+		}
+		return clang.ChildVisit_Continue
+	})
+	enumName := decl.Spelling()
+	if len(enumName) == 0 {
+		enumName = cond.Body.Type().Spelling()
+	}
+	var diags []*Diagnostic
+	covered := make(map[int64]bool)
+	hasDefault := false
+	for _, item := range items {
+		if !item.isLabel {
+			continue
+		}
+		if item.node.Body.Kind() == clang.Cursor_DefaultStmt {
+			hasDefault = true
+			continue
+		}
+		if len(item.node.Children) == 0 {
+			continue
+		}
+		value, ok := caseValue(item.node.Children[0])
+		if !ok {
+			continue
+		}
+		covered[value] = true
+		if !values[value] {
+			d := &Diagnostic{
+				Loc:      item.node.Loc,
+				Rule:     "switch-unreachable-case",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("case value %d is not an enumerator of enum %s and is unreachable", value, enumName),
+			}
+			diags = append(diags, d)
+		}
+	}
+	if hasDefault {
+		return diags
+	}
+	var missing []string
+	for _, e := range enumerators {
+		if !covered[e.value] {
+			missing = append(missing, e.name)
+		}
+	}
+	if len(missing) > 0 {
+		d := &Diagnostic{
+			Loc:        sw.Loc,
+			Rule:       "switch-missing-enumerator",
+			Severity:   SeverityWarning,
+			Message:    fmt.Sprintf("switch on enum %s without default label does not handle %s", enumName, strings.Join(missing, ", ")),
+			Suggestion: "add case labels for the missing enumerators or a default label",
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// caseValue returns the value of the given case label expression, if it is an
+// enumerator or an integer literal.
+func caseValue(expr *cc.Node) (int64, bool) {
+	expr = stripCasts(expr)
+	neg := false
+	if expr.Body.Kind() == clang.Cursor_UnaryOperator && expr.Operator() == "-" && len(expr.Children) == 1 {
+		neg = true
+		expr = stripCasts(expr.Children[0])
+	}
+	var value int64
+	switch expr.Body.Kind() {
+	case clang.Cursor_DeclRefExpr:
+		ref := expr.Body.Referenced()
+		if ref.Kind() != clang.Cursor_EnumConstantDecl {
+			return 0, false
+		}
+		value = ref.EnumConstantDeclValue()
+	case clang.Cursor_IntegerLiteral:
+		toks := expr.Tokens()
+		if len(toks) == 0 {
+			return 0, false
+		}
+		v, err := strconv.ParseInt(strings.TrimRight(toks[0], "uUlL"), 0, 64)
+		if err != nil {
+			return 0, false
+		}
+		value = v
+	default:
+		return 0, false
+	}
+	if neg {
+		value = -value
+	}
+	return value, true
+}
+
+// checkFallthrough checks for case labels reached by falling through from the
+// statements of the previous case without a fallthrough annotation.
+func checkFallthrough(items []*switchItem, lines map[string][]string) []*Diagnostic {
+	var diags []*Diagnostic
+	var last *cc.Node
+	for _, item := range items {
+		if !item.isLabel {
+			last = item.node
+			continue
+		}
+		if last != nil && canFallThrough(last) && !annotatedFallthrough(last, item.node, lines) {
+			d := &Diagnostic{
+				Loc:        item.node.Loc,
+				Rule:       "switch-fallthrough",
+				Severity:   SeverityWarning,
+				Message:    "unannotated fall-through between switch labels",
+				Suggestion: "add a break statement, or a /* fallthrough */ comment if intentional",
+			}
+			diags = append(diags, d)
+		}
+		last = nil
+	}
+	return diags
+}
+
+// canFallThrough reports whether control may flow past the end of the given
+// statement.
+func canFallThrough(stmt *cc.Node) bool {
+	switch stmt.Body.Kind() {
+	case clang.Cursor_BreakStmt, clang.Cursor_ContinueStmt, clang.Cursor_ReturnStmt, clang.Cursor_GotoStmt, clang.Cursor_IndirectGotoStmt:
+		return false
+	case clang.Cursor_CompoundStmt:
+		if len(stmt.Children) == 0 {
+			return true
+		}
+		return canFallThrough(stmt.Children[len(stmt.Children)-1])
+	case clang.Cursor_IfStmt:
+		// cond, then [, else]
+		if len(stmt.Children) < 3 {
+			return true
+		}
+		return canFallThrough(stmt.Children[1]) || canFallThrough(stmt.Children[2])
+	case clang.Cursor_CallExpr:
+		return !cfg.NoReturn[stmt.Body.Referenced().Spelling()]
+	}
+	return true
+}
+
+// annotatedFallthrough reports whether a fallthrough annotation is present
+// between the given statement and case label.
+func annotatedFallthrough(stmt, label *cc.Node, lines map[string][]string) bool {
+	_, end := stmt.Extent()
+	if end.File != label.Loc.File {
+		return false
+	}
+	src, ok := lines[end.File]
+	if !ok {
+		if buf, err := ioutil.ReadFile(end.File); err == nil {
+			src = strings.Split(string(buf), "\n")
+		}
+		lines[end.File] = src
+	}
+	for line := end.Line; line <= label.Loc.Line; line++ {
+		if line < 1 || int(line) > len(src) {
+			continue
+		}
+		if fallthroughRegexp.MatchString(src[line-1]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package checker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+func TestSwitch(t *testing.T) {
+	const src = `
+enum color { RED, GREEN, BLUE };
+void g(void);
+void f(enum color c, int x) {
+	switch (c) {
+	case RED:
+		g();
+	case GREEN:
+		g();
+		/* fall through */
+	case 7:
+		break;
+	}
+	switch (c) {
+	case RED:
+	case GREEN:
+		if (x)
+			return;
+		else
+			break;
+	default:
+		g();
+	}
+	switch (x) {
+	case 1:
+		g();
+	case 2:
+		break;
+	}
+}
+`
+	// Fallthrough annotations are read from disk.
+	dir, err := ioutil.TempDir("", "switch")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	srcPath := filepath.Join(dir, "switch.c")
+	if err := ioutil.WriteFile(srcPath, []byte(src), 0644); err != nil {
+		t.Fatalf("unable to write source file; %+v", err)
+	}
+	file, err := cc.ParseFile(srcPath, "-w")
+	if err != nil {
+		t.Fatalf("unable to parse source; %+v", err)
+	}
+	defer file.Close()
+	golden := []string{
+		"5 switch-missing-enumerator",
+		"8 switch-fallthrough",
+		"11 switch-unreachable-case",
+		"27 switch-fallthrough",
+	}
+	var got []string
+	for _, d := range Run([]*cc.File{file}, &Switch{}) {
+		got = append(got, fmt.Sprintf("%d %s", d.Loc.Line, d.Rule))
+	}
+	if strings.Join(got, "; ") != strings.Join(golden, "; ") {
+		t.Errorf("diagnostics mismatch; expected %q, got %q", golden, got)
+	}
+}
+
+func TestCaseValue(t *testing.T) {
+	golden := []struct {
+		expr string
+		want int64
+		ok   bool
+	}{
+		{expr: "B", want: 5, ok: true},
+		{expr: "-B", want: -5, ok: true},
+		{expr: "0x10", want: 16, ok: true},
+		{expr: "10UL", want: 10, ok: true},
+		{expr: "(int)3", want: 3, ok: true},
+		{expr: "1 + 2", ok: false},
+		{expr: "sizeof(int)", ok: false},
+	}
+	for _, g := range golden {
+		src := fmt.Sprintf("enum { A, B = 5 }; long x = %s;", g.expr)
+		file, err := cc.ParseSource("case.c", src, "-w")
+		if err != nil {
+			t.Errorf("unable to parse %q; %+v", src, err)
+			continue
+		}
+		init := file.Root.Children[len(file.Root.Children)-1]
+		init = init.Children[len(init.Children)-1]
+		got, ok := caseValue(init)
+		if got != g.want || ok != g.ok {
+			t.Errorf("%q: case value mismatch; expected %d (%v), got %d (%v)", g.expr, g.want, g.ok, got, ok)
+		}
+		file.Close()
+	}
+}
@@ -0,0 +1,42 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/taint"
+)
+
+// Taint is a checker which reports flows of untrusted input (e.g. from read,
+// recv, getenv or argv) into sinks such as commands, copy sizes, format
+// strings and array indices, based on an interprocedural taint analysis.
+type Taint struct {
+	// Taint analysis configuration; or nil to use taint.DefaultConfig.
+	Config *taint.Config
+}
+
+// Name returns the name of the checker.
+func (c *Taint) Name() string {
+	return "taint"
+}
+
+// Check checks the given translation units, returning the diagnostics.
+func (c *Taint) Check(files []*cc.File) []*Diagnostic {
+	var diags []*Diagnostic
+	for _, flow := range taint.Analyze(files, c.Config) {
+		if flow.Site.Body.Location().IsInSystemHeader() {
+			continue
+		}
+		d := &Diagnostic{
+			Loc:      flow.Site.Loc,
+			Rule:     "taint",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("untrusted data from %s reaches %s (%s)", flow.Source, flow.Sink, flow.Kind),
+		}
+		for _, step := range flow.Trace {
+			d.Trace = append(d.Trace, &Note{Loc: step.Loc, Message: step.Message})
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
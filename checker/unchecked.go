@@ -0,0 +1,260 @@
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/cfg"
+)
+
+// UncheckedConfig is the configuration of the unchecked return value checker.
+type UncheckedConfig struct {
+	// Functions whose return value must always be checked, in addition to
+	// functions declared with the warn_unused_result or nodiscard attribute.
+	Funcs []string `json:"funcs"`
+	// Minimum number of call sites of a function to infer from its call sites
+	// whether its return value should be checked; or 0 to disable inference.
+	MinCalls int `json:"min_calls"`
+	// Minimum ratio of call sites checking the return value of a function for
+	// the remaining call sites to be reported as outliers.
+	MinCheckedRatio float64 `json:"min_checked_ratio"`
+}
+
+// DefaultUncheckedConfig is the default configuration of the unchecked return
+// value checker.
+var DefaultUncheckedConfig = &UncheckedConfig{
+	Funcs: []string{
+		"read", "write", "pread", "pwrite", "fread", "fwrite",
+		"scanf", "fscanf", "sscanf",
+		"setuid", "setgid", "seteuid", "setegid", "setreuid", "setregid", "setresuid", "setresgid",
+		"chdir", "chroot", "mkstemp", "pipe", "fork", "dup2",
+		"realloc", "posix_memalign", "asprintf", "vasprintf",
+		"pthread_create", "pthread_mutex_init",
+	},
+	MinCalls:        5,
+	MinCheckedRatio: 0.8,
+}
+
+// Unchecked is a checker which reports calls discarding the return value of
+// functions whose return value should be checked. Return values explicitly
+// discarded by a cast to void are not reported.
+type Unchecked struct {
+	// Checker configuration; or nil to use DefaultUncheckedConfig.
+	Config *UncheckedConfig
+}
+
+// Name returns the name of the checker.
+func (c *Unchecked) Name() string {
+	return "unchecked"
+}
+
+// callStats are the call site statistics of a function.
+type callStats struct {
+	// Function name.
+	name string
+	// Number of call sites using the return value.
+	checked int
+	// Call sites discarding the return value.
+	discarded []*cc.Node
+}
+
+// Check checks the given translation units, returning the diagnostics.
+func (c *Unchecked) Check(files []*cc.File) []*Diagnostic {
+	config := c.Config
+	if config == nil {
+		config = DefaultUncheckedConfig
+	}
+	mustCheck := make(map[string]bool)
+	for _, name := range config.Funcs {
+		mustCheck[name] = true
+	}
+	// Functions declared with the warn_unused_result or nodiscard attribute.
+	attrFuncs := make(map[string]bool)
+	for _, file := range files {
+		cc.Walk(file.Root, func(n *cc.Node) {
+			switch n.Body.Kind() {
+			case clang.Cursor_FunctionDecl, clang.Cursor_CXXMethod:
+				if hasAttr(n, "warn_unused_result", "nodiscard", "__wur") {
+					attrFuncs[n.Body.USR()] = true
+				}
+			}
+		})
+	}
+	// Collect call site statistics.
+	stats := make(map[string]*callStats)
+	seen := make(map[cc.Location]bool)
+	walkFuncDefs(files, func(fn *cc.Node) {
+		discarded := make(map[*cc.Node]bool)
+		ignored := make(map[*cc.Node]bool)
+		visitStmtExprs(fn, func(expr *cc.Node) {
+			if call := discardedCall(expr); call != nil {
+				discarded[call] = true
+			}
+			if call := voidCastCall(expr); call != nil {
+				ignored[call] = true
+			}
+		})
+		cc.Walk(fn, func(n *cc.Node) {
+			if n.Body.Kind() != clang.Cursor_CallExpr || ignored[n] || seen[n.Loc] {
+				return
+			}
+			// Headers included by several translation units are only counted
+			// once.
+			seen[n.Loc] = true
+			// Calls through function pointers are not considered.
+			callee := n.Body.Referenced()
+			switch callee.Kind() {
+			case clang.Cursor_FunctionDecl, clang.Cursor_CXXMethod:
+			default:
+				return
+			}
+			if callee.ResultType().Kind() == clang.Type_Void {
+				return
+			}
+			s, ok := stats[callee.USR()]
+			if !ok {
+				s = &callStats{name: callee.Spelling()}
+				stats[callee.USR()] = s
+			}
+			if discarded[n] {
+				s.discarded = append(s.discarded, n)
+			} else {
+				s.checked++
+			}
+		})
+	})
+	var usrs []string
+	for usr := range stats {
+		usrs = append(usrs, usr)
+	}
+	sort.Strings(usrs)
+	var diags []*Diagnostic
+	for _, usr := range usrs {
+		s := stats[usr]
+		total := s.checked + len(s.discarded)
+		for _, call := range s.discarded {
+			d := &Diagnostic{
+				Loc:        call.Loc,
+				Rule:       "unchecked-return",
+				Severity:   SeverityWarning,
+				Suggestion: fmt.Sprintf("check the return value of %s, or cast it to void if intentionally ignored", s.name),
+			}
+			switch {
+			case attrFuncs[usr]:
+				d.Message = fmt.Sprintf("return value of %s declared with warn_unused_result is ignored", s.name)
+			case mustCheck[s.name]:
+				d.Message = fmt.Sprintf("return value of %s is ignored", s.name)
+			case config.MinCalls > 0 && total >= config.MinCalls && float64(s.checked)/float64(total) >= config.MinCheckedRatio:
+				d.Rule = "unchecked-return-outlier"
+				d.Message = fmt.Sprintf("return value of %s is ignored, but checked at %d of %d call sites", s.name, s.checked, total)
+			default:
+				continue
+			}
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
+
+// discardedCall returns the call of the given expression statement whose
+// return value is discarded; or nil if not present.
+func discardedCall(expr *cc.Node) *cc.Node {
+	expr = stripImplicit(expr)
+	if expr.Body.Kind() == clang.Cursor_CallExpr {
+		return expr
+	}
+	return nil
+}
+
+// voidCastCall returns the call of the given expression statement explicitly
+// discarded by a cast to void; or nil if not present.
+func voidCastCall(expr *cc.Node) *cc.Node {
+	expr = stripImplicit(expr)
+	if expr.Body.Kind() != clang.Cursor_CStyleCastExpr || expr.Body.Type().CanonicalType().Kind() != clang.Type_Void {
+		return nil
+	}
+	if call := stripCasts(expr); call.Body.Kind() == clang.Cursor_CallExpr {
+		return call
+	}
+	return nil
+}
+
+// visitStmtExprs invokes f for each expression in statement position of the
+// given function definition or statement; i.e. expressions whose value is
+// discarded. The operands of comma operators in statement position are
+// visited as well.
+func visitStmtExprs(n *cc.Node, f func(expr *cc.Node)) {
+	kind := n.Body.Kind()
+	switch kind {
+	case clang.Cursor_FunctionDecl, clang.Cursor_CXXMethod, clang.Cursor_Constructor, clang.Cursor_Destructor, clang.Cursor_CompoundStmt:
+		for _, child := range n.Children {
+			if child.Body.Kind().IsStatement() || child.Body.Kind().IsExpression() {
+				visitStmtExprs(child, f)
+			}
+		}
+	case clang.Cursor_IfStmt:
+		// cond, then [, else]
+		for i, child := range n.Children {
+			if i > 0 {
+				visitStmtExprs(child, f)
+			}
+		}
+	case clang.Cursor_WhileStmt, clang.Cursor_SwitchStmt:
+		// cond, body
+		if len(n.Children) > 1 {
+			visitStmtExprs(n.Children[len(n.Children)-1], f)
+		}
+	case clang.Cursor_DoStmt:
+		// body, cond
+		if len(n.Children) > 0 {
+			visitStmtExprs(n.Children[0], f)
+		}
+	case clang.Cursor_ForStmt:
+		init, _, inc, body := cfg.ForParts(n)
+		for _, child := range []*cc.Node{init, inc, body} {
+			if child != nil {
+				visitStmtExprs(child, f)
+			}
+		}
+	case clang.Cursor_CaseStmt, clang.Cursor_DefaultStmt, clang.Cursor_LabelStmt:
+		// The sub-statement is the last child.
+		if len(n.Children) > 0 {
+			visitStmtExprs(n.Children[len(n.Children)-1], f)
+		}
+	default:
+		if !kind.IsExpression() {
+			return
+		}
+		f(n)
+		if kind == clang.Cursor_BinaryOperator && n.Operator() == "," {
+			for _, child := range n.Children {
+				visitStmtExprs(child, f)
+			}
+		}
+	}
+}
+
+// hasAttr reports whether the given declaration has an attribute of one of the
+// given names. Leading and trailing underscores of attribute names are
+// ignored.
+func hasAttr(decl *cc.Node, names ...string) bool {
+	for _, child := range decl.Children {
+		if !child.Body.Kind().IsAttribute() {
+			continue
+		}
+		toks := child.Tokens()
+		if len(toks) == 0 {
+			continue
+		}
+		attr := toks[0]
+		for _, name := range names {
+			if attr == name || strings.Trim(attr, "_") == strings.Trim(name, "_") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package checker
+
+import "testing"
+
+func TestUnchecked(t *testing.T) {
+	const src = `
+int setuid(int uid);
+int get(void);
+__attribute__((warn_unused_result)) int must(void);
+void nothing(void);
+int (*fp)(void);
+void f(void) {
+	setuid(0);
+	(void)setuid(0);
+	if (setuid(0) != 0)
+		return;
+	must();
+	nothing();
+	fp();
+	fp();
+	fp();
+	get();
+	for (get(); get(); get())
+		;
+	int x = get() + get() + get() + get() + get() + get() + get() + get() + get() + get() + get() + get();
+	x = fp() + fp() + fp() + fp() + fp() + fp() + fp() + fp() + fp() + fp() + fp() + fp();
+}
+`
+	golden := []struct {
+		config *UncheckedConfig
+		want   []string
+	}{
+		{
+			config: nil,
+			want: []string{
+				"8 unchecked-return",
+				"12 unchecked-return",
+				"17 unchecked-return-outlier",
+				"18 unchecked-return-outlier",
+				"18 unchecked-return-outlier",
+			},
+		},
+		{
+			config: &UncheckedConfig{},
+			want: []string{
+				"12 unchecked-return",
+			},
+		},
+	}
+	for _, g := range golden {
+		checkDiags(t, &Unchecked{Config: g.config}, src, g.want)
+	}
+}
@@ -0,0 +1,400 @@
+// Package clone detects duplicated code fragments across source files.
+//
+// Statement subtrees are hashed after normalization, abstracting identifiers
+// and literals but retaining the structure and operators of the code. Subtrees
+// with identical normalized hashes are exact clones (modulo renaming and
+// literal values). Near-duplicate subtrees are detected by comparing the
+// multisets of normalized hashes of their descendants, using the similarity
+// measure of Baxter et al. (Clone Detection Using Abstract Syntax Trees,
+// 1998):
+//
+//	similarity = 2*S / (2*S + L + R)
+//
+// where S is the number of shared nodes, and L and R the number of nodes only
+// present in either subtree.
+package clone
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+)
+
+// Config is the configuration of the clone detector.
+type Config struct {
+	// Minimum number of AST nodes of code fragments.
+	MinSize int
+	// Minimum similarity of near-duplicate code fragments, in the range
+	// (0, 1]. A minimum similarity of 1 only reports exact clones.
+	MinSimilarity float64
+}
+
+// DefaultConfig is the default configuration of the clone detector.
+var DefaultConfig = &Config{
+	MinSize:       20,
+	MinSimilarity: 0.8,
+}
+
+// Group is a group of cloned code fragments.
+type Group struct {
+	// Minimum similarity between linked fragments of the group; 1 for exact
+	// clones.
+	Similarity float64
+	// Cloned code fragments, sorted by location.
+	Fragments []*Fragment
+}
+
+// Fragment is a code fragment.
+type Fragment struct {
+	// Kind of root node of the fragment.
+	Kind string
+	// Start location of the fragment.
+	Start cc.Location
+	// End location of the fragment.
+	End cc.Location
+	// Number of AST nodes of the fragment.
+	Size int
+}
+
+// String returns a string representation of the code fragment.
+func (f *Fragment) String() string {
+	return fmt.Sprintf("%s-%d:%d (%s, %d nodes)", f.Start, f.End.Line, f.End.Col, f.Kind, f.Size)
+}
+
+// contains reports whether the code fragment contains the given fragment.
+func (f *Fragment) contains(g *Fragment) bool {
+	return f.Start.File == g.Start.File && !before(g.Start, f.Start) && !before(f.End, g.End)
+}
+
+// before reports whether location a is before location b in the same file.
+func before(a, b cc.Location) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Col < b.Col
+}
+
+// candidate is a candidate code fragment.
+type candidate struct {
+	// Code fragment.
+	frag *Fragment
+	// Normalized hash of the fragment.
+	hash uint64
+	// Normalized hashes of the nodes of the fragment, in post-order.
+	nodes []uint64
+}
+
+// Detect detects the cloned code fragments of the given translation units,
+// sorted by decreasing fragment size. Code located in system headers is
+// ignored.
+func Detect(files []*cc.File, config *Config) []*Group {
+	// Collect candidate fragments; fragments of headers included by several
+	// translation units are only collected once.
+	var cands []*candidate
+	seen := make(map[string]bool)
+	for _, file := range files {
+		for _, n := range file.Root.Children {
+			if n.Body.Location().IsInSystemHeader() {
+				continue
+			}
+			var nodes []uint64
+			normalize(n, &nodes, func(c *candidate) {
+				if c.frag.Size < config.MinSize {
+					return
+				}
+				key := fmt.Sprintf("%s %s", c.frag.Start, c.frag.Kind)
+				if seen[key] {
+					return
+				}
+				seen[key] = true
+				cands = append(cands, c)
+			})
+		}
+	}
+	sort.SliceStable(cands, func(i, j int) bool {
+		return cands[i].frag.Size > cands[j].frag.Size
+	})
+	var groups []*Group
+	var accepted []*Group
+	// covered reports whether all given fragments are contained within the
+	// fragments of an accepted group.
+	covered := func(frags []*Fragment) bool {
+	outer:
+		for _, g := range accepted {
+			for _, f := range frags {
+				found := false
+				for _, gf := range g.Fragments {
+					if gf.contains(f) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					continue outer
+				}
+			}
+			return true
+		}
+		return false
+	}
+	// Exact clones.
+	candsFromHash := make(map[uint64][]*candidate)
+	for _, c := range cands {
+		candsFromHash[c.hash] = append(candsFromHash[c.hash], c)
+	}
+	exact := make(map[*candidate]bool)
+	for _, c := range cands {
+		cs := candsFromHash[c.hash]
+		if len(cs) < 2 || exact[c] {
+			continue
+		}
+		var frags []*Fragment
+		for _, dup := range cs {
+			exact[dup] = true
+			frags = append(frags, dup.frag)
+		}
+		if covered(frags) {
+			continue
+		}
+		g := &Group{Similarity: 1, Fragments: frags}
+		accepted = append(accepted, g)
+		groups = append(groups, g)
+	}
+	// Near-duplicates.
+	if config.MinSimilarity < 1 {
+		for _, pair := range similarPairs(cands, config.MinSimilarity) {
+			a, b := cands[pair[0]], cands[pair[1]]
+			if a.hash == b.hash || a.frag.Kind != b.frag.Kind {
+				continue
+			}
+			if a.frag.contains(b.frag) || b.frag.contains(a.frag) {
+				continue
+			}
+			sim := similarity(a.nodes, b.nodes)
+			if sim < config.MinSimilarity {
+				continue
+			}
+			frags := []*Fragment{a.frag, b.frag}
+			if covered(frags) {
+				continue
+			}
+			g := &Group{Similarity: sim, Fragments: frags}
+			accepted = append(accepted, g)
+			groups = append(groups, g)
+		}
+		groups = mergeGroups(groups)
+	}
+	for _, g := range groups {
+		sort.Slice(g.Fragments, func(i, j int) bool {
+			a, b := g.Fragments[i].Start, g.Fragments[j].Start
+			if a.File != b.File {
+				return a.File < b.File
+			}
+			return before(a, b)
+		})
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Fragments[0].Size > groups[j].Fragments[0].Size
+	})
+	return groups
+}
+
+// mergeGroups merges near-duplicate groups sharing a code fragment. Exact
+// clone groups are left as is.
+func mergeGroups(groups []*Group) []*Group {
+	var merged []*Group
+	groupFromFrag := make(map[*Fragment]*Group)
+	for _, g := range groups {
+		if g.Similarity == 1 {
+			merged = append(merged, g)
+			continue
+		}
+		var target *Group
+		for _, f := range g.Fragments {
+			if t, ok := groupFromFrag[f]; ok {
+				target = t
+				break
+			}
+		}
+		if target == nil {
+			target = &Group{Similarity: g.Similarity}
+			merged = append(merged, target)
+		}
+		if g.Similarity < target.Similarity {
+			target.Similarity = g.Similarity
+		}
+		for _, f := range g.Fragments {
+			if _, ok := groupFromFrag[f]; !ok {
+				groupFromFrag[f] = target
+				target.Fragments = append(target.Fragments, f)
+			}
+		}
+	}
+	return merged
+}
+
+// token is an element of the multiset of normalized node hashes of a
+// candidate; the k-th occurrence of hash h.
+type token struct {
+	h uint64
+	k int
+}
+
+// tokens returns the multiset of the given normalized node hashes as a set of
+// tokens.
+func tokens(nodes []uint64) []token {
+	hashes := append([]uint64(nil), nodes...)
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i] < hashes[j]
+	})
+	toks := make([]token, len(hashes))
+	for i, h := range hashes {
+		k := 0
+		if i > 0 && hashes[i-1] == h {
+			k = toks[i-1].k + 1
+		}
+		toks[i] = token{h: h, k: k}
+	}
+	return toks
+}
+
+// similarPairs returns the index pairs of the given candidates, sorted by
+// decreasing size, which may have a similarity of at least minSim, in order of
+// occurrence.
+//
+// Candidate pairs are located using prefix filtering (Chaudhuri et al., A
+// Primitive Operator for Similarity Joins in Data Cleaning, 2006). Pairs with
+// similarity 2*S/(|a|+|b|) >= minSim share S >= minSim*|a|/(2-minSim) nodes,
+// and thus share a token within the prefixes of their tokens ordered by
+// increasing frequency, of length |a|-ceil(minSim*|a|/(2-minSim))+1.
+func similarPairs(cands []*candidate, minSim float64) [][2]int {
+	toks := make([][]token, len(cands))
+	freq := make(map[token]int)
+	for i, c := range cands {
+		toks[i] = tokens(c.nodes)
+		for _, tok := range toks[i] {
+			freq[tok]++
+		}
+	}
+	// Inverted index from prefix tokens to candidates.
+	candsFromToken := make(map[token][]int)
+	var pairs [][2]int
+	for i, c := range cands {
+		ts := toks[i]
+		sort.Slice(ts, func(x, y int) bool {
+			a, b := ts[x], ts[y]
+			if freq[a] != freq[b] {
+				return freq[a] < freq[b]
+			}
+			if a.h != b.h {
+				return a.h < b.h
+			}
+			return a.k < b.k
+		})
+		overlap := int(math.Ceil(minSim * float64(len(ts)) / (2 - minSim)))
+		if overlap < 1 {
+			overlap = 1
+		}
+		prefix := ts[:len(ts)-overlap+1]
+		seen := make(map[int]bool)
+		for _, tok := range prefix {
+			for _, j := range candsFromToken[tok] {
+				// Candidates are sorted by decreasing size; pairs with
+				// similarity of at least minSim satisfy
+				// |b| >= minSim*|a|/(2-minSim) for |a| >= |b|.
+				if seen[j] || float64(c.frag.Size) < minSim*float64(cands[j].frag.Size)/(2-minSim) {
+					continue
+				}
+				seen[j] = true
+				pairs = append(pairs, [2]int{j, i})
+			}
+			candsFromToken[tok] = append(candsFromToken[tok], i)
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	return pairs
+}
+
+// similarity returns the similarity of the given multisets of normalized node
+// hashes.
+func similarity(a, b []uint64) float64 {
+	ta, tb := tokens(a), tokens(b)
+	shared := 0
+	for i, j := 0, 0; i < len(ta) && j < len(tb); {
+		switch x, y := ta[i], tb[j]; {
+		case x.h < y.h || (x.h == y.h && x.k < y.k):
+			i++
+		case y.h < x.h || (x.h == y.h && y.k < x.k):
+			j++
+		default:
+			shared++
+			i++
+			j++
+		}
+	}
+	if shared == 0 {
+		return 0
+	}
+	// 2*S / (2*S + L + R), where L+R = |a|+|b|-2*S.
+	return 2 * float64(shared) / float64(len(a)+len(b))
+}
+
+// normalize returns the normalized hash and size of the subtree rooted at the
+// given node, invoking f for each candidate fragment of the subtree. The
+// normalized hashes of the nodes of the subtree are appended to nodes in
+// post-order, so that the nodes of each candidate fragment are contiguous.
+func normalize(n *cc.Node, nodes *[]uint64, f func(c *candidate)) (uint64, int) {
+	first := len(*nodes)
+	h := fnv.New64a()
+	kind := n.Body.Kind()
+	fmt.Fprintf(h, "%d\x00", kind)
+	switch kind {
+	case clang.Cursor_BinaryOperator, clang.Cursor_CompoundAssignOperator, clang.Cursor_UnaryOperator:
+		// Operators are part of the structure; identifiers and literals are
+		// abstracted.
+		fmt.Fprintf(h, "%s\x00", n.Operator())
+	}
+	size := 1
+	for _, child := range n.Children {
+		ch, csize := normalize(child, nodes, f)
+		fmt.Fprintf(h, "%x\x00", ch)
+		size += csize
+	}
+	sum := h.Sum64()
+	*nodes = append(*nodes, sum)
+	if isFragmentRoot(kind) {
+		start, end := n.Extent()
+		c := &candidate{
+			frag: &Fragment{
+				Kind:  kind.Spelling(),
+				Start: start,
+				End:   end,
+				Size:  size,
+			},
+			hash: sum,
+			// Later appends do not modify the nodes of the subtree.
+			nodes: (*nodes)[first:len(*nodes):len(*nodes)],
+		}
+		f(c)
+	}
+	return sum, size
+}
+
+// isFragmentRoot reports whether nodes of the given kind are roots of
+// candidate code fragments.
+func isFragmentRoot(kind clang.CursorKind) bool {
+	switch kind {
+	case clang.Cursor_CompoundStmt, clang.Cursor_IfStmt, clang.Cursor_ForStmt, clang.Cursor_WhileStmt, clang.Cursor_DoStmt, clang.Cursor_SwitchStmt:
+		return true
+	}
+	return false
+}
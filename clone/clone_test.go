@@ -0,0 +1,118 @@
+package clone
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+func TestSimilarity(t *testing.T) {
+	golden := []struct {
+		a, b []uint64
+		want float64
+	}{
+		{a: []uint64{1, 2, 3}, b: []uint64{1, 2, 3}, want: 1},
+		{a: []uint64{1, 2, 3}, b: []uint64{4, 5}, want: 0},
+		// S = 2, L = 1, R = 1.
+		{a: []uint64{1, 2, 3}, b: []uint64{3, 1, 4}, want: 2 * 2.0 / (2*2 + 1 + 1)},
+		// Multiplicity: S = 2 (1, 1), L = 1 (1), R = 0.
+		{a: []uint64{1, 1, 1}, b: []uint64{1, 1}, want: 2 * 2.0 / (2*2 + 1)},
+	}
+	for _, g := range golden {
+		if got := similarity(g.a, g.b); got != g.want {
+			t.Errorf("%v vs %v: similarity mismatch; expected %v, got %v", g.a, g.b, g.want, got)
+		}
+	}
+}
+
+func TestSimilarPairs(t *testing.T) {
+	cands := []*candidate{
+		{frag: &Fragment{Size: 5}, nodes: []uint64{1, 2, 3, 4, 5}},
+		{frag: &Fragment{Size: 5}, nodes: []uint64{1, 2, 3, 4, 6}},
+		{frag: &Fragment{Size: 4}, nodes: []uint64{7, 8, 9, 10}},
+		{frag: &Fragment{Size: 4}, nodes: []uint64{1, 2, 3, 4}},
+	}
+	// Similarities: 0-1 0.8, 0-3 0.89, 1-3 0.89; candidate 2 shares nothing.
+	golden := [][2]int{{0, 1}, {0, 3}, {1, 3}}
+	got := similarPairs(cands, 0.8)
+	if fmt.Sprint(got) != fmt.Sprint(golden) {
+		t.Errorf("pairs mismatch; expected %v, got %v", golden, got)
+	}
+}
+
+func TestSimilarPairsUnequalSizes(t *testing.T) {
+	// Candidates of 100 and 70 nodes, of which 70 are shared; similarity
+	// 2*70/(100+70) = 0.82.
+	var large, small []uint64
+	for i := uint64(1); i <= 100; i++ {
+		large = append(large, i)
+		if i <= 70 {
+			small = append(small, i)
+		}
+	}
+	cands := []*candidate{
+		{frag: &Fragment{Size: len(large)}, nodes: large},
+		{frag: &Fragment{Size: len(small)}, nodes: small},
+	}
+	golden := [][2]int{{0, 1}}
+	got := similarPairs(cands, 0.8)
+	if fmt.Sprint(got) != fmt.Sprint(golden) {
+		t.Errorf("pairs mismatch; expected %v, got %v", golden, got)
+	}
+}
+
+// lines returns the comma-separated start lines of the fragments of the given
+// group.
+func lines(g *Group) string {
+	var ss []string
+	for _, f := range g.Fragments {
+		ss = append(ss, fmt.Sprint(f.Start.Line))
+	}
+	return strings.Join(ss, ",")
+}
+
+func TestDetect(t *testing.T) {
+	const src = `
+int f(int a, int b) { int x = a + b; if (x > 0) { x = x * 2; } return x - 1; }
+int g(int c, int d) { int y = c + d; if (y > 0) { y = y * 2; } return y - 1; }
+int h(int a, int b) { int x = a + b; if (x > 0) { x = x * 2; } x = x + 3; return x - 1; }
+int k(void) { while (1) { } return 0; }
+`
+	file, err := cc.ParseSource("clone.c", src)
+	if err != nil {
+		t.Fatalf("unable to parse source; %+v", err)
+	}
+	defer file.Close()
+	golden := []struct {
+		minSim float64
+		// Start lines of the fragments of the first group with the given
+		// similarity class.
+		exact, near string
+	}{
+		{minSim: 1, exact: "2,3"},
+		{minSim: 0.8, exact: "2,3", near: "2,4"},
+	}
+	for _, g := range golden {
+		groups := Detect([]*cc.File{file}, &Config{MinSize: 10, MinSimilarity: g.minSim})
+		var exact, near string
+		for _, group := range groups {
+			switch {
+			case group.Similarity == 1 && len(exact) == 0:
+				exact = lines(group)
+			case group.Similarity < 1 && len(near) == 0:
+				near = lines(group)
+				if group.Similarity < g.minSim {
+					t.Errorf("min similarity %v: similarity of group %q below minimum; got %v", g.minSim, near, group.Similarity)
+				}
+			}
+		}
+		if exact != g.exact {
+			t.Errorf("min similarity %v: exact clones mismatch; expected %q, got %q", g.minSim, g.exact, exact)
+		}
+		if !strings.HasPrefix(near, g.near) {
+			t.Errorf("min similarity %v: near-duplicates mismatch; expected %q, got %q", g.minSim, g.near, near)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// The apidiff tool reports source- and ABI-breaking changes between two
+// versions of a library's public API.
+//
+// Usage:
+//
+//	apidiff [OPTION]... OLD NEW
+//
+// OLD and NEW are header files or directories containing header files.
+//
+// Flags:
+//
+//	-args string
+//	      space-separated list of arguments passed to Clang
+//	-json
+//	      output report in JSON format
+//
+// The exit status is 1 if a breaking change is found.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/api"
+	"github.com/pkg/errors"
+)
+
+func usage() {
+	const use = `
+Report source- and ABI-breaking changes between two versions of a library's
+public API.
+
+Usage:
+
+	apidiff [OPTION]... OLD NEW
+
+OLD and NEW are header files or directories containing header files.
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		// Space-separated list of arguments passed to Clang.
+		clangArgs string
+		// Output report in JSON format.
+		jsonOutput bool
+	)
+	flag.StringVar(&clangArgs, "args", "", "space-separated list of arguments passed to Clang")
+	flag.BoolVar(&jsonOutput, "json", false, "output report in JSON format")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	args := append(append([]string(nil), cc.PreprocessingRecordArgs...), strings.Fields(clangArgs)...)
+	oldAPI, err := extract(flag.Arg(0), args)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	newAPI, err := extract(flag.Arg(1), args)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	report := api.Compare(oldAPI, newAPI)
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	} else {
+		for _, c := range report.Changes {
+			fmt.Println(c)
+		}
+	}
+	if report.SourceBreaking || report.ABIBreaking {
+		os.Exit(1)
+	}
+}
+
+// extract extracts the public API of the given header file or directory of
+// header files.
+func extract(path string, clangArgs []string) (*api.API, error) {
+	var headers []string
+	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".h", ".hh", ".hpp", ".hxx":
+			headers = append(headers, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(headers) == 0 {
+		return nil, errors.Errorf("unable to locate header files in %q", path)
+	}
+	var files []*cc.File
+	for _, header := range headers {
+		file, err := cc.ParseFile(header, clangArgs...)
+		if err != nil {
+			// Report diagnostics but continue with the partial AST.
+			log.Printf("%+v", err)
+		}
+		defer file.Close()
+		files = append(files, file)
+	}
+	return api.Extract(files...), nil
+}
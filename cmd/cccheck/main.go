@@ -0,0 +1,192 @@
+// The cccheck tool runs static checkers on C and C++ source files.
+//
+// Usage:
+//
+//	cccheck [OPTION]... [FILE]...
+//
+// If a compilation database is given, the source files of the compilation
+// database are checked; or only FILE... if given.
+//
+// Flags:
+//
+//	-args string
+//	      space-separated list of arguments passed to Clang
+//	-checks string
+//	      comma-separated list of checkers to run (default all)
+//	-config string
+//	      JSON checker configuration file
+//	-fix
+//	      fix issues in place, where supported (e.g. naming)
+//	-host string
+//	      host compiler whose system include directories and predefined macros are used (e.g. "gcc")
+//	-p string
+//	      compilation database (compile_commands.json or its directory)
+//	-std string
+//	      language standard of the host compiler environment (e.g. "c11")
+//
+// Diagnostics may be suppressed by a "cc:ignore" or "cc:ignore(RULE,...)"
+// comment on the same line or on the line before.
+//
+// The exit status is 1 if a diagnostic is reported.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/checker"
+	"github.com/mewspring/cc/compdb"
+)
+
+func usage() {
+	const use = `
+Run static checkers on C and C++ source files.
+
+Usage:
+
+	cccheck [OPTION]... [FILE]...
+
+If a compilation database is given, the source files of the compilation
+database are checked; or only FILE... if given.
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		// Space-separated list of arguments passed to Clang.
+		clangArgs string
+		// Comma-separated list of checkers to run.
+		checks string
+		// JSON checker configuration file.
+		configPath string
+		// Compilation database.
+		dbPath string
+		// Fix issues in place.
+		fix bool
+		// Host compiler.
+		host string
+		// Language standard of the host compiler environment.
+		std string
+	)
+	flag.StringVar(&clangArgs, "args", "", "space-separated list of arguments passed to Clang")
+	flag.StringVar(&checks, "checks", "", "comma-separated list of checkers to run (default all)")
+	flag.StringVar(&configPath, "config", "", "JSON checker configuration file")
+	flag.BoolVar(&fix, "fix", false, "fix issues in place, where supported (e.g. naming)")
+	flag.StringVar(&host, "host", "", `host compiler whose system include directories and predefined macros are used (e.g. "gcc")`)
+	flag.StringVar(&dbPath, "p", "", "compilation database (compile_commands.json or its directory)")
+	flag.StringVar(&std, "std", "", `language standard of the host compiler environment (e.g. "c11")`)
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 && len(dbPath) == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	config := &checker.Config{}
+	if len(configPath) > 0 {
+		var err error
+		config, err = checker.LoadConfig(configPath)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+	var names []string
+	if len(checks) > 0 {
+		names = strings.Split(checks, ",")
+	}
+	checkers, err := config.Checkers(names...)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	// Preprocessing record required for macro expansions.
+	env := &cc.Config{
+		Std:                 cc.Std(std),
+		PreprocessingRecord: true,
+		Args:                strings.Fields(clangArgs),
+	}
+	if len(host) > 0 {
+		env.Host, err = cc.DiscoverHost(host, env.Std)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+	args, err := env.ClangArgs()
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	files, err := parseFiles(dbPath, flag.Args(), args)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	diags := checker.Run(files, checkers...)
+	if fix {
+		for _, c := range checkers {
+			fixer, ok := c.(checker.Fixer)
+			if !ok {
+				continue
+			}
+			r, err := fixer.Fix(files)
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+			if err := r.WriteFiles(); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		}
+	}
+	for _, file := range files {
+		file.Close()
+	}
+	for _, d := range diags {
+		fmt.Println(d)
+	}
+	if len(diags) > 0 {
+		os.Exit(1)
+	}
+}
+
+// parseFiles parses the given source files, using the compile commands of the
+// given compilation database if present. If no source files are given, all
+// source files of the compilation database are parsed.
+func parseFiles(dbPath string, srcPaths, clangArgs []string) ([]*cc.File, error) {
+	var files []*cc.File
+	if len(dbPath) == 0 {
+		for _, srcPath := range srcPaths {
+			file, err := cc.ParseFile(srcPath, clangArgs...)
+			if err != nil {
+				log.Printf("%+v", err)
+			}
+			files = append(files, file)
+		}
+		return files, nil
+	}
+	cmds, err := compdb.Load(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	want := make(map[string]bool)
+	for _, srcPath := range srcPaths {
+		if path, err := filepath.Abs(srcPath); err == nil {
+			want[path] = true
+		}
+	}
+	for _, cmd := range cmds {
+		if len(want) > 0 && !want[cmd.File] {
+			continue
+		}
+		file, err := cmd.Parse(clangArgs...)
+		if err != nil {
+			log.Printf("%+v", err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -0,0 +1,74 @@
+// The ccclone tool reports exact and near-duplicate code fragments.
+//
+// Usage:
+//
+//	ccclone [OPTION]... FILE...
+//
+// Flags:
+//
+//	-args string
+//	      space-separated list of arguments passed to Clang
+//	-min-similarity float
+//	      minimum similarity of near-duplicate code fragments (default 0.8)
+//	-min-size int
+//	      minimum number of AST nodes of code fragments (default 20)
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/clone"
+)
+
+func usage() {
+	const use = `
+Report exact and near-duplicate code fragments.
+
+Usage:
+
+	ccclone [OPTION]... FILE...
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		// Space-separated list of arguments passed to Clang.
+		clangArgs string
+		// Clone detector configuration.
+		config = *clone.DefaultConfig
+	)
+	flag.StringVar(&clangArgs, "args", "", "space-separated list of arguments passed to Clang")
+	flag.Float64Var(&config.MinSimilarity, "min-similarity", config.MinSimilarity, "minimum similarity of near-duplicate code fragments")
+	flag.IntVar(&config.MinSize, "min-size", config.MinSize, "minimum number of AST nodes of code fragments")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	var files []*cc.File
+	for _, srcPath := range flag.Args() {
+		file, err := cc.ParseFile(srcPath, strings.Fields(clangArgs)...)
+		if err != nil {
+			// Report diagnostics but continue with the partial AST.
+			log.Printf("%+v", err)
+		}
+		defer file.Close()
+		files = append(files, file)
+	}
+	for i, g := range clone.Detect(files, &config) {
+		fmt.Printf("clone group %d (similarity %.2f):\n", i+1, g.Similarity)
+		for _, frag := range g.Fragments {
+			fmt.Printf("\t%s\n", frag)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// The ccdead tool reports functions, variables, types, enum constants and
+// macros defined in a program but never referenced.
+//
+// Usage:
+//
+//	ccdead [OPTION]... FILE...
+//
+// FILE... are the translation units of the program.
+//
+// Flags:
+//
+//	-args string
+//	      space-separated list of arguments passed to Clang
+//	-entries string
+//	      comma-separated list of entry points (default "main")
+//	-lib
+//	      treat functions and variables with external linkage as entry points
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/deadcode"
+)
+
+func usage() {
+	const use = `
+Report functions, variables, types, enum constants and macros defined in a
+program but never referenced.
+
+Usage:
+
+	ccdead [OPTION]... FILE...
+
+FILE... are the translation units of the program.
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		// Space-separated list of arguments passed to Clang.
+		clangArgs string
+		// Comma-separated list of entry points.
+		entries string
+		// Treat functions and variables with external linkage as entry points.
+		lib bool
+	)
+	flag.StringVar(&clangArgs, "args", "", "space-separated list of arguments passed to Clang")
+	flag.StringVar(&entries, "entries", "main", "comma-separated list of entry points")
+	flag.BoolVar(&lib, "lib", false, "treat functions and variables with external linkage as entry points")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	args := append(append([]string(nil), cc.PreprocessingRecordArgs...), strings.Fields(clangArgs)...)
+	var files []*cc.File
+	for _, srcPath := range flag.Args() {
+		file, err := cc.ParseFile(srcPath, args...)
+		if err != nil {
+			// Report diagnostics but continue with the partial AST.
+			log.Printf("%+v", err)
+		}
+		defer file.Close()
+		files = append(files, file)
+	}
+	config := &deadcode.Config{
+		Entries:        strings.Split(entries, ","),
+		ExportExternal: lib,
+	}
+	for _, d := range deadcode.Find(files, config) {
+		fmt.Printf("%s: %s %s is %s\n", d.Loc, d.Kind, d.Name, d.Reason)
+	}
+}
@@ -0,0 +1,73 @@
+// The ccdiff tool reports structural differences between two versions of a
+// source file.
+//
+// Usage:
+//
+//	ccdiff [OPTION]... OLD NEW
+//
+// Flags:
+//
+//	-args string
+//	      space-separated list of arguments passed to Clang
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/astdiff"
+)
+
+func usage() {
+	const use = `
+Report structural differences between two versions of a source file.
+
+Usage:
+
+	ccdiff [OPTION]... OLD NEW
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		// Space-separated list of arguments passed to Clang.
+		clangArgs string
+	)
+	flag.StringVar(&clangArgs, "args", "", "space-separated list of arguments passed to Clang")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	oldFile, err := cc.ParseFile(flag.Arg(0), strings.Fields(clangArgs)...)
+	if err != nil {
+		// Report diagnostics but continue with the partial AST.
+		log.Printf("%+v", err)
+	}
+	defer oldFile.Close()
+	newFile, err := cc.ParseFile(flag.Arg(1), strings.Fields(clangArgs)...)
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+	defer newFile.Close()
+	for _, action := range astdiff.Diff(oldFile.Root, newFile.Root) {
+		// Only report changes of the main files; not of included headers.
+		n := action.New
+		if n == nil {
+			n = action.Old
+		}
+		if !n.Body.Location().IsFromMainFile() {
+			continue
+		}
+		fmt.Println(action)
+	}
+}
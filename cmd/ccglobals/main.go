@@ -0,0 +1,139 @@
+// The ccglobals tool reports the global and static variables of a program, the
+// functions accessing them and the threads from which they are accessed.
+//
+// Usage:
+//
+//	ccglobals [OPTION]... FILE...
+//
+// FILE... are the translation units of the program.
+//
+// Flags:
+//
+//	-args string
+//	      space-separated list of arguments passed to Clang
+//	-entries string
+//	      comma-separated list of entry points (default functions without callers)
+//	-shared
+//	      only report variables accessed from more than one thread
+//	-threads string
+//	      comma-separated list of thread entry functions, in addition to start routines of thread creation functions
+//
+// The exit status is 1 if a variable is accessed from more than one thread.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/callgraph"
+	"github.com/mewspring/cc/globals"
+)
+
+func usage() {
+	const use = `
+Report the global and static variables of a program, the functions accessing
+them and the threads from which they are accessed.
+
+Usage:
+
+	ccglobals [OPTION]... FILE...
+
+FILE... are the translation units of the program.
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		// Space-separated list of arguments passed to Clang.
+		clangArgs string
+		// Comma-separated list of entry points.
+		entries string
+		// Only report variables accessed from more than one thread.
+		sharedOnly bool
+		// Comma-separated list of thread entry functions.
+		threads string
+	)
+	flag.StringVar(&clangArgs, "args", "", "space-separated list of arguments passed to Clang")
+	flag.StringVar(&entries, "entries", "", "comma-separated list of entry points (default functions without callers)")
+	flag.BoolVar(&sharedOnly, "shared", false, "only report variables accessed from more than one thread")
+	flag.StringVar(&threads, "threads", "", "comma-separated list of thread entry functions, in addition to start routines of thread creation functions")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	config := &globals.Config{}
+	if len(entries) > 0 {
+		config.Entries = strings.Split(entries, ",")
+	}
+	if len(threads) > 0 {
+		config.Threads = strings.Split(threads, ",")
+	}
+	var files []*cc.File
+	for _, srcPath := range flag.Args() {
+		file, err := cc.ParseFile(srcPath, strings.Fields(clangArgs)...)
+		if err != nil {
+			// Report diagnostics but continue with the partial AST.
+			log.Printf("%+v", err)
+		}
+		defer file.Close()
+		files = append(files, file)
+	}
+	r := globals.Analyze(files, config)
+	shared := false
+	for _, v := range r.Vars {
+		if v.Shared {
+			shared = true
+		} else if sharedOnly {
+			continue
+		}
+		var attrs []string
+		if v.Func != nil {
+			attrs = append(attrs, "static local of "+v.Func.Name)
+		} else {
+			attrs = append(attrs, v.Linkage.String()+" linkage")
+		}
+		if v.ThreadLocal {
+			attrs = append(attrs, "thread-local")
+		}
+		if v.Shared {
+			attrs = append(attrs, "shared")
+		}
+		fmt.Printf("%s: %s (%s)\n", v.Decl.Loc, v.Name, strings.Join(attrs, ", "))
+		for _, kind := range []globals.AccessKind{globals.AccessRead, globals.AccessWrite, globals.AccessAddr} {
+			if fs := v.Funcs(kind); len(fs) > 0 {
+				fmt.Printf("\t%s: %s\n", kind, funcNames(fs))
+			}
+		}
+		if len(v.Accesses) == 0 {
+			fmt.Println("\tnot accessed")
+		}
+		if len(v.Entries) > 0 {
+			fmt.Printf("\tentry points: %s\n", funcNames(v.Entries))
+		}
+		if len(v.Threads) > 0 {
+			fmt.Printf("\tthreads: %s\n", funcNames(v.Threads))
+		}
+	}
+	if shared {
+		os.Exit(1)
+	}
+}
+
+// funcNames returns the comma-separated names of the given functions.
+func funcNames(fs []*callgraph.Func) string {
+	var names []string
+	for _, f := range fs {
+		names = append(names, f.Name)
+	}
+	return strings.Join(names, ", ")
+}
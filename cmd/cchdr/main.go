@@ -0,0 +1,129 @@
+// The cchdr tool reports headers which fail to compile on their own, or which
+// lack an include guard or #pragma once.
+//
+// Usage:
+//
+//	cchdr [OPTION]... PATH...
+//
+// PATH... are header files or directories containing header files.
+//
+// Flags:
+//
+//	-I string
+//	      comma-separated list of include directories
+//	-args string
+//	      space-separated list of arguments passed to Clang
+//	-std string
+//	      language standard (e.g. c99, c++11)
+//
+// The exit status is 1 if a header fails a check.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/headercheck"
+	"github.com/pkg/errors"
+)
+
+func usage() {
+	const use = `
+Report headers which fail to compile on their own, or which lack an include
+guard or #pragma once.
+
+Usage:
+
+	cchdr [OPTION]... PATH...
+
+PATH... are header files or directories containing header files.
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		// Space-separated list of arguments passed to Clang.
+		clangArgs string
+		// Comma-separated list of include directories.
+		includeDirs string
+		// Language standard.
+		std string
+	)
+	flag.StringVar(&clangArgs, "args", "", "space-separated list of arguments passed to Clang")
+	flag.StringVar(&includeDirs, "I", "", "comma-separated list of include directories")
+	flag.StringVar(&std, "std", "", "language standard (e.g. c99, c++11)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	config := &cc.Config{
+		Std:  cc.Std(std),
+		Args: strings.Fields(clangArgs),
+	}
+	if len(includeDirs) > 0 {
+		config.IncludeDirs = strings.Split(includeDirs, ",")
+	}
+	headers, err := findHeaders(flag.Args())
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	failed := false
+	for _, header := range headers {
+		r, err := headercheck.CheckHeader(header, config)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		if r.OK() {
+			continue
+		}
+		failed = true
+		if r.MissingGuard {
+			fmt.Printf("%s: missing include guard or #pragma once\n", header)
+		}
+		if len(r.Errors) > 0 {
+			fmt.Printf("%s: not self-contained\n", header)
+			for _, e := range r.Errors {
+				fmt.Printf("\t%s\n", e)
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// findHeaders returns the header files of the given paths, recursively
+// searching directories.
+func findHeaders(paths []string) ([]string, error) {
+	var headers []string
+	for _, path := range paths {
+		err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			switch filepath.Ext(path) {
+			case ".h", ".hh", ".hpp", ".hxx", ".h++":
+				headers = append(headers, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return headers, nil
+}
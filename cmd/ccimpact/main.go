@@ -0,0 +1,131 @@
+// The ccimpact tool reports the declarations, callers and translation units
+// affected by a set of source code changes.
+//
+// Usage:
+//
+//	ccimpact [OPTION]... FILE...
+//
+// FILE... are the translation units of the program.
+//
+// Flags:
+//
+//	-args string
+//	      space-separated list of arguments passed to Clang
+//	-diff string
+//	      unified diff of changes ("-" for standard input)
+//	-git string
+//	      Git revisions to compare ("OLD..NEW", or "OLD" to compare against the working tree)
+//	-tus
+//	      only output affected translation units
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/impact"
+	"github.com/pkg/errors"
+)
+
+func usage() {
+	const use = `
+Report the declarations, callers and translation units affected by a set of
+source code changes.
+
+Usage:
+
+	ccimpact [OPTION]... FILE...
+
+FILE... are the translation units of the program.
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		// Space-separated list of arguments passed to Clang.
+		clangArgs string
+		// Unified diff of changes.
+		diffPath string
+		// Git revisions to compare.
+		revs string
+		// Only output affected translation units.
+		onlyTUs bool
+	)
+	flag.StringVar(&clangArgs, "args", "", "space-separated list of arguments passed to Clang")
+	flag.StringVar(&diffPath, "diff", "", `unified diff of changes ("-" for standard input)`)
+	flag.StringVar(&revs, "git", "", `Git revisions to compare ("OLD..NEW", or "OLD" to compare against the working tree)`)
+	flag.BoolVar(&onlyTUs, "tus", false, "only output affected translation units")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 || (len(diffPath) == 0) == (len(revs) == 0) {
+		flag.Usage()
+		os.Exit(1)
+	}
+	changes, err := parseChanges(diffPath, revs)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	args := append(append([]string(nil), cc.PreprocessingRecordArgs...), strings.Fields(clangArgs)...)
+	var files []*cc.File
+	for _, srcPath := range flag.Args() {
+		file, err := cc.ParseFile(srcPath, args...)
+		if err != nil {
+			// Report diagnostics but continue with the partial AST.
+			log.Printf("%+v", err)
+		}
+		defer file.Close()
+		files = append(files, file)
+	}
+	report := impact.Analyze(files, changes)
+	if onlyTUs {
+		for _, tu := range report.TUs {
+			fmt.Println(tu)
+		}
+		return
+	}
+	fmt.Println("changed declarations:")
+	for _, n := range report.Changed {
+		fmt.Printf("\t%s: %s %s\n", n.Loc, n.Body.Kind().Spelling(), n.Body.Spelling())
+	}
+	fmt.Println("affected callers:")
+	for _, f := range report.Callers {
+		if f.Def != nil {
+			fmt.Printf("\t%s: %s\n", f.Def.Loc, f.Name)
+		}
+	}
+	fmt.Println("affected translation units:")
+	for _, tu := range report.TUs {
+		fmt.Printf("\t%s\n", tu)
+	}
+}
+
+// parseChanges parses the changes of the given unified diff or Git revisions.
+func parseChanges(diffPath, revs string) ([]*impact.FileChange, error) {
+	if len(revs) > 0 {
+		parts := strings.SplitN(revs, "..", 2)
+		newRev := ""
+		if len(parts) == 2 {
+			newRev = parts[1]
+		}
+		return impact.GitDiff(".", parts[0], newRev)
+	}
+	var r io.Reader = os.Stdin
+	if diffPath != "-" {
+		f, err := os.Open(diffPath)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		defer f.Close()
+		r = f
+	}
+	return impact.ParseDiff(r)
+}
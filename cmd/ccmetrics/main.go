@@ -0,0 +1,113 @@
+// The ccmetrics tool reports code metrics of the functions of a program.
+//
+// Usage:
+//
+//	ccmetrics [OPTION]... FILE...
+//
+// FILE... are the translation units of the program.
+//
+// Flags:
+//
+//	-args string
+//	      space-separated list of arguments passed to Clang
+//	-files
+//	      output per-file metrics instead of per-function metrics (CSV only)
+//	-format string
+//	      output format (csv or json) (default "csv")
+//	-max-complexity int
+//	      maximum cyclomatic complexity of functions
+//	-max-nesting int
+//	      maximum nesting depth of functions
+//	-max-params int
+//	      maximum number of parameters of functions
+//	-max-statements int
+//	      maximum number of statements of functions
+//
+// Threshold violations are reported on standard error, and the exit status is
+// 1 if a threshold is exceeded.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/metrics"
+)
+
+func usage() {
+	const use = `
+Report code metrics of the functions of a program.
+
+Usage:
+
+	ccmetrics [OPTION]... FILE...
+
+FILE... are the translation units of the program.
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		// Space-separated list of arguments passed to Clang.
+		clangArgs string
+		// Output per-file metrics.
+		perFile bool
+		// Output format.
+		format string
+		// Thresholds of function metrics.
+		thresholds metrics.Thresholds
+	)
+	flag.StringVar(&clangArgs, "args", "", "space-separated list of arguments passed to Clang")
+	flag.BoolVar(&perFile, "files", false, "output per-file metrics instead of per-function metrics (CSV only)")
+	flag.StringVar(&format, "format", "csv", "output format (csv or json)")
+	flag.IntVar(&thresholds.Complexity, "max-complexity", 0, "maximum cyclomatic complexity of functions")
+	flag.IntVar(&thresholds.Nesting, "max-nesting", 0, "maximum nesting depth of functions")
+	flag.IntVar(&thresholds.Params, "max-params", 0, "maximum number of parameters of functions")
+	flag.IntVar(&thresholds.Statements, "max-statements", 0, "maximum number of statements of functions")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	var files []*cc.File
+	for _, srcPath := range flag.Args() {
+		file, err := cc.ParseFile(srcPath, strings.Fields(clangArgs)...)
+		if err != nil {
+			// Report diagnostics but continue with the partial AST.
+			log.Printf("%+v", err)
+		}
+		defer file.Close()
+		files = append(files, file)
+	}
+	r := metrics.Compute(files...)
+	var err error
+	switch {
+	case format == "json":
+		err = r.WriteJSON(os.Stdout)
+	case format == "csv" && perFile:
+		err = r.WriteFilesCSV(os.Stdout)
+	case format == "csv":
+		err = r.WriteFuncsCSV(os.Stdout)
+	default:
+		log.Fatalf("unknown output format %q", format)
+	}
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	violations := r.Check(&thresholds)
+	for _, v := range violations {
+		fmt.Fprintln(os.Stderr, v)
+	}
+	if len(violations) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,92 @@
+// The ccport tool reports portability differences of source files between
+// compilation targets.
+//
+// Usage:
+//
+//	ccport [OPTION]... FILE...
+//
+// Flags:
+//
+//	-args string
+//	      space-separated list of arguments passed to Clang
+//	-std string
+//	      language standard (e.g. c99, c++11)
+//	-targets string
+//	      comma-separated list of target triples (default "x86_64-linux-gnu,i386-linux-gnu,armv7-linux-gnueabihf,aarch64-linux-gnu")
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/portability"
+)
+
+func usage() {
+	const use = `
+Report portability differences of source files between compilation targets.
+
+Usage:
+
+	ccport [OPTION]... FILE...
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		// Space-separated list of arguments passed to Clang.
+		clangArgs string
+		// Language standard.
+		std string
+		// Comma-separated list of target triples.
+		targets string
+	)
+	flag.StringVar(&clangArgs, "args", "", "space-separated list of arguments passed to Clang")
+	flag.StringVar(&std, "std", "", "language standard (e.g. c99, c++11)")
+	flag.StringVar(&targets, "targets", "x86_64-linux-gnu,i386-linux-gnu,armv7-linux-gnueabihf,aarch64-linux-gnu", "comma-separated list of target triples")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	config := &cc.Config{
+		Std:  cc.Std(std),
+		Args: strings.Fields(clangArgs),
+	}
+	triples := strings.Split(targets, ",")
+	r, err := portability.Analyze(flag.Args(), triples, config)
+	if err != nil {
+		// Report diagnostics but continue with the partial report.
+		log.Printf("%+v", err)
+	}
+	for _, t := range r.Types {
+		for _, diff := range t.Differences(r.Triples) {
+			fmt.Printf("%s: %s: %s\n", t.Loc, t.Name, diff)
+		}
+	}
+	for _, e := range r.Code {
+		desc := e.Kind
+		if len(e.Name) > 0 {
+			desc += " " + e.Name
+		}
+		var present []string
+		for i, ok := range e.Present {
+			if ok {
+				present = append(present, r.Triples[i])
+			}
+		}
+		fmt.Printf("%s: %s only compiled for [%s]\n", e.Loc, desc, strings.Join(present, ", "))
+	}
+	for _, b := range r.Dead {
+		fmt.Printf("%s: never compiled for any target\n", b)
+	}
+}
@@ -0,0 +1,136 @@
+// The ccstack tool estimates the worst-case stack usage of the entry points of
+// a program.
+//
+// Usage:
+//
+//	ccstack [OPTION]... FILE...
+//
+// FILE... are the translation units of the program.
+//
+// Flags:
+//
+//	-align int
+//	      alignment in bytes of stack frames (default 8)
+//	-args string
+//	      space-separated list of arguments passed to Clang
+//	-entries string
+//	      comma-separated list of entry points (default functions without callers)
+//	-frames
+//	      output the estimated stack frames of all functions
+//	-limit int
+//	      stack size limit in bytes
+//	-overhead int
+//	      size in bytes of the fixed overhead of each call (default 8)
+//
+// The exit status is 1 if the stack depth of an entry point is unbounded or
+// exceeds the stack size limit.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/stack"
+)
+
+func usage() {
+	const use = `
+Estimate the worst-case stack usage of the entry points of a program.
+
+Usage:
+
+	ccstack [OPTION]... FILE...
+
+FILE... are the translation units of the program.
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		// Space-separated list of arguments passed to Clang.
+		clangArgs string
+		// Comma-separated list of entry points.
+		entries string
+		// Output the estimated stack frames of all functions.
+		showFrames bool
+		// Stack size limit in bytes.
+		limit int64
+		// Stack usage configuration.
+		config stack.Config
+	)
+	flag.Int64Var(&config.Align, "align", stack.DefaultConfig.Align, "alignment in bytes of stack frames")
+	flag.StringVar(&clangArgs, "args", "", "space-separated list of arguments passed to Clang")
+	flag.StringVar(&entries, "entries", "", "comma-separated list of entry points (default functions without callers)")
+	flag.BoolVar(&showFrames, "frames", false, "output the estimated stack frames of all functions")
+	flag.Int64Var(&limit, "limit", 0, "stack size limit in bytes")
+	flag.Int64Var(&config.CallOverhead, "overhead", stack.DefaultConfig.CallOverhead, "size in bytes of the fixed overhead of each call")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if len(entries) > 0 {
+		config.Entries = strings.Split(entries, ",")
+	}
+	var files []*cc.File
+	for _, srcPath := range flag.Args() {
+		file, err := cc.ParseFile(srcPath, strings.Fields(clangArgs)...)
+		if err != nil {
+			// Report diagnostics but continue with the partial AST.
+			log.Printf("%+v", err)
+		}
+		defer file.Close()
+		files = append(files, file)
+	}
+	r := stack.Analyze(files, &config)
+	if showFrames {
+		fmt.Println("frames:")
+		for _, frame := range r.Frames {
+			fmt.Printf("\t%s: %s: %d bytes\n", frame.Func.Def.Loc, frame.Func.Name, frame.Size)
+			for _, local := range frame.Locals {
+				fmt.Printf("\t\t%s: %d bytes at offset %d\n", local.Name, local.Size, local.Offset)
+			}
+		}
+		fmt.Println("entry points:")
+	}
+	exceeded := false
+	for _, u := range r.Usages {
+		bound := ""
+		if len(u.Unbounded) > 0 {
+			bound = "at least "
+			exceeded = true
+		}
+		fmt.Printf("%s: %s: %s%d bytes\n", u.Entry.Def.Loc, u.Entry.Name, bound, u.Depth)
+		var path []string
+		for _, f := range u.Path {
+			path = append(path, f.Name)
+		}
+		fmt.Printf("\tpath: %s\n", strings.Join(path, " -> "))
+		for _, reason := range u.Unbounded {
+			fmt.Printf("\tunbounded: %s\n", reason)
+		}
+		if len(u.Unknown) > 0 {
+			var names []string
+			for _, f := range u.Unknown {
+				names = append(names, f.Name)
+			}
+			fmt.Printf("\tunknown stack usage: %s\n", strings.Join(names, ", "))
+		}
+		if limit > 0 && u.Depth > limit {
+			fmt.Printf("\texceeds stack size limit of %d bytes\n", limit)
+			exceeded = true
+		}
+	}
+	if exceeded {
+		os.Exit(1)
+	}
+}
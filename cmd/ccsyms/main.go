@@ -0,0 +1,76 @@
+// The ccsyms tool reports the externally visible symbols of a project.
+//
+// Usage:
+//
+//	ccsyms [OPTION]... FILE...
+//
+// Flags:
+//
+//	-args string
+//	      space-separated list of arguments passed to Clang
+//	-undef
+//	      only report symbols declared but not defined in the given files
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mewspring/cc"
+)
+
+func usage() {
+	const use = `
+Report the externally visible symbols of a project.
+
+Usage:
+
+	ccsyms [OPTION]... FILE...
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		// Space-separated list of arguments passed to Clang.
+		clangArgs string
+		// Only report undefined symbols.
+		undef bool
+	)
+	flag.StringVar(&clangArgs, "args", "", "space-separated list of arguments passed to Clang")
+	flag.BoolVar(&undef, "undef", false, "only report symbols declared but not defined in the given files")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	var files []*cc.File
+	for _, srcPath := range flag.Args() {
+		file, err := cc.ParseFile(srcPath, strings.Fields(clangArgs)...)
+		if err != nil {
+			// Report diagnostics but continue with the partial AST.
+			log.Printf("%+v", err)
+		}
+		defer file.Close()
+		files = append(files, file)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "name\tkind\tstorage\tvisibility\tinline\ttls\tdefined\tlocation")
+	for _, sym := range cc.ExternalSymbols(files...) {
+		if undef && sym.Defined {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%t\t%t\t%s\n", sym.Name, sym.Decl.Body.Kind().Spelling(), sym.StorageClass, sym.Visibility, sym.Inline, sym.ThreadLocal, sym.Defined, sym.Decl.Loc)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("%+v", err)
+	}
+}
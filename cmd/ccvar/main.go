@@ -0,0 +1,111 @@
+// The ccvar tool reports which declarations and statements of a source file
+// exist in which macro configurations, and which conditionally compiled code
+// is never compiled under any configuration.
+//
+// Usage:
+//
+//	ccvar [OPTION]... FILE
+//
+// Flags:
+//
+//	-args string
+//	      space-separated list of arguments passed to Clang
+//	-config value
+//	      macro configuration as NAME=ARGS (e.g. "tls=-DUSE_TLS -UNO_SSL"); may be repeated
+//	-v    also report declarations and statements present in all configurations
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mewspring/cc/variability"
+	"github.com/pkg/errors"
+)
+
+func usage() {
+	const use = `
+Report which declarations and statements of a source file exist in which macro
+configurations.
+
+Usage:
+
+	ccvar [OPTION]... FILE
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		// Space-separated list of arguments passed to Clang.
+		clangArgs string
+		// Macro configurations.
+		configs configFlag
+		// Also report common declarations and statements.
+		verbose bool
+	)
+	flag.StringVar(&clangArgs, "args", "", "space-separated list of arguments passed to Clang")
+	flag.Var(&configs, "config", `macro configuration as NAME=ARGS (e.g. "tls=-DUSE_TLS -UNO_SSL"); may be repeated`)
+	flag.BoolVar(&verbose, "v", false, "also report declarations and statements present in all configurations")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 || len(configs) == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	r, err := variability.Analyze(flag.Arg(0), configs, strings.Fields(clangArgs)...)
+	if err != nil {
+		// Report diagnostics but continue with the partial result.
+		log.Printf("%+v", err)
+	}
+	printEntity := func(e *variability.Entity) {
+		desc := e.Kind
+		if len(e.Name) > 0 {
+			desc += " " + e.Name
+		}
+		fmt.Printf("%s: %s [%s]\n", e.Loc, desc, strings.Join(e.Configs(r.Configs), ", "))
+	}
+	if verbose {
+		for _, e := range r.Common {
+			printEntity(e)
+		}
+	}
+	for _, e := range r.Variable {
+		printEntity(e)
+	}
+	for _, b := range r.Dead {
+		fmt.Printf("%s: never compiled\n", b)
+	}
+}
+
+// configFlag is a repeatable flag of macro configurations.
+type configFlag []*variability.Config
+
+// String returns a string representation of the macro configurations.
+func (cs *configFlag) String() string {
+	var ss []string
+	for _, c := range *cs {
+		ss = append(ss, fmt.Sprintf("%s=%s", c.Name, strings.Join(c.Args, " ")))
+	}
+	return strings.Join(ss, "; ")
+}
+
+// Set adds the given NAME=ARGS macro configuration.
+func (cs *configFlag) Set(s string) error {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return errors.Errorf("invalid configuration %q; expected NAME=ARGS", s)
+	}
+	c := &variability.Config{
+		Name: parts[0],
+		Args: strings.Fields(parts[1]),
+	}
+	*cs = append(*cs, c)
+	return nil
+}
@@ -0,0 +1,48 @@
+package cc
+
+import "strings"
+
+// StripComments returns the given source code with C and C++ comments replaced
+// by a space. Line breaks of block comments are preserved, and comment
+// delimiters within string and character literals are left intact.
+func StripComments(src string) string {
+	var sb strings.Builder
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '"' || c == '\'':
+			// String or character literal; ends at the matching unescaped
+			// quote or at the end of the line.
+			j := i + 1
+			for ; j < len(src) && src[j] != c && src[j] != '\n'; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+			}
+			if j < len(src) && src[j] == c {
+				j++
+			}
+			sb.WriteString(src[i:j])
+			i = j - 1
+		case strings.HasPrefix(src[i:], "//"):
+			j := strings.IndexByte(src[i:], '\n')
+			if j == -1 {
+				j = len(src) - i
+			}
+			sb.WriteByte(' ')
+			i += j - 1
+		case strings.HasPrefix(src[i:], "/*"):
+			j := strings.Index(src[i+2:], "*/")
+			end := len(src)
+			if j != -1 {
+				end = i + 2 + j + len("*/")
+			}
+			sb.WriteByte(' ')
+			sb.WriteString(strings.Repeat("\n", strings.Count(src[i:end], "\n")))
+			i = end - 1
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
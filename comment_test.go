@@ -0,0 +1,20 @@
+package cc
+
+import "testing"
+
+func TestStripComments(t *testing.T) {
+	golden := []struct {
+		src, want string
+	}{
+		{src: "a // b\nc", want: "a  \nc"},
+		{src: "a /* b\nc */ d", want: "a  \n d"},
+		{src: `s = "/* x */"; // y`, want: `s = "/* x */";  `},
+		{src: `c = '"'; /* z */`, want: `c = '"';  `},
+		{src: `s = "\" // q"; r`, want: `s = "\" // q"; r`},
+	}
+	for i, g := range golden {
+		if got := StripComments(g.src); got != g.want {
+			t.Errorf("test %d: stripped source mismatch; expected %q, got %q", i, g.want, got)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Package compdb parses JSON compilation databases (compile_commands.json).
+//
+// See https://clang.llvm.org/docs/JSONCompilationDatabase.html
+package compdb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/pkg/errors"
+)
+
+// Command is a compile command of a compilation database.
+type Command struct {
+	// Working directory of the compile command.
+	Directory string
+	// Absolute path of the main source file of the compile command.
+	File string
+	// Compile command arguments, including the compiler executable.
+	Args []string
+}
+
+// entry is an entry of a JSON compilation database.
+type entry struct {
+	Directory string   `json:"directory"`
+	File      string   `json:"file"`
+	Command   string   `json:"command"`
+	Arguments []string `json:"arguments"`
+}
+
+// Load loads the given compilation database. If path is a directory, the
+// compilation database compile_commands.json of the directory is loaded.
+func Load(path string) ([]*Command, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "compile_commands.json")
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var entries []*entry
+	if err := json.Unmarshal(buf, &entries); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse compilation database %q", path)
+	}
+	var cmds []*Command
+	for _, e := range entries {
+		args := e.Arguments
+		if len(args) == 0 {
+			args = splitCommand(e.Command)
+		}
+		file := e.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(e.Directory, file)
+		}
+		cmd := &Command{
+			Directory: e.Directory,
+			File:      filepath.Clean(file),
+			Args:      args,
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+// ClangArgs returns the Clang arguments of the compile command, suitable for
+// cc.ParseFile. The compiler executable, the main source file and output
+// related arguments are omitted, and relative paths of include related
+// arguments are resolved against the working directory of the compile
+// command.
+func (cmd *Command) ClangArgs() []string {
+	var args []string
+	if len(cmd.Args) == 0 {
+		return nil
+	}
+	// Skip compiler executable.
+	in := cmd.Args[1:]
+	for i := 0; i < len(in); i++ {
+		arg := in[i]
+		switch arg {
+		case "-c", "-S", "-E", "-M", "-MM", "-MD", "-MMD", "-MP":
+			continue
+		case "-o", "-MF", "-MT", "-MQ":
+			// Skip output argument and its value.
+			i++
+			continue
+		case "-I", "-isystem", "-iquote", "-idirafter", "-include", "-imacros", "--sysroot", "-isysroot":
+			if i+1 < len(in) {
+				i++
+				args = append(args, arg, cmd.abs(in[i]))
+			}
+			continue
+		}
+		if cmd.isSourceFile(arg) {
+			continue
+		}
+		if strings.HasPrefix(arg, "-o") {
+			continue
+		}
+		for _, prefix := range []string{"-I", "-isystem", "-iquote", "-idirafter", "--sysroot="} {
+			if strings.HasPrefix(arg, prefix) && len(arg) > len(prefix) {
+				arg = prefix + cmd.abs(arg[len(prefix):])
+				break
+			}
+		}
+		args = append(args, arg)
+	}
+	return args
+}
+
+// isSourceFile reports whether the given argument denotes the main source file
+// of the compile command.
+func (cmd *Command) isSourceFile(arg string) bool {
+	if strings.HasPrefix(arg, "-") {
+		return false
+	}
+	return cmd.abs(arg) == cmd.File
+}
+
+// abs resolves the given path against the working directory of the compile
+// command.
+func (cmd *Command) abs(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(cmd.Directory, path)
+}
+
+// Parse parses the main source file of the compile command. Note, a (partial)
+// AST is returned even when an error is encountered.
+func (cmd *Command) Parse(extraArgs ...string) (*cc.File, error) {
+	return cc.ParseFile(cmd.File, append(cmd.ClangArgs(), extraArgs...)...)
+}
+
+// splitCommand splits the given shell command into arguments, handling single
+// and double quotes and backslash escapes.
+func splitCommand(command string) []string {
+	var (
+		args []string
+		arg  strings.Builder
+		// Current argument is non-empty or quoted.
+		inArg bool
+		// Current quote character; or 0 if unquoted.
+		quote rune
+		// Previous character was a backslash.
+		escaped bool
+	)
+	for _, r := range command {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
@@ -0,0 +1,65 @@
+package compdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	golden := []struct {
+		command string
+		want    []string
+	}{
+		{command: "cc -c foo.c", want: []string{"cc", "-c", "foo.c"}},
+		{command: `cc -DMSG="hello world" foo.c`, want: []string{"cc", "-DMSG=hello world", "foo.c"}},
+		{command: `cc '-DA=\n' foo.c`, want: []string{"cc", `-DA=\n`, "foo.c"}},
+		{command: `cc -DA=a\ b ""`, want: []string{"cc", "-DA=a b", ""}},
+	}
+	for _, g := range golden {
+		got := splitCommand(g.command)
+		if strings.Join(got, "|") != strings.Join(g.want, "|") || len(got) != len(g.want) {
+			t.Errorf("%q: arguments mismatch; expected %q, got %q", g.command, g.want, got)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	const db = `[
+	{"directory": "/src", "file": "foo.c", "command": "cc -c -Iinc -I /abs -isystem sys -o foo.o foo.c -DX=1"},
+	{"directory": "/src", "file": "/src/bar.c", "arguments": ["cc", "-MD", "-MF", "bar.d", "-c", "bar.c"]}
+]`
+	dir, err := ioutil.TempDir("", "compdb")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "compile_commands.json"), []byte(db), 0644); err != nil {
+		t.Fatalf("unable to write compilation database; %+v", err)
+	}
+	cmds, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unable to load compilation database; %+v", err)
+	}
+	golden := []struct {
+		file string
+		args []string
+	}{
+		{file: "/src/foo.c", args: []string{"-I/src/inc", "-I", "/abs", "-isystem", "/src/sys", "-DX=1"}},
+		{file: "/src/bar.c", args: nil},
+	}
+	if len(cmds) != len(golden) {
+		t.Fatalf("number of commands mismatch; expected %d, got %d", len(golden), len(cmds))
+	}
+	for i, g := range golden {
+		cmd := cmds[i]
+		if cmd.File != g.file {
+			t.Errorf("command %d: file mismatch; expected %q, got %q", i, g.file, cmd.File)
+		}
+		if got := cmd.ClangArgs(); strings.Join(got, " ") != strings.Join(g.args, " ") {
+			t.Errorf("command %d: Clang arguments mismatch; expected %q, got %q", i, g.args, got)
+		}
+	}
+}
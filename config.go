@@ -0,0 +1,202 @@
+package cc
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Config is a configuration of the compiler environment used to parse source
+// files.
+type Config struct {
+	// Target triple (e.g. "x86_64-linux-gnu", "armv7-none-eabi"); or empty for
+	// the target of the host compiler environment if present, and the default
+	// target of Clang otherwise.
+	Target string
+	// Language standard; or empty for the default of Clang.
+	Std Std
+	// Macros to define, as NAME or NAME=VALUE (-D). Macros are defined before
+	// undefined.
+	Defines []string
+	// Macros to undefine (-U).
+	Undefines []string
+	// Include directories (-I), in search order.
+	IncludeDirs []string
+	// System include directories (-isystem), in search order.
+	SystemIncludeDirs []string
+	// Logical root directory for headers and libraries (--sysroot).
+	Sysroot string
+	// Compiler environment of host compiler; or nil to use the built-in
+	// environment of Clang. If present, the system include directories of the
+	// host compiler replace those of Clang, and the predefined macros of the
+	// host compiler (except for those identifying the compiler and language
+	// standard, e.g. __GNUC__) replace those of Clang.
+	Host *Host
+	// Enable the detailed preprocessing record (see PreprocessingRecordArgs).
+	PreprocessingRecord bool
+	// Additional arguments passed to Clang.
+	Args []string
+}
+
+// ClangArgs returns the Clang arguments of the configuration.
+func (c *Config) ClangArgs() ([]string, error) {
+	var args []string
+	target := c.Target
+	if len(target) == 0 && c.Host != nil {
+		target = c.Host.Target
+	}
+	if len(target) > 0 {
+		args = append(args, "-target", target)
+	}
+	if len(c.Std) > 0 {
+		std, err := c.Std.clangStd()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if c.Std.IsCXX() {
+			args = append(args, "-x", "c++")
+		} else {
+			args = append(args, "-x", "c")
+		}
+		args = append(args, "-std="+std)
+	}
+	if len(c.Sysroot) > 0 {
+		args = append(args, "--sysroot", c.Sysroot)
+	}
+	if c.Host != nil {
+		// Replace the system include directories of Clang with those of the
+		// host compiler.
+		args = append(args, "-nostdinc")
+		// Replace the predefined macros of Clang with those of the host
+		// compiler. Macros identifying the compiler (e.g. __GNUC__ of GCC 12)
+		// are kept as predefined by Clang, as system headers select features
+		// (e.g. _Float128) not supported by Clang 3.9 based on them.
+		for _, macro := range c.Host.Macros {
+			name := macroName(macro)
+			if isCompilerMacro(name) {
+				continue
+			}
+			args = append(args, "-U"+name, "-D"+macro)
+		}
+	}
+	for _, macro := range c.Defines {
+		args = append(args, "-D"+macro)
+	}
+	for _, macro := range c.Undefines {
+		args = append(args, "-U"+macro)
+	}
+	for _, dir := range c.IncludeDirs {
+		args = append(args, "-I"+dir)
+	}
+	for _, dir := range c.SystemIncludeDirs {
+		args = append(args, "-isystem", dir)
+	}
+	if c.Host != nil {
+		for _, dir := range c.Host.IncludeDirs {
+			args = append(args, "-isystem", dir)
+		}
+	}
+	if c.PreprocessingRecord {
+		args = append(args, PreprocessingRecordArgs...)
+	}
+	args = append(args, c.Args...)
+	return args, nil
+}
+
+// macroName returns the name of the given macro definition, as NAME=VALUE or
+// NAME(PARAMS)=VALUE.
+func macroName(macro string) string {
+	if i := strings.IndexAny(macro, "(="); i != -1 {
+		return macro[:i]
+	}
+	return macro
+}
+
+// isCompilerMacro reports whether the given predefined macro identifies the
+// compiler, its version or the language standard (e.g. __GNUC__, __VERSION__,
+// __STDC_VERSION__).
+func isCompilerMacro(name string) bool {
+	prefixes := []string{
+		"__GNUC",
+		"__GNUG__",
+		"__GXX_",
+		"__GCC_",
+		"__VERSION__",
+		"__clang",
+		"__llvm__",
+		"__apple_build_version__",
+		"__STDC",
+		"__cplusplus",
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseFileWithConfig parses the given source file using the given compiler
+// environment configuration. Note, a (partial) AST is returned even when a
+// parse error is encountered.
+func ParseFileWithConfig(srcPath string, config *Config) (*File, error) {
+	args, err := config.ClangArgs()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ParseFile(srcPath, args...)
+}
+
+// Std is a language standard.
+type Std string
+
+// Language standards.
+const (
+	// C standards.
+	StdC89 Std = "c89"
+	StdC99 Std = "c99"
+	StdC11 Std = "c11"
+	StdC17 Std = "c17"
+	// C standards with GNU extensions.
+	StdGNU89 Std = "gnu89"
+	StdGNU99 Std = "gnu99"
+	StdGNU11 Std = "gnu11"
+	StdGNU17 Std = "gnu17"
+	// C++ standards.
+	StdCXX98 Std = "c++98"
+	StdCXX03 Std = "c++03"
+	StdCXX11 Std = "c++11"
+	StdCXX14 Std = "c++14"
+	StdCXX17 Std = "c++17"
+	// C++ standards with GNU extensions.
+	StdGNUXX98 Std = "gnu++98"
+	StdGNUXX11 Std = "gnu++11"
+	StdGNUXX14 Std = "gnu++14"
+	StdGNUXX17 Std = "gnu++17"
+)
+
+// IsCXX reports whether the language standard is a C++ standard.
+func (std Std) IsCXX() bool {
+	return strings.Contains(string(std), "++")
+}
+
+// clangStd returns the name of the language standard as supported by Clang
+// 3.9. C17 and C++17 are mapped to the corresponding standards of Clang 3.9
+// (c11 and c++1z); other standards unknown to Clang 3.9 (e.g. c2x) are
+// reported as an error.
+func (std Std) clangStd() (string, error) {
+	switch std {
+	case StdC89, StdC99, StdC11, StdGNU89, StdGNU99, StdGNU11, StdCXX98, StdCXX03, StdCXX11, StdCXX14, StdGNUXX98, StdGNUXX11, StdGNUXX14:
+		return string(std), nil
+	case StdC17:
+		// C17 only contains defect fixes of C11.
+		return string(StdC11), nil
+	case StdGNU17:
+		return string(StdGNU11), nil
+	case StdCXX17:
+		return "c++1z", nil
+	case StdGNUXX17:
+		return "gnu++1z", nil
+	}
+	return "", errors.Errorf("unsupported language standard %q", std)
+}
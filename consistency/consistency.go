@@ -0,0 +1,396 @@
+// Package consistency reports inconsistencies between declarations and
+// definitions of symbols across translation units.
+//
+// The following inconsistencies are reported:
+//
+//   - functions and global variables whose declarations and definitions
+//     disagree on type (e.g. parameter types, return type, const-ness);
+//   - names declared with external linkage in one translation unit and defined
+//     with internal linkage in another;
+//   - One Definition Rule violations, where a struct or union type with the
+//     same name has different layouts in different translation units.
+package consistency
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/index"
+)
+
+// Kind specifies the kind of an inconsistency.
+type Kind string
+
+// Kinds of inconsistencies.
+const (
+	// Declarations disagree on type.
+	TypeMismatch Kind = "type mismatch"
+	// Declarations disagree on linkage.
+	LinkageMismatch Kind = "linkage mismatch"
+	// Definitions of type disagree on layout.
+	ODRViolation Kind = "ODR violation"
+)
+
+// Issue is an inconsistency between declarations of a symbol.
+type Issue struct {
+	// Kind of inconsistency.
+	Kind Kind
+	// Symbol name.
+	Name string
+	// Description of the inconsistency.
+	Desc string
+	// Inconsistent declarations.
+	Decls []*Decl
+}
+
+// Decl is a declaration involved in an inconsistency.
+type Decl struct {
+	// Source location of declaration.
+	Loc cc.Location
+	// Path of the translation unit.
+	TU string
+	// Declaration summary (e.g. type or layout of declaration).
+	Summary string
+}
+
+// String returns a string representation of the inconsistency.
+func (issue *Issue) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s of %s: %s", issue.Kind, issue.Name, issue.Desc)
+	for _, decl := range issue.Decls {
+		fmt.Fprintf(&sb, "\n\t%s (in %s): %s", decl.Loc, decl.TU, decl.Summary)
+	}
+	return sb.String()
+}
+
+// Check reports the inconsistencies between the declarations of the symbols of
+// the given index, sorted by symbol name.
+func Check(idx *index.Index) []*Issue {
+	var issues []*Issue
+	for _, sym := range idx.Sorted() {
+		switch sym.Kind {
+		case clang.Cursor_FunctionDecl, clang.Cursor_VarDecl:
+			if issue := checkTypes(sym); issue != nil {
+				issues = append(issues, issue)
+			}
+		case clang.Cursor_StructDecl, clang.Cursor_UnionDecl, clang.Cursor_ClassDecl:
+			if issue := checkLayouts(sym); issue != nil {
+				issues = append(issues, issue)
+			}
+		}
+	}
+	issues = append(issues, checkLinkage(idx)...)
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Name < issues[j].Name
+	})
+	return issues
+}
+
+// checkTypes checks that the declarations of the given function or global
+// variable have compatible types.
+func checkTypes(sym *index.Symbol) *Issue {
+	var decls []*Decl
+	var types []clang.Type
+	seen := make(map[string]bool)
+	for _, occ := range sym.Decls {
+		n := occ.Node
+		if n.Body.Kind() == clang.Cursor_VarDecl && n.Linkage() != cc.LinkageExternal {
+			// Local variables are not subject to cross translation unit
+			// consistency.
+			return nil
+		}
+		types = append(types, n.Body.Type())
+		typ := n.Body.Type().CanonicalType().Spelling()
+		// Report each distinct declaration once, even if included by several
+		// translation units.
+		key := n.Loc.String() + "\x00" + typ
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		decls = append(decls, &Decl{Loc: n.Loc, TU: occ.TU, Summary: typ})
+	}
+	if allCompatible(types) {
+		return nil
+	}
+	return &Issue{
+		Kind:  TypeMismatch,
+		Name:  sym.Name,
+		Desc:  typeMismatchDesc(sym),
+		Decls: decls,
+	}
+}
+
+// typeMismatchDesc describes how the declarations of the given function or
+// global variable disagree on type.
+func typeMismatchDesc(sym *index.Symbol) string {
+	if sym.Kind == clang.Cursor_VarDecl {
+		first := sym.Decls[0].Node.Body.Type()
+		for _, occ := range sym.Decls[1:] {
+			t := occ.Node.Body.Type()
+			if t.IsConstQualifiedType() != first.IsConstQualifiedType() {
+				return "declarations disagree on const-ness"
+			}
+		}
+		return "declarations disagree on type"
+	}
+	var diffs []string
+	first := sym.Decls[0].Node.Body.Type()
+	add := func(diff string) {
+		for _, d := range diffs {
+			if d == diff {
+				return
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	for _, occ := range sym.Decls[1:] {
+		t := occ.Node.Body.Type()
+		if !compatible(t.ResultType(), first.ResultType()) {
+			add("return type")
+		}
+		if t.Kind() == clang.Type_FunctionNoProto || first.Kind() == clang.Type_FunctionNoProto {
+			// Parameters of functions without prototype are unspecified.
+			if !compatible(t, first) {
+				add("parameters")
+			}
+			continue
+		}
+		if t.NumArgTypes() != first.NumArgTypes() {
+			add("number of parameters")
+			continue
+		}
+		for i := uint32(0); i < uint32(t.NumArgTypes()); i++ {
+			if !compatibleUnqualified(t.ArgType(i), first.ArgType(i)) {
+				add(fmt.Sprintf("type of parameter %d", i+1))
+			}
+		}
+		if t.IsFunctionTypeVariadic() != first.IsFunctionTypeVariadic() {
+			add("variadic")
+		}
+	}
+	if len(diffs) == 0 {
+		return "declarations disagree on type"
+	}
+	return "declarations disagree on " + strings.Join(diffs, ", ")
+}
+
+// allCompatible reports whether the given types are pairwise compatible.
+func allCompatible(types []clang.Type) bool {
+	for i := range types {
+		for j := i + 1; j < len(types); j++ {
+			if !compatible(types[i], types[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compatible reports whether the given types are compatible, as defined by
+// C11 6.2.7, 6.7.3, 6.7.6.1, 6.7.6.2 and 6.7.6.3. Tagged types are compatible
+// if they have the same canonical spelling.
+func compatible(a, b clang.Type) bool {
+	a, b = a.CanonicalType(), b.CanonicalType()
+	if a.IsConstQualifiedType() != b.IsConstQualifiedType() || a.IsVolatileQualifiedType() != b.IsVolatileQualifiedType() || a.IsRestrictQualifiedType() != b.IsRestrictQualifiedType() {
+		return false
+	}
+	return compatibleUnqualified(a, b)
+}
+
+// compatibleUnqualified reports whether the unqualified versions of the given
+// types are compatible.
+func compatibleUnqualified(a, b clang.Type) bool {
+	a, b = a.CanonicalType(), b.CanonicalType()
+	ak, bk := a.Kind(), b.Kind()
+	switch {
+	case ak == clang.Type_Pointer && bk == clang.Type_Pointer:
+		return compatible(a.PointeeType(), b.PointeeType())
+	case isArrayKind(ak) && isArrayKind(bk):
+		if !compatible(a.ArrayElementType(), b.ArrayElementType()) {
+			return false
+		}
+		// Array sizes must agree if both are known.
+		return ak != clang.Type_ConstantArray || bk != clang.Type_ConstantArray || a.ArraySize() == b.ArraySize()
+	case isFuncKind(ak) && isFuncKind(bk):
+		return compatibleFuncs(a, b)
+	case ak == clang.Type_Enum && bk != clang.Type_Enum:
+		// An enumerated type is compatible with its underlying integer type.
+		return compatible(a.Declaration().EnumDeclIntegerType(), b)
+	case bk == clang.Type_Enum && ak != clang.Type_Enum:
+		return compatible(a, b.Declaration().EnumDeclIntegerType())
+	}
+	return unqualifiedSpelling(a) == unqualifiedSpelling(b)
+}
+
+// unqualifiedSpelling returns the spelling of the given canonical type without
+// top-level qualifiers.
+func unqualifiedSpelling(t clang.Type) string {
+	s := t.Spelling()
+	for _, qual := range []string{"const ", "volatile ", "restrict "} {
+		s = strings.TrimPrefix(s, qual)
+	}
+	return s
+}
+
+// compatibleFuncs reports whether the given canonical function types are
+// compatible.
+func compatibleFuncs(a, b clang.Type) bool {
+	if !compatible(a.ResultType(), b.ResultType()) {
+		return false
+	}
+	if a.Kind() == clang.Type_FunctionNoProto && b.Kind() == clang.Type_FunctionNoProto {
+		return true
+	}
+	if b.Kind() == clang.Type_FunctionNoProto {
+		a, b = b, a
+	}
+	if a.Kind() == clang.Type_FunctionNoProto {
+		// A prototype is compatible with a function declarator without
+		// parameter list if it has no ellipsis and its parameter types are
+		// unaffected by the default argument promotions.
+		if b.IsFunctionTypeVariadic() {
+			return false
+		}
+		for i := uint32(0); i < uint32(b.NumArgTypes()); i++ {
+			if isPromotable(b.ArgType(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	if a.NumArgTypes() != b.NumArgTypes() || a.IsFunctionTypeVariadic() != b.IsFunctionTypeVariadic() {
+		return false
+	}
+	for i := uint32(0); i < uint32(a.NumArgTypes()); i++ {
+		// Top-level qualifiers of parameters do not affect compatibility.
+		if !compatibleUnqualified(a.ArgType(i), b.ArgType(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPromotable reports whether the given type is affected by the default
+// argument promotions.
+func isPromotable(t clang.Type) bool {
+	switch t.CanonicalType().Kind() {
+	case clang.Type_Bool, clang.Type_Char_U, clang.Type_UChar, clang.Type_Char_S, clang.Type_SChar, clang.Type_Short, clang.Type_UShort, clang.Type_Float:
+		return true
+	}
+	return false
+}
+
+// isArrayKind reports whether the given type kind is an array type.
+func isArrayKind(kind clang.TypeKind) bool {
+	switch kind {
+	case clang.Type_ConstantArray, clang.Type_IncompleteArray, clang.Type_VariableArray, clang.Type_DependentSizedArray:
+		return true
+	}
+	return false
+}
+
+// isFuncKind reports whether the given type kind is a function type.
+func isFuncKind(kind clang.TypeKind) bool {
+	return kind == clang.Type_FunctionProto || kind == clang.Type_FunctionNoProto
+}
+
+// checkLayouts checks that the definitions of the given struct or union type
+// agree on layout.
+func checkLayouts(sym *index.Symbol) *Issue {
+	var decls []*Decl
+	layouts := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, def := range sym.Defs() {
+		l := layout(def.Node.Body)
+		layouts[l] = true
+		key := def.Node.Loc.String() + "\x00" + l
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		decls = append(decls, &Decl{Loc: def.Node.Loc, TU: def.TU, Summary: l})
+	}
+	if len(layouts) < 2 {
+		return nil
+	}
+	return &Issue{
+		Kind:  ODRViolation,
+		Name:  sym.Name,
+		Desc:  "definitions disagree on layout",
+		Decls: decls,
+	}
+}
+
+// layout returns a summary of the layout of the given struct or union
+// definition (e.g. "size 8: int a @0, char b @32").
+func layout(decl clang.Cursor) string {
+	var fields []string
+	decl.Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		if cursor.Kind() == clang.Cursor_FieldDecl {
+			field := fmt.Sprintf("%s %s @%d", cursor.Type().CanonicalType().Spelling(), cursor.Spelling(), cursor.OffsetOfField())
+			if cursor.IsBitField() {
+				field += fmt.Sprintf(":%d", cursor.FieldDeclBitWidth())
+			}
+			fields = append(fields, field)
+		}
+		return clang.ChildVisit_Continue
+	})
+	size := decl.Type().SizeOf()
+	return fmt.Sprintf("size %d: %s", size, strings.Join(fields, ", "))
+}
+
+// checkLinkage reports names declared with external linkage in one translation
+// unit and defined with internal linkage in another.
+func checkLinkage(idx *index.Index) []*Issue {
+	// Symbols with external and internal linkage, indexed by name.
+	external := make(map[string][]*index.Symbol)
+	internal := make(map[string][]*index.Symbol)
+	for _, sym := range idx.Sorted() {
+		switch sym.Kind {
+		case clang.Cursor_FunctionDecl, clang.Cursor_VarDecl:
+		default:
+			continue
+		}
+		if len(sym.Decls) == 0 {
+			// Implicitly declared; e.g. builtin function.
+			continue
+		}
+		switch sym.Decls[0].Node.Linkage() {
+		case cc.LinkageExternal:
+			external[sym.Name] = append(external[sym.Name], sym)
+		case cc.LinkageInternal:
+			if len(sym.Defs()) > 0 {
+				internal[sym.Name] = append(internal[sym.Name], sym)
+			}
+		}
+	}
+	var names []string
+	for name := range internal {
+		if _, ok := external[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var issues []*Issue
+	for _, name := range names {
+		issue := &Issue{
+			Kind: LinkageMismatch,
+			Name: name,
+			Desc: "declared with external linkage but defined with internal linkage",
+		}
+		for _, sym := range external[name] {
+			occ := sym.Decls[0]
+			issue.Decls = append(issue.Decls, &Decl{Loc: occ.Node.Loc, TU: occ.TU, Summary: "external linkage"})
+		}
+		for _, sym := range internal[name] {
+			def := sym.Defs()[0]
+			issue.Decls = append(issue.Decls, &Decl{Loc: def.Node.Loc, TU: def.TU, Summary: "internal linkage"})
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
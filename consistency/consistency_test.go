@@ -0,0 +1,72 @@
+package consistency
+
+import (
+	"testing"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/index"
+)
+
+func TestCheck(t *testing.T) {
+	golden := []struct {
+		a, b string
+		// Kinds of issues, or empty if consistent.
+		kinds []Kind
+	}{
+		// Identical prototypes.
+		{a: "int f(int x);", b: "int f(int x) { return x; }"},
+		// Function without prototype and prototype.
+		{a: "int f();", b: "int f(void) { return 0; }"},
+		{a: "int f();", b: "int f(int x, long y) { return x; }"},
+		// Default argument promotions.
+		{a: "int f();", b: "int f(char c) { return c; }", kinds: []Kind{TypeMismatch}},
+		{a: "int f();", b: "int f(int x, ...) { return x; }", kinds: []Kind{TypeMismatch}},
+		// Return types.
+		{a: "long f();", b: "int f(void) { return 0; }", kinds: []Kind{TypeMismatch}},
+		// Top-level qualifiers of parameters.
+		{a: "int f(int x);", b: "int f(const int x) { return x; }"},
+		// Parameter types.
+		{a: "int f(const char *s);", b: "int f(char *s) { return 0; }", kinds: []Kind{TypeMismatch}},
+		{a: "int f(int x);", b: "int f(long x) { return 0; }", kinds: []Kind{TypeMismatch}},
+		// Arrays of unknown and known size.
+		{a: "extern int x[];", b: "int x[10];"},
+		{a: "extern int x[5];", b: "int x[10];", kinds: []Kind{TypeMismatch}},
+		// Qualifiers of variables.
+		{a: "extern const int x;", b: "int x;", kinds: []Kind{TypeMismatch}},
+		// Linkage.
+		{a: "extern int x;", b: "static int x;", kinds: []Kind{LinkageMismatch}},
+		// Layouts.
+		{a: "struct s { int a; }; struct s v1;", b: "struct s { int a; };"},
+		{a: "struct s { int a; }; struct s v1;", b: "struct s { char a; int b; };", kinds: []Kind{ODRViolation}},
+	}
+	for _, g := range golden {
+		fa, err := cc.ParseSource("a.c", g.a)
+		if err != nil {
+			t.Errorf("unable to parse %q; %+v", g.a, err)
+			continue
+		}
+		fb, err := cc.ParseSource("b.c", g.b)
+		if err != nil {
+			fa.Close()
+			t.Errorf("unable to parse %q; %+v", g.b, err)
+			continue
+		}
+		issues := Check(index.New(fa, fb))
+		var kinds []Kind
+		for _, issue := range issues {
+			kinds = append(kinds, issue.Kind)
+		}
+		if len(kinds) != len(g.kinds) {
+			t.Errorf("%q vs %q: issues mismatch; expected %v, got %v", g.a, g.b, g.kinds, kinds)
+		} else {
+			for i := range kinds {
+				if kinds[i] != g.kinds[i] {
+					t.Errorf("%q vs %q: issues mismatch; expected %v, got %v", g.a, g.b, g.kinds, kinds)
+					break
+				}
+			}
+		}
+		fa.Close()
+		fb.Close()
+	}
+}
@@ -0,0 +1,114 @@
+// Package dataflow implements forward dataflow analysis over the control flow
+// graphs of functions.
+//
+// An analysis defines a fact lattice through its join and equality functions,
+// and the effect of statement-level nodes on facts through its transfer
+// function. Facts are solved to a fixed point using a worklist algorithm, and
+// must therefore form a lattice of finite height under the join function.
+package dataflow
+
+import (
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/cfg"
+)
+
+// Fact is a dataflow fact. Facts are treated as immutable values by the solver;
+// analyses must not modify facts passed to them.
+type Fact interface{}
+
+// Analysis is a forward dataflow analysis.
+type Analysis interface {
+	// Entry returns the fact holding at the entry of the function.
+	Entry() Fact
+	// Join returns the join of the given facts.
+	Join(a, b Fact) Fact
+	// Equal reports whether the given facts are equal.
+	Equal(a, b Fact) bool
+	// Transfer returns the fact holding after the given statement-level node,
+	// given the fact holding before it.
+	Transfer(n *cc.Node, in Fact) Fact
+}
+
+// BranchAnalysis is a forward dataflow analysis which refines facts on the
+// edges of two-way branches (e.g. after a bounds check).
+type BranchAnalysis interface {
+	Analysis
+	// Branch returns the fact holding on the true or false edge of the given
+	// branch condition, given the fact holding after the condition.
+	Branch(cond *cc.Node, taken bool, out Fact) Fact
+}
+
+// Result is the solution of a forward dataflow analysis.
+type Result struct {
+	// Control flow graph of the function.
+	Graph *cfg.Graph
+	// Analysis of the solution.
+	Analysis Analysis
+	// Facts holding at the entry of reachable basic blocks.
+	In map[*cfg.Block]Fact
+	// Facts holding at the exit of reachable basic blocks.
+	Out map[*cfg.Block]Fact
+}
+
+// Forward solves the given forward dataflow analysis on the given control flow
+// graph. Blocks unreachable from the entry block have no facts.
+func Forward(g *cfg.Graph, a Analysis) *Result {
+	r := &Result{
+		Graph:    g,
+		Analysis: a,
+		In:       make(map[*cfg.Block]Fact),
+		Out:      make(map[*cfg.Block]Fact),
+	}
+	ba, hasBranch := a.(BranchAnalysis)
+	r.In[g.Entry] = a.Entry()
+	worklist := []*cfg.Block{g.Entry}
+	queued := map[*cfg.Block]bool{g.Entry: true}
+	for len(worklist) > 0 {
+		block := worklist[0]
+		worklist = worklist[1:]
+		queued[block] = false
+		out := r.In[block]
+		for _, n := range block.Nodes {
+			out = a.Transfer(n, out)
+		}
+		if prev, ok := r.Out[block]; ok && a.Equal(prev, out) {
+			continue
+		}
+		r.Out[block] = out
+		for i, succ := range block.Succs {
+			edge := out
+			if hasBranch && block.Cond != nil && i < 2 {
+				edge = ba.Branch(block.Cond, i == 0, out)
+			}
+			in, ok := r.In[succ]
+			if ok {
+				joined := a.Join(in, edge)
+				if a.Equal(in, joined) {
+					continue
+				}
+				edge = joined
+			}
+			r.In[succ] = edge
+			if !queued[succ] {
+				queued[succ] = true
+				worklist = append(worklist, succ)
+			}
+		}
+	}
+	return r
+}
+
+// Visit invokes f for each statement-level node of the reachable basic blocks
+// of the solution, in block order, with the fact holding before the node.
+func (r *Result) Visit(f func(n *cc.Node, in Fact)) {
+	for _, block := range r.Graph.Blocks {
+		fact, ok := r.In[block]
+		if !ok {
+			continue
+		}
+		for _, n := range block.Nodes {
+			f(n, fact)
+			fact = r.Analysis.Transfer(n, fact)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package dataflow
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/cfg"
+)
+
+// assigned is a must analysis of the variables assigned on all paths, which
+// also records the variables known to be non-zero on the true edge of
+// branches.
+type assigned struct{}
+
+// vars is the set of assigned variables.
+type vars map[string]bool
+
+func (assigned) Entry() Fact {
+	return vars{}
+}
+
+func (assigned) Join(a, b Fact) Fact {
+	c := make(vars)
+	for name := range a.(vars) {
+		if b.(vars)[name] {
+			c[name] = true
+		}
+	}
+	return c
+}
+
+func (assigned) Equal(a, b Fact) bool {
+	return a.(vars).String() == b.(vars).String()
+}
+
+func (assigned) Transfer(n *cc.Node, in Fact) Fact {
+	out := in.(vars).clone()
+	cc.Walk(n, func(n *cc.Node) {
+		if n.Body.Kind() == clang.Cursor_BinaryOperator && n.Operator() == "=" && len(n.Children) == 2 {
+			if lhs := n.Children[0]; lhs.Body.Kind() == clang.Cursor_DeclRefExpr {
+				out[lhs.Body.Spelling()] = true
+			}
+		}
+	})
+	return out
+}
+
+func (assigned) Branch(cond *cc.Node, taken bool, out Fact) Fact {
+	if !taken {
+		return out
+	}
+	c := out.(vars).clone()
+	cc.Walk(cond, func(n *cc.Node) {
+		if n.Body.Kind() == clang.Cursor_DeclRefExpr {
+			c[n.Body.Spelling()] = true
+		}
+	})
+	return c
+}
+
+// clone returns a copy of the set.
+func (vs vars) clone() vars {
+	c := make(vars, len(vs))
+	for name := range vs {
+		c[name] = true
+	}
+	return c
+}
+
+// String returns the sorted comma-separated names of the set.
+func (vs vars) String() string {
+	var names []string
+	for name := range vs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func TestForward(t *testing.T) {
+	golden := []struct {
+		src string
+		// Facts holding before each reachable return statement, separated by
+		// semicolons.
+		want string
+	}{
+		{src: "int f(int x) { int a; a = x; return a; }", want: "a"},
+		{src: "int f(int x) { int a; if (x) a = 1; return a; }", want: ""},
+		{src: "int f(int x) { int a; if (x) a = 1; else a = 2; return a; }", want: "a"},
+		{src: "int f(int x) { int a; a = 0; if (x) return x; return a; }", want: "a,x;a"},
+		{src: "int f(int x) { int a; while (x) { a = 1; x = 0; } return a; }", want: ""},
+		{src: "int f(int x) { int a; do { a = 1; } while (x); return a; }", want: "a"},
+		{src: "int f(int x) { return 0; x = 1; return x; }", want: ""},
+	}
+	for _, g := range golden {
+		file, err := cc.ParseSource("dataflow.c", g.src)
+		if err != nil {
+			t.Errorf("unable to parse %q; %+v", g.src, err)
+			continue
+		}
+		var graph *cfg.Graph
+		for _, n := range file.Root.Children {
+			if n.Body.Kind() == clang.Cursor_FunctionDecl && n.Body.IsCursorDefinition() {
+				graph = cfg.New(n)
+			}
+		}
+		if graph == nil {
+			t.Errorf("%q: unable to locate function definition", g.src)
+			file.Close()
+			continue
+		}
+		r := Forward(graph, assigned{})
+		var facts []string
+		r.Visit(func(n *cc.Node, in Fact) {
+			if n.Body.Kind() == clang.Cursor_ReturnStmt {
+				facts = append(facts, in.(vars).String())
+			}
+		})
+		if got := strings.Join(facts, ";"); got != g.want {
+			t.Errorf("%q: facts mismatch; expected %q, got %q", g.src, g.want, got)
+		}
+		file.Close()
+	}
+}
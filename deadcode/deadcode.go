@@ -0,0 +1,317 @@
+// Package deadcode locates dead code of a program.
+//
+// Functions, global and static variables, types, enum constants and macros
+// defined in the program but never referenced are reported as unreferenced.
+// Functions which are referenced but unreachable from the entry points of the
+// program through the call graph are reported as unreachable. Functions whose
+// address is taken are assumed reachable, as they may be called indirectly.
+//
+// Macros are only considered if the translation units were parsed with
+// cc.PreprocessingRecordArgs. As macros used in conditional preprocessing
+// directives (e.g. #ifdef) have no expansions in the AST, macros whose name
+// occurs in a conditional directive are considered referenced.
+package deadcode
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/callgraph"
+	"github.com/mewspring/cc/index"
+)
+
+// Config is the configuration of the dead code finder.
+type Config struct {
+	// Names of entry points of the program (e.g. "main", exported library
+	// functions). Entry points are never reported.
+	Entries []string
+	// Treat all functions and variables with external linkage as entry points
+	// (e.g. for shared libraries).
+	ExportExternal bool
+}
+
+// Dead is a dead symbol.
+type Dead struct {
+	// Kind of symbol (e.g. "function", "variable", "type", "enum constant",
+	// "macro").
+	Kind string
+	// Symbol name.
+	Name string
+	// Source location of the symbol definition.
+	Loc cc.Location
+	// Reason the symbol is dead ("unreferenced" or "unreachable").
+	Reason string
+}
+
+// Find locates the dead code of the program consisting of the given
+// translation units, sorted by location. Symbols defined in system headers are
+// ignored.
+func Find(files []*cc.File, config *Config) []*Dead {
+	idx := index.New(files...)
+	cg := callgraph.New(files...)
+	entries := make(map[string]bool)
+	for _, entry := range config.Entries {
+		entries[entry] = true
+	}
+	isEntry := func(sym *index.Symbol) bool {
+		if entries[sym.Name] {
+			return true
+		}
+		if !config.ExportExternal || len(sym.Decls) == 0 {
+			return false
+		}
+		switch sym.Kind {
+		case clang.Cursor_FunctionDecl, clang.Cursor_VarDecl:
+			return sym.Decls[0].Node.Linkage() == cc.LinkageExternal
+		}
+		return false
+	}
+	owners := typedefOwners(files)
+	condIdents := conditionalIdents(files)
+	// Enums are referenced through their enumerators.
+	enumRefs := make(map[string]bool)
+	for _, sym := range idx.Symbols {
+		if sym.Kind == clang.Cursor_EnumConstantDecl && len(sym.Decls) > 0 && hasExternalRefs(sym) {
+			enumRefs[sym.Decls[0].Node.Body.SemanticParent().USR()] = true
+		}
+	}
+	referenced := func(sym *index.Symbol) bool {
+		if hasExternalRefs(sym) {
+			return true
+		}
+		switch sym.Kind {
+		case clang.Cursor_StructDecl, clang.Cursor_UnionDecl, clang.Cursor_EnumDecl:
+			if enumRefs[sym.USR] {
+				return true
+			}
+			// Types defined by a type definition are referenced through the
+			// type definition.
+			owner, ok := idx.Symbols[owners[sym.USR]]
+			return ok && hasExternalRefs(owner)
+		case clang.Cursor_MacroDefinition:
+			return condIdents[sym.Name]
+		}
+		return false
+	}
+	// Locate unreferenced symbols.
+	var dead []*Dead
+	for _, sym := range idx.Sorted() {
+		kind := kindName(sym)
+		if len(kind) == 0 || isEntry(sym) {
+			continue
+		}
+		def := definition(sym)
+		if def == nil || def.Node.Body.Location().IsInSystemHeader() {
+			continue
+		}
+		if !referenced(sym) {
+			dead = append(dead, &Dead{Kind: kind, Name: sym.Name, Loc: def.Node.Loc, Reason: "unreferenced"})
+		}
+	}
+	// Locate unreachable functions.
+	var roots []*callgraph.Func
+	callees := calleeRefs(cg)
+	for _, sym := range idx.Symbols {
+		if sym.Kind != clang.Cursor_FunctionDecl && sym.Kind != clang.Cursor_CXXMethod {
+			continue
+		}
+		f, ok := cg.Funcs[sym.USR]
+		if !ok {
+			continue
+		}
+		if isEntry(sym) {
+			roots = append(roots, f)
+			continue
+		}
+		// Functions whose address is taken may be called indirectly.
+		for _, ref := range sym.Refs {
+			if !callees[ref.Node.Loc] {
+				roots = append(roots, f)
+				break
+			}
+		}
+	}
+	reachable := make(map[*callgraph.Func]bool)
+	for _, f := range roots {
+		reachable[f] = true
+	}
+	for _, f := range callgraph.Callees(roots...) {
+		reachable[f] = true
+	}
+	for _, f := range cg.Sorted() {
+		if f.Def == nil || reachable[f] || f.Def.Body.Location().IsInSystemHeader() {
+			continue
+		}
+		if sym, ok := idx.Symbols[f.USR]; ok && !hasExternalRefs(sym) {
+			// Already reported as unreferenced.
+			continue
+		}
+		dead = append(dead, &Dead{Kind: "function", Name: f.Name, Loc: f.Def.Loc, Reason: "unreachable"})
+	}
+	sort.SliceStable(dead, func(i, j int) bool {
+		a, b := dead[i].Loc, dead[j].Loc
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return dead
+}
+
+// kindName returns the kind of the given symbol as reported by the dead code
+// finder; or an empty string if the symbol is not considered.
+func kindName(sym *index.Symbol) string {
+	switch sym.Kind {
+	case clang.Cursor_FunctionDecl, clang.Cursor_CXXMethod:
+		return "function"
+	case clang.Cursor_VarDecl:
+		if len(sym.Decls) == 0 {
+			return ""
+		}
+		n := sym.Decls[0].Node
+		// Global variables and static local variables.
+		if n.Linkage() == cc.LinkageNone && n.StorageClass() != cc.StorageStatic {
+			return ""
+		}
+		return "variable"
+	case clang.Cursor_StructDecl, clang.Cursor_UnionDecl, clang.Cursor_EnumDecl, clang.Cursor_TypedefDecl, clang.Cursor_ClassDecl:
+		return "type"
+	case clang.Cursor_EnumConstantDecl:
+		return "enum constant"
+	case clang.Cursor_MacroDefinition:
+		return "macro"
+	}
+	return ""
+}
+
+// definition returns the first definition of the given symbol; or nil if not
+// defined.
+func definition(sym *index.Symbol) *index.Occurrence {
+	if sym.Kind == clang.Cursor_MacroDefinition || sym.Kind == clang.Cursor_EnumConstantDecl || sym.Kind == clang.Cursor_TypedefDecl {
+		// Always definitions.
+		if len(sym.Decls) > 0 {
+			return sym.Decls[0]
+		}
+		return nil
+	}
+	if defs := sym.Defs(); len(defs) > 0 {
+		return defs[0]
+	}
+	return nil
+}
+
+// hasExternalRefs reports whether the given symbol is referenced, ignoring
+// references within its own definitions (e.g. recursive calls or
+// self-referential types).
+func hasExternalRefs(sym *index.Symbol) bool {
+	for _, ref := range sym.Refs {
+		if !withinDefs(sym, ref.Node.Loc) {
+			return true
+		}
+	}
+	return false
+}
+
+// withinDefs reports whether the given location is within a definition of the
+// given symbol.
+func withinDefs(sym *index.Symbol, loc cc.Location) bool {
+	for _, def := range sym.Decls {
+		if !def.Node.Body.IsCursorDefinition() {
+			continue
+		}
+		start, end := def.Node.Extent()
+		if loc.File != start.File {
+			continue
+		}
+		if (loc.Line > start.Line || (loc.Line == start.Line && loc.Col >= start.Col)) && (loc.Line < end.Line || (loc.Line == end.Line && loc.Col <= end.Col)) {
+			return true
+		}
+	}
+	return false
+}
+
+// typedefOwners returns a map from the USR of struct, union and enum types
+// defined within type definitions to the USR of the type definition.
+func typedefOwners(files []*cc.File) map[string]string {
+	owners := make(map[string]string)
+	for _, file := range files {
+		cc.Walk(file.Root, func(n *cc.Node) {
+			if n.Body.Kind() != clang.Cursor_TypedefDecl {
+				return
+			}
+			for _, child := range n.Children {
+				switch child.Body.Kind() {
+				case clang.Cursor_StructDecl, clang.Cursor_UnionDecl, clang.Cursor_EnumDecl:
+					owners[child.Body.USR()] = n.Body.USR()
+				}
+			}
+		})
+	}
+	return owners
+}
+
+// calleeRefs returns the locations of function references used as the callee
+// of direct calls.
+func calleeRefs(cg *callgraph.Graph) map[cc.Location]bool {
+	locs := make(map[cc.Location]bool)
+	for _, f := range cg.Funcs {
+		for _, call := range f.Callees {
+			if len(call.Site.Children) == 0 {
+				continue
+			}
+			// Skip implicit casts and parentheses of the callee expression.
+			callee := call.Site.Children[0]
+			for len(callee.Children) > 0 && (callee.Body.Kind() == clang.Cursor_UnexposedExpr || callee.Body.Kind() == clang.Cursor_ParenExpr) {
+				callee = callee.Children[0]
+			}
+			locs[callee.Loc] = true
+		}
+	}
+	return locs
+}
+
+// identRegexp matches identifiers.
+var identRegexp = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// conditionalIdents returns the identifiers occurring in conditional
+// preprocessing directives of the source files of the given translation units.
+func conditionalIdents(files []*cc.File) map[string]bool {
+	paths := make(map[string]bool)
+	for _, file := range files {
+		cc.Walk(file.Root, func(n *cc.Node) {
+			if len(n.Loc.File) > 0 && !n.Body.Location().IsInSystemHeader() {
+				paths[n.Loc.File] = true
+			}
+		})
+	}
+	idents := make(map[string]bool)
+	for path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			line := strings.TrimSpace(s.Text())
+			if !strings.HasPrefix(line, "#") {
+				continue
+			}
+			directive := strings.TrimSpace(line[1:])
+			for _, prefix := range []string{"if", "elif"} {
+				if strings.HasPrefix(directive, prefix) {
+					for _, ident := range identRegexp.FindAllString(directive, -1) {
+						idents[ident] = true
+					}
+					break
+				}
+			}
+		}
+		f.Close()
+	}
+	return idents
+}
@@ -0,0 +1,76 @@
+package deadcode
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+func TestFind(t *testing.T) {
+	const src = `
+#define USED 1
+#define UNUSED 2
+#define FEATURE
+enum color { GREEN };
+enum shape { SQUARE };
+typedef struct { int x; } point;
+struct unused_s { int y; };
+static int counter;
+static int unused_var;
+static int rec(int n) { return n > 0 ? rec(n - 1) : 0; }
+static int island2(void);
+static int island(void) { return island2(); }
+static int island2(void) { return island(); }
+static int callback(int x) { return x; }
+int apply(int (*f)(int), int x) { return f(x); }
+int main(void) {
+#ifdef FEATURE
+	point p = {USED};
+#endif
+	counter = GREEN;
+	return apply(callback, p.x) + counter;
+}
+`
+	// Conditional directives are read from disk.
+	dir, err := ioutil.TempDir("", "deadcode")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	srcPath := filepath.Join(dir, "dead.c")
+	if err := ioutil.WriteFile(srcPath, []byte(src), 0644); err != nil {
+		t.Fatalf("unable to write source file; %+v", err)
+	}
+	file, err := cc.ParseFile(srcPath, cc.PreprocessingRecordArgs...)
+	if err != nil {
+		t.Logf("diagnostics; %+v", err)
+	}
+	defer file.Close()
+	golden := []string{
+		"macro UNUSED unreferenced",
+		"enum constant SQUARE unreferenced",
+		"type shape unreferenced",
+		"type unused_s unreferenced",
+		"variable unused_var unreferenced",
+		"function rec unreferenced",
+		"function island unreachable",
+		"function island2 unreachable",
+	}
+	dead := Find([]*cc.File{file}, &Config{Entries: []string{"main"}})
+	var got []string
+	for _, d := range dead {
+		got = append(got, fmt.Sprintf("%s %s %s", d.Kind, d.Name, d.Reason))
+	}
+	if len(got) != len(golden) {
+		t.Fatalf("dead code mismatch; expected %q, got %q", golden, got)
+	}
+	for i, want := range golden {
+		if got[i] != want {
+			t.Errorf("dead code %d mismatch; expected %q, got %q", i, want, got[i])
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package cc
+
+import (
+	"github.com/go-clang/clang-v3.9/clang"
+)
+
+// Operator returns the operator of the given unary, binary or compound
+// assignment operator node (e.g. "+", "<<=", "!", "++"); or an empty string if
+// not an operator node.
+func (n *Node) Operator() string {
+	switch n.Body.Kind() {
+	case clang.Cursor_BinaryOperator, clang.Cursor_CompoundAssignOperator:
+		if len(n.Children) < 1 {
+			return ""
+		}
+		// The operator is the first token following the left-hand side
+		// operand.
+		lhsEnd := offset(n.Children[0].Body.Extent().End())
+		return n.tokenAt(func(off uint32) bool { return off >= lhsEnd })
+	case clang.Cursor_UnaryOperator:
+		if len(n.Children) < 1 {
+			return ""
+		}
+		// The operator is either the first token preceding the operand (prefix
+		// operator) or the first token following the operand (postfix
+		// operator).
+		operand := n.Children[0].Body.Extent()
+		start, end := offset(operand.Start()), offset(operand.End())
+		if op := n.tokenAt(func(off uint32) bool { return off < start }); len(op) > 0 {
+			return op
+		}
+		return n.tokenAt(func(off uint32) bool { return off >= end })
+	}
+	return ""
+}
+
+// IsPostfix reports whether the given unary operator node is a postfix
+// operator (e.g. x++).
+func (n *Node) IsPostfix() bool {
+	if n.Body.Kind() != clang.Cursor_UnaryOperator || len(n.Children) < 1 {
+		return false
+	}
+	start := offset(n.Children[0].Body.Extent().Start())
+	return len(n.tokenAt(func(off uint32) bool { return off < start })) == 0
+}
+
+// tokenAt returns the spelling of the first token of the node whose file
+// offset satisfies the given predicate; or an empty string if not present.
+func (n *Node) tokenAt(pred func(off uint32) bool) string {
+	tu := n.Body.TranslationUnit()
+	toks := tu.Tokenize(n.Body.Extent())
+	defer tu.DisposeTokens(toks)
+	for _, tok := range toks {
+		if pred(offset(tu.TokenLocation(tok))) {
+			return tu.TokenSpelling(tok)
+		}
+	}
+	return ""
+}
+
+// offset returns the file offset of the given source location.
+func offset(loc clang.SourceLocation) uint32 {
+	_, _, _, off := loc.FileLocation()
+	return off
+}
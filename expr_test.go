@@ -0,0 +1,53 @@
+package cc
+
+import (
+	"testing"
+
+	"github.com/go-clang/clang-v3.9/clang"
+)
+
+func TestOperator(t *testing.T) {
+	const src = `
+void f(int x, int *p) {
+	x = x + 1;
+	x <<= 2;
+	x++;
+	--x;
+	x = -x;
+	x = *p;
+	x = x >= 3 && x != 4;
+}
+`
+	file := parseSource(t, "expr.c", src)
+	defer file.Close()
+	golden := []struct {
+		op      string
+		postfix bool
+	}{
+		{op: "="}, {op: "+"},
+		{op: "<<="},
+		{op: "++", postfix: true},
+		{op: "--"},
+		{op: "="}, {op: "-"},
+		{op: "="}, {op: "*"},
+		{op: "="}, {op: "&&"}, {op: ">="}, {op: "!="},
+	}
+	var ops []*Node
+	Walk(file.Root, func(n *Node) {
+		switch n.Body.Kind() {
+		case clang.Cursor_BinaryOperator, clang.Cursor_CompoundAssignOperator, clang.Cursor_UnaryOperator:
+			ops = append(ops, n)
+		}
+	})
+	if len(ops) != len(golden) {
+		t.Fatalf("number of operators mismatch; expected %d, got %d", len(golden), len(ops))
+	}
+	for i, g := range golden {
+		if got := ops[i].Operator(); got != g.op {
+			t.Errorf("operator %d: mismatch; expected %q, got %q", i, g.op, got)
+		}
+		if got := ops[i].IsPostfix(); got != g.postfix {
+			t.Errorf("operator %d (%q): postfix mismatch; expected %v, got %v", i, g.op, g.postfix, got)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+// Package globals reports the global mutable state of C programs.
+//
+// Global and static variables of the program are listed along with the
+// functions reading, writing or taking the address of them, the entry points
+// from which these functions are reachable through the call graph, and the
+// threads from which they are accessed. Targets of calls through function
+// pointers are resolved by points-to analysis.
+//
+// Thread entry functions are the functions passed as start routine to thread
+// creation functions (e.g. pthread_create), and configured thread entries. The
+// entry points of the program which are not thread entry functions are assumed
+// to run on the main thread. A variable is shared if it is accessed from more
+// than one thread; a thread entry function started several times is not
+// considered to share variables with itself. Variables with thread storage
+// duration are never shared.
+//
+// Only accesses within function definitions are recorded; initializers of
+// global variables are not considered accesses. Const-qualified variables are
+// not mutable and thus not reported.
+package globals
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/callgraph"
+	"github.com/mewspring/cc/pointsto"
+)
+
+// ThreadCreators holds the index of the start routine argument of thread
+// creation functions, by function name.
+var ThreadCreators = map[string]int{
+	"pthread_create": 2,
+	"thrd_create":    1,
+}
+
+// Config is the configuration of the global mutable state analysis.
+type Config struct {
+	// Names of entry points (e.g. "main", interrupt handlers); or empty to use
+	// the defined functions without callers.
+	Entries []string `json:"entries"`
+	// Names of thread entry functions, in addition to the start routines of
+	// thread creation functions.
+	Threads []string `json:"threads"`
+	// Index of the start routine argument of thread creation functions, by
+	// function name; or nil to use ThreadCreators.
+	ThreadCreators map[string]int `json:"thread_creators"`
+}
+
+// DefaultConfig is the default configuration of the global mutable state
+// analysis.
+var DefaultConfig = &Config{}
+
+// AccessKind is the kind of an access to a variable.
+type AccessKind uint8
+
+// Access kinds.
+const (
+	// Read access.
+	AccessRead AccessKind = iota
+	// Write access.
+	AccessWrite
+	// Address of variable taken (e.g. &x, decay of array).
+	AccessAddr
+)
+
+// String returns the string representation of the access kind.
+func (kind AccessKind) String() string {
+	switch kind {
+	case AccessRead:
+		return "read"
+	case AccessWrite:
+		return "write"
+	case AccessAddr:
+		return "address taken"
+	}
+	return fmt.Sprintf("AccessKind(%d)", uint8(kind))
+}
+
+// Access is an access to a variable.
+type Access struct {
+	// Access kind.
+	Kind AccessKind
+	// Accessing function.
+	Func *callgraph.Func
+	// Reference node of the variable.
+	Node *cc.Node
+}
+
+// Var is a global or static variable.
+type Var struct {
+	// Variable name.
+	Name string
+	// Unified Symbol Resolution of the variable.
+	USR string
+	// Variable definition; or first declaration if not defined in the program.
+	Decl *cc.Node
+	// Enclosing function of static local variables; or nil for global
+	// variables.
+	Func *callgraph.Func
+	// Linkage of the variable.
+	Linkage cc.Linkage
+	// Variable with thread storage duration.
+	ThreadLocal bool
+	// Accesses to the variable, by accessing function in order of name, then
+	// in source order.
+	Accesses []*Access
+	// Entry points of the main thread from which the variable is accessed,
+	// sorted by name.
+	Entries []*callgraph.Func
+	// Thread entry functions from which the variable is accessed, sorted by
+	// name; main thread accesses are recorded by MainThread.
+	Threads []*callgraph.Func
+	// Variable accessed from the main thread.
+	MainThread bool
+	// Variable accessed from more than one thread.
+	Shared bool
+}
+
+// Funcs returns the functions accessing the variable with the given access
+// kind, sorted by name.
+func (v *Var) Funcs(kind AccessKind) []*callgraph.Func {
+	var fs []*callgraph.Func
+	seen := make(map[*callgraph.Func]bool)
+	for _, access := range v.Accesses {
+		if access.Kind == kind && !seen[access.Func] {
+			seen[access.Func] = true
+			fs = append(fs, access.Func)
+		}
+	}
+	return fs
+}
+
+// Result is the result of a global mutable state analysis.
+type Result struct {
+	// Global and static variables of the program, sorted by name.
+	Vars []*Var
+	// Entry points of the program run on the main thread, sorted by name.
+	Entries []*callgraph.Func
+	// Thread entry functions of the program, sorted by name.
+	Threads []*callgraph.Func
+}
+
+// Analyze analyzes the global mutable state of the program consisting of the
+// given translation units, using the given configuration; or DefaultConfig if
+// nil. Variables declared in system headers are ignored.
+func Analyze(files []*cc.File, config *Config) *Result {
+	if config == nil {
+		config = DefaultConfig
+	}
+	g := callgraph.New(files...)
+	pts := pointsto.Analyze(files...)
+	pts.ResolveCalls(g)
+	a := &analyzer{vars: make(map[string]*Var)}
+	for _, file := range files {
+		for _, n := range file.Root.Children {
+			a.declare(n, nil)
+		}
+	}
+	for _, f := range g.Sorted() {
+		if f.Def == nil {
+			continue
+		}
+		a.f = f
+		cc.Walk(f.Def, func(n *cc.Node) {
+			if n.Body.Kind() == clang.Cursor_VarDecl {
+				a.declare(n, f)
+			}
+		})
+		for _, child := range f.Def.Children {
+			a.visit(child, AccessRead)
+		}
+	}
+	r := &Result{Threads: threads(g, pts, config)}
+	isThread := make(map[*callgraph.Func]bool)
+	for _, f := range r.Threads {
+		isThread[f] = true
+	}
+	r.Entries = entries(g, config, isThread)
+	// Entry points and thread entry functions reaching each function, by
+	// function.
+	reaching := make(map[*callgraph.Func][]*callgraph.Func)
+	for _, entry := range r.Entries {
+		for _, f := range append(callgraph.Callees(entry), entry) {
+			reaching[f] = append(reaching[f], entry)
+		}
+	}
+	for _, entry := range r.Threads {
+		for _, f := range append(callgraph.Callees(entry), entry) {
+			reaching[f] = append(reaching[f], entry)
+		}
+	}
+	for _, v := range a.vars {
+		seen := make(map[*callgraph.Func]bool)
+		for _, access := range v.Accesses {
+			for _, entry := range reaching[access.Func] {
+				if seen[entry] {
+					continue
+				}
+				seen[entry] = true
+				if isThread[entry] {
+					v.Threads = append(v.Threads, entry)
+				} else {
+					v.Entries = append(v.Entries, entry)
+					v.MainThread = true
+				}
+			}
+		}
+		sortFuncs(v.Entries)
+		sortFuncs(v.Threads)
+		nthreads := len(v.Threads)
+		if v.MainThread {
+			nthreads++
+		}
+		v.Shared = !v.ThreadLocal && nthreads > 1
+		r.Vars = append(r.Vars, v)
+	}
+	sort.Slice(r.Vars, func(i, j int) bool {
+		if r.Vars[i].Name != r.Vars[j].Name {
+			return r.Vars[i].Name < r.Vars[j].Name
+		}
+		return r.Vars[i].USR < r.Vars[j].USR
+	})
+	return r
+}
+
+// entries returns the entry points of the call graph other than the given
+// thread entry functions, sorted by name.
+func entries(g *callgraph.Graph, config *Config, isThread map[*callgraph.Func]bool) []*callgraph.Func {
+	names := make(map[string]bool)
+	for _, name := range config.Entries {
+		names[name] = true
+	}
+	var fs []*callgraph.Func
+	for _, f := range g.Sorted() {
+		if f.Def == nil || f.Def.Body.Location().IsInSystemHeader() || isThread[f] {
+			continue
+		}
+		if names[f.Name] || (len(names) == 0 && len(f.Callers) == 0) {
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
+
+// threads returns the thread entry functions of the call graph, sorted by
+// name.
+func threads(g *callgraph.Graph, pts *pointsto.Result, config *Config) []*callgraph.Func {
+	creators := config.ThreadCreators
+	if creators == nil {
+		creators = ThreadCreators
+	}
+	found := make(map[*callgraph.Func]bool)
+	for _, name := range config.Threads {
+		for _, f := range g.Sorted() {
+			if f.Name == name && f.Def != nil {
+				found[f] = true
+			}
+		}
+	}
+	for _, f := range g.Sorted() {
+		for _, call := range f.Callees {
+			i, ok := creators[call.Callee.Name]
+			if !ok || i+1 >= len(call.Site.Children) {
+				continue
+			}
+			// The first child of a call expression is the callee.
+			arg := call.Site.Children[i+1]
+			if ref := stripParens(arg); ref.Body.Kind() == clang.Cursor_DeclRefExpr && ref.Body.Referenced().Kind() == clang.Cursor_FunctionDecl {
+				found[g.Func(ref.Body.Referenced())] = true
+				continue
+			}
+			for _, obj := range pts.PointsTo(arg) {
+				if obj.Kind == pointsto.ObjectFunc {
+					found[g.Func(obj.Decl)] = true
+				}
+			}
+		}
+	}
+	var fs []*callgraph.Func
+	for f := range found {
+		fs = append(fs, f)
+	}
+	sortFuncs(fs)
+	return fs
+}
+
+// analyzer records the accesses to the global and static variables of a
+// program.
+type analyzer struct {
+	// Global and static variables, by USR.
+	vars map[string]*Var
+	// Function being analyzed.
+	f *callgraph.Func
+}
+
+// declare adds the given variable declaration to the variables of the
+// analyzer if global or static. The enclosing function of local variables is
+// given by f; or nil for top-level declarations.
+func (a *analyzer) declare(n *cc.Node, f *callgraph.Func) {
+	if n.Body.Kind() != clang.Cursor_VarDecl || n.Body.Location().IsInSystemHeader() {
+		return
+	}
+	if f != nil && n.StorageClass() != cc.StorageStatic {
+		// Automatic and block scope extern variables.
+		return
+	}
+	if isConst(n.Body.Type()) {
+		return
+	}
+	usr := n.Body.USR()
+	v, ok := a.vars[usr]
+	if !ok {
+		v = &Var{
+			Name:        n.Body.Spelling(),
+			USR:         usr,
+			Decl:        n,
+			Func:        f,
+			Linkage:     n.Linkage(),
+			ThreadLocal: n.IsThreadLocal(),
+		}
+		a.vars[usr] = v
+		return
+	}
+	if n.Body.IsCursorDefinition() && !v.Decl.Body.IsCursorDefinition() {
+		v.Decl = n
+		v.ThreadLocal = v.ThreadLocal || n.IsThreadLocal()
+	}
+}
+
+// visit records the accesses to global and static variables of the given
+// node, where the given access kinds apply to the node if it designates a
+// variable.
+func (a *analyzer) visit(n *cc.Node, kinds ...AccessKind) {
+	switch n.Body.Kind() {
+	case clang.Cursor_DeclRefExpr:
+		v, ok := a.vars[n.Body.Referenced().USR()]
+		if !ok || n.Body.Referenced().Kind() != clang.Cursor_VarDecl {
+			return
+		}
+		for _, kind := range kinds {
+			v.Accesses = append(v.Accesses, &Access{Kind: kind, Func: a.f, Node: n})
+		}
+		return
+	case clang.Cursor_ParenExpr:
+		a.visitChildren(n, kinds...)
+		return
+	case clang.Cursor_UnexposedExpr:
+		// Implicit conversion of array to pointer.
+		if len(n.Children) == 1 && isArray(n.Children[0].Body.Type()) && n.Body.Type().CanonicalType().Kind() == clang.Type_Pointer {
+			a.visit(n.Children[0], AccessAddr)
+			return
+		}
+		a.visitChildren(n, kinds...)
+		return
+	case clang.Cursor_BinaryOperator, clang.Cursor_CompoundAssignOperator:
+		if len(n.Children) == 2 {
+			switch op := n.Operator(); {
+			case op == "=":
+				a.visit(n.Children[0], AccessWrite)
+				a.visit(n.Children[1], AccessRead)
+				return
+			case n.Body.Kind() == clang.Cursor_CompoundAssignOperator:
+				a.visit(n.Children[0], AccessRead, AccessWrite)
+				a.visit(n.Children[1], AccessRead)
+				return
+			}
+		}
+	case clang.Cursor_UnaryOperator:
+		if len(n.Children) == 1 {
+			switch n.Operator() {
+			case "++", "--":
+				a.visit(n.Children[0], AccessRead, AccessWrite)
+				return
+			case "&":
+				a.visit(n.Children[0], AccessAddr)
+				return
+			}
+		}
+	case clang.Cursor_MemberRefExpr:
+		// The fields of a variable accessed through the dot operator are part
+		// of the variable; the base pointer of the arrow operator is read.
+		if len(n.Children) == 1 && n.Children[0].Body.Type().CanonicalType().Kind() != clang.Type_Pointer {
+			a.visit(n.Children[0], kinds...)
+			return
+		}
+	case clang.Cursor_ArraySubscriptExpr:
+		// The elements of an array variable are part of the variable; the base
+		// pointer of other subscript expressions is read.
+		if len(n.Children) == 2 {
+			if base := stripParens(n.Children[0]); base != n.Children[0] && isArray(base.Body.Type()) {
+				a.visit(base, kinds...)
+				a.visit(n.Children[1], AccessRead)
+				return
+			}
+		}
+	case clang.Cursor_UnaryExpr:
+		// Operand of sizeof and alignof is not evaluated.
+		return
+	}
+	a.visitChildren(n, AccessRead)
+}
+
+// visitChildren records the accesses to global and static variables of the
+// children of the given node, where the given access kinds apply to the
+// children designating a variable.
+func (a *analyzer) visitChildren(n *cc.Node, kinds ...AccessKind) {
+	for _, child := range n.Children {
+		a.visit(child, kinds...)
+	}
+}
+
+// stripParens returns the given expression with parentheses and implicit casts
+// removed.
+func stripParens(n *cc.Node) *cc.Node {
+	for len(n.Children) == 1 && (n.Body.Kind() == clang.Cursor_UnexposedExpr || n.Body.Kind() == clang.Cursor_ParenExpr) {
+		n = n.Children[0]
+	}
+	return n
+}
+
+// isArray reports whether the given type is an array type.
+func isArray(t clang.Type) bool {
+	switch t.CanonicalType().Kind() {
+	case clang.Type_ConstantArray, clang.Type_IncompleteArray, clang.Type_VariableArray:
+		return true
+	}
+	return false
+}
+
+// isConst reports whether the given type, or the element type of the given
+// array type, is const-qualified.
+func isConst(t clang.Type) bool {
+	t = t.CanonicalType()
+	for isArray(t) {
+		t = t.ArrayElementType().CanonicalType()
+	}
+	return t.IsConstQualifiedType()
+}
+
+// sortFuncs sorts the given functions by name.
+func sortFuncs(fs []*callgraph.Func) {
+	sort.Slice(fs, func(i, j int) bool {
+		return fs[i].Name < fs[j].Name
+	})
+}
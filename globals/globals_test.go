@@ -0,0 +1,104 @@
+package globals
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/callgraph"
+)
+
+const src = `
+typedef unsigned long pthread_t;
+int pthread_create(pthread_t *, const void *, void *(*)(void *), void *);
+extern int counter;
+int counter = 0;
+static int config;
+const int limit = 10;
+_Thread_local int tls;
+int unused;
+static void *worker(void *arg) { counter++; tls = 1; return arg; }
+static void setup(void) { config = limit; tls = 2; }
+int main(void) {
+	static int calls;
+	pthread_t t;
+	int *p = &config;
+	calls++;
+	setup();
+	pthread_create(&t, 0, worker, 0);
+	return counter + *p;
+}
+`
+
+// names returns the comma-separated names of the given functions.
+func names(fs []*callgraph.Func) string {
+	var ss []string
+	for _, f := range fs {
+		ss = append(ss, f.Name)
+	}
+	return strings.Join(ss, ",")
+}
+
+func TestAnalyze(t *testing.T) {
+	file, err := cc.ParseSource("globals.c", src)
+	if err != nil {
+		t.Fatalf("unable to parse source; %+v", err)
+	}
+	defer file.Close()
+	// Default configuration.
+	r := Analyze([]*cc.File{file}, nil)
+	if got := names(r.Entries); got != "main" {
+		t.Errorf("entry points mismatch; expected %q, got %q", "main", got)
+	}
+	if got := names(r.Threads); got != "worker" {
+		t.Errorf("thread entry functions mismatch; expected %q, got %q", "worker", got)
+	}
+	golden := []struct {
+		name string
+		// Line of the variable definition.
+		line    uint32
+		reads   string
+		writes  string
+		addrs   string
+		entries string
+		threads string
+		shared  bool
+	}{
+		{name: "calls", line: 13, reads: "main", writes: "main", entries: "main"},
+		{name: "config", line: 6, writes: "setup", addrs: "main", entries: "main"},
+		{name: "counter", line: 5, reads: "main,worker", writes: "worker", entries: "main", threads: "worker", shared: true},
+		{name: "tls", line: 8, writes: "setup,worker", entries: "main", threads: "worker"},
+		{name: "unused", line: 9},
+	}
+	if len(r.Vars) != len(golden) {
+		t.Fatalf("number of variables mismatch; expected %d, got %d", len(golden), len(r.Vars))
+	}
+	for i, g := range golden {
+		v := r.Vars[i]
+		if v.Name != g.name {
+			t.Errorf("variable %d: name mismatch; expected %q, got %q", i, g.name, v.Name)
+			continue
+		}
+		if v.Decl.Loc.Line != g.line {
+			t.Errorf("%q: declaration line mismatch; expected %d, got %d", g.name, g.line, v.Decl.Loc.Line)
+		}
+		if got := names(v.Funcs(AccessRead)); got != g.reads {
+			t.Errorf("%q: reading functions mismatch; expected %q, got %q", g.name, g.reads, got)
+		}
+		if got := names(v.Funcs(AccessWrite)); got != g.writes {
+			t.Errorf("%q: writing functions mismatch; expected %q, got %q", g.name, g.writes, got)
+		}
+		if got := names(v.Funcs(AccessAddr)); got != g.addrs {
+			t.Errorf("%q: address taking functions mismatch; expected %q, got %q", g.name, g.addrs, got)
+		}
+		if got := names(v.Entries); got != g.entries {
+			t.Errorf("%q: entry points mismatch; expected %q, got %q", g.name, g.entries, got)
+		}
+		if got := names(v.Threads); got != g.threads {
+			t.Errorf("%q: threads mismatch; expected %q, got %q", g.name, g.threads, got)
+		}
+		if v.Shared != g.shared {
+			t.Errorf("%q: shared mismatch; expected %v, got %v", g.name, g.shared, v.Shared)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Package headercheck checks that headers are self-contained and protected
+// against multiple inclusion.
+//
+// Each header is checked by parsing a synthetic translation unit which only
+// includes the header. The synthetic translation unit is provided to Clang as
+// an unsaved file located next to the header, so it never touches the disk.
+package headercheck
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/pkg/errors"
+)
+
+// Result is the result of checking a header.
+type Result struct {
+	// Header path.
+	Header string
+	// Errors encountered when compiling the header on its own.
+	Errors []string
+	// The header has neither an include guard nor #pragma once.
+	MissingGuard bool
+}
+
+// OK reports whether the header passed all checks.
+func (r *Result) OK() bool {
+	return len(r.Errors) == 0 && !r.MissingGuard
+}
+
+// CheckHeader checks the given header using the given compiler environment
+// configuration.
+func CheckHeader(header string, config *cc.Config) (*Result, error) {
+	r := &Result{Header: header}
+	// Check include guard.
+	buf, err := ioutil.ReadFile(header)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r.MissingGuard = !hasGuard(string(buf))
+	// Check self-containment.
+	abs, err := filepath.Abs(header)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ext := ".c"
+	if config.Std.IsCXX() || isCXXHeader(header) {
+		ext = ".cpp"
+	}
+	srcPath := filepath.Join(filepath.Dir(abs), "__self_contained_"+strings.TrimSuffix(filepath.Base(abs), filepath.Ext(abs))+ext)
+	src, err := includeDirective(abs)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	args, err := config.ClangArgs()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	file, err := cc.ParseSource(srcPath, src, args...)
+	if file == nil {
+		return nil, errors.WithStack(err)
+	}
+	// Only errors are reported, as warnings do not prevent the header from
+	// compiling.
+	r.Errors = file.Errors()
+	file.Close()
+	return r, nil
+}
+
+// includeDirective returns an #include directive of the given header path.
+// The characters of header names are not escaped in C, so header paths
+// containing double quotes or line breaks cannot be included.
+func includeDirective(header string) (string, error) {
+	if strings.ContainsAny(header, "\"\n") {
+		return "", errors.Errorf("unable to include header %q; path contains double quote or line break", header)
+	}
+	return fmt.Sprintf("#include \"%s\"\n", header), nil
+}
+
+// isCXXHeader reports whether the given header is a C++ header, based on its
+// file extension.
+func isCXXHeader(header string) bool {
+	switch filepath.Ext(header) {
+	case ".hh", ".hpp", ".hxx", ".h++":
+		return true
+	}
+	return false
+}
+
+var (
+	// pragmaOnceRegexp matches #pragma once directives.
+	pragmaOnceRegexp = regexp.MustCompile(`^#\s*pragma\s+once\b`)
+	// ifndefRegexp matches the opening directive of an include guard,
+	// capturing the guard macro.
+	ifndefRegexp = regexp.MustCompile(`^#\s*(?:ifndef\s+(\w+)|if\s+!\s*defined\s*\(?\s*(\w+)\s*\)?)\s*$`)
+	// endifRegexp matches #endif directives.
+	endifRegexp = regexp.MustCompile(`^#\s*endif\b`)
+)
+
+// hasGuard reports whether the given header contents are protected against
+// multiple inclusion, either by #pragma once or by an include guard spanning
+// the entire header.
+func hasGuard(contents string) bool {
+	contents = cc.StripComments(contents)
+	var lines []string
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if pragmaOnceRegexp.MatchString(line) {
+			return true
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) < 3 {
+		return false
+	}
+	m := ifndefRegexp.FindStringSubmatch(lines[0])
+	if m == nil {
+		return false
+	}
+	guard := m[1]
+	if len(guard) == 0 {
+		guard = m[2]
+	}
+	defineRegexp := regexp.MustCompile(`^#\s*define\s+` + regexp.QuoteMeta(guard) + `\b`)
+	if !defineRegexp.MatchString(lines[1]) || !endifRegexp.MatchString(lines[len(lines)-1]) {
+		return false
+	}
+	// The closing #endif must match the opening directive of the guard.
+	depth := 0
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		directive := strings.TrimSpace(line[1:])
+		switch {
+		case strings.HasPrefix(directive, "if"):
+			depth++
+		case strings.HasPrefix(directive, "endif"):
+			depth--
+			if depth == 0 && i != len(lines)-1 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
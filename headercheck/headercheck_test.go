@@ -0,0 +1,105 @@
+package headercheck
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+func TestHasGuard(t *testing.T) {
+	golden := []struct {
+		contents string
+		want     bool
+	}{
+		{contents: "#ifndef FOO_H\n#define FOO_H\nint x;\n#endif\n", want: true},
+		{contents: "/* license */\n#if !defined(FOO_H)\n#define FOO_H\n#endif // FOO_H\n", want: true},
+		{contents: "#pragma once\nint x;\n", want: true},
+		{contents: "int x;\n", want: false},
+		// Code after the closing #endif of the guard.
+		{contents: "#ifndef FOO_H\n#define FOO_H\n#endif\nint x;\n", want: false},
+		// Nested conditional closed by the last #endif.
+		{contents: "#ifndef FOO_H\n#define FOO_H\n#endif\n#ifdef BAR\n#endif\n", want: false},
+		// Guard macro mismatch.
+		{contents: "#ifndef FOO_H\n#define BAR_H\n#endif\n", want: false},
+		// Comment delimiters in literals preceding the guard.
+		{contents: "// see \"/*\"\n#ifndef FOO_H\n#define FOO_H\nconst char *s = \"/*\";\nchar c = '/';\n#endif\n", want: true},
+		{contents: "#ifndef FOO_H\n#define FOO_H\nconst char *url = \"http://example.com\";\n#endif\n", want: true},
+	}
+	for i, g := range golden {
+		if got := hasGuard(g.contents); got != g.want {
+			t.Errorf("test %d: include guard mismatch; expected %v, got %v", i, g.want, got)
+		}
+	}
+}
+
+func TestIncludeDirective(t *testing.T) {
+	golden := []struct {
+		header string
+		want   string
+		err    bool
+	}{
+		{header: "/src/foo.h", want: "#include \"/src/foo.h\"\n"},
+		{header: `C:\src\foo.h`, want: "#include \"C:\\src\\foo.h\"\n"},
+		{header: "/src/bär.h", want: "#include \"/src/bär.h\"\n"},
+		{header: `/src/"foo".h`, err: true},
+	}
+	for i, g := range golden {
+		got, err := includeDirective(g.header)
+		if g.err {
+			if err == nil {
+				t.Errorf("test %d: expected error for header %q", i, g.header)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error; %v", i, err)
+			continue
+		}
+		if got != g.want {
+			t.Errorf("test %d: include directive mismatch; expected %q, got %q", i, g.want, got)
+		}
+	}
+}
+
+func TestCheckHeader(t *testing.T) {
+	headers := map[string]string{
+		"ok.h":      "#ifndef OK_H\n#define OK_H\ntypedef unsigned long size;\n#endif\n",
+		"missing.h": "#pragma once\nsize n;\n",
+		"noguard.h": "int x;\n",
+	}
+	dir, err := ioutil.TempDir("", "headercheck")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	for name, contents := range headers {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("unable to write header; %+v", err)
+		}
+	}
+	golden := []struct {
+		header       string
+		errors       bool
+		missingGuard bool
+	}{
+		{header: "ok.h"},
+		{header: "missing.h", errors: true},
+		{header: "noguard.h", missingGuard: true},
+	}
+	for _, g := range golden {
+		r, err := CheckHeader(filepath.Join(dir, g.header), &cc.Config{})
+		if err != nil {
+			t.Errorf("%q: unable to check header; %+v", g.header, err)
+			continue
+		}
+		if got := len(r.Errors) > 0; got != g.errors {
+			t.Errorf("%q: errors mismatch; expected %v, got %v", g.header, g.errors, r.Errors)
+		}
+		if r.MissingGuard != g.missingGuard {
+			t.Errorf("%q: missing guard mismatch; expected %v, got %v", g.header, g.missingGuard, r.MissingGuard)
+		}
+	}
+}
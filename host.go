@@ -0,0 +1,148 @@
+package cc
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Host is the compiler environment of a host compiler (e.g. GCC or Clang).
+type Host struct {
+	// Host compiler command (e.g. "gcc", "arm-none-eabi-gcc").
+	Compiler string
+	// Target triple of the host compiler (e.g. "x86_64-linux-gnu").
+	Target string
+	// System include directories of the host compiler, in search order.
+	IncludeDirs []string
+	// Predefined macros of the host compiler, as NAME=VALUE or
+	// NAME(PARAMS)=VALUE, sorted by name.
+	Macros []string
+}
+
+// DiscoverHost discovers the compiler environment of the given host compiler
+// for the given language standard (or the default language standard of the
+// host compiler if empty), by invoking the host compiler.
+func DiscoverHost(compiler string, std Std) (*Host, error) {
+	lang := "c"
+	if std.IsCXX() {
+		lang = "c++"
+	}
+	langArgs := []string{"-x", lang}
+	if len(std) > 0 {
+		langArgs = append(langArgs, "-std="+string(std))
+	}
+	h := &Host{Compiler: compiler}
+	// Target triple.
+	out, err := runCompiler(compiler, "-dumpmachine")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	h.Target = strings.TrimSpace(string(out))
+	// System include directories; reported on standard error.
+	args := append(append([]string(nil), langArgs...), "-E", "-v", "-")
+	cmd := exec.Command(compiler, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Errorf("%s %s failed: %v\n%s", compiler, strings.Join(args, " "), err, stderr.String())
+	}
+	h.IncludeDirs = parseIncludeDirs(stderr.Bytes())
+	// Predefined macros.
+	out, err = runCompiler(compiler, append(append([]string(nil), langArgs...), "-dM", "-E", "-")...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	h.Macros = parseMacros(out)
+	return h, nil
+}
+
+// runCompiler runs the given compiler command with empty standard input,
+// returning its output.
+func runCompiler(compiler string, args ...string) ([]byte, error) {
+	cmd := exec.Command(compiler, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Errorf("%s %s failed: %v\n%s", compiler, strings.Join(args, " "), err, stderr.String())
+	}
+	return out, nil
+}
+
+// parseIncludeDirs parses the system include directories of the verbose
+// output of a host compiler.
+//
+// Example output:
+//
+//	#include "..." search starts here:
+//	#include <...> search starts here:
+//	 /usr/lib/gcc/x86_64-linux-gnu/12/include
+//	 /usr/include
+//	End of search list.
+func parseIncludeDirs(output []byte) []string {
+	var dirs []string
+	inList := false
+	s := bufio.NewScanner(bytes.NewReader(output))
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case strings.HasPrefix(line, "#include <...> search starts here:"):
+			inList = true
+		case strings.HasPrefix(line, "End of search list."):
+			inList = false
+		case inList:
+			dir := strings.TrimSpace(line)
+			// Remove annotation of macOS framework directories.
+			dir = strings.TrimSuffix(dir, " (framework directory)")
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+	}
+	return dirs
+}
+
+// parseMacros parses the macro definitions output by a host compiler,
+// returning the macros as NAME=VALUE or NAME(PARAMS)=VALUE sorted by name.
+// Macros implemented as builtins by Clang (e.g. __has_include) are omitted, as
+// Clang does not allow them to be redefined.
+//
+// Example output:
+//
+//	#define __STDC__ 1
+//	#define __x86_64__ 1
+//	#define __has_include(STR) __has_include__(STR)
+func parseMacros(output []byte) []string {
+	var macros []string
+	s := bufio.NewScanner(bytes.NewReader(output))
+	for s.Scan() {
+		line := s.Text()
+		if !strings.HasPrefix(line, "#define ") {
+			continue
+		}
+		def := line[len("#define "):]
+		// The name of function-like macros is directly followed by the
+		// parameter list, which may contain spaces.
+		end := strings.IndexAny(def, " (")
+		if end != -1 && def[end] == '(' {
+			if i := strings.IndexByte(def[end:], ')'); i != -1 {
+				end += i + 1
+			} else {
+				end = -1
+			}
+		}
+		name, value := def, ""
+		if end != -1 {
+			name, value = def[:end], strings.TrimPrefix(def[end:], " ")
+		}
+		if strings.HasPrefix(name, "__has_") {
+			continue
+		}
+		macros = append(macros, name+"="+value)
+	}
+	sort.Strings(macros)
+	return macros
+}
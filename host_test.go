@@ -0,0 +1,113 @@
+package cc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIncludeDirs(t *testing.T) {
+	const output = `Using built-in specs.
+#include "..." search starts here:
+#include <...> search starts here:
+ /usr/lib/gcc/x86_64-linux-gnu/12/include
+ /usr/local/include/
+ /System/Library/Frameworks (framework directory)
+End of search list.
+`
+	want := []string{
+		"/usr/lib/gcc/x86_64-linux-gnu/12/include",
+		"/usr/local/include",
+		"/System/Library/Frameworks",
+	}
+	if got := parseIncludeDirs([]byte(output)); !reflect.DeepEqual(got, want) {
+		t.Errorf("include directories mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestParseMacros(t *testing.T) {
+	const output = `#define __x86_64__ 1
+#define __VERSION__ "12.2.0 (Debian)"
+#define __GCC_HAVE_DWARF2_CFI_ASM 1
+#define __has_include(STR) __has_include__(STR)
+#define __EMPTY__
+#define __ADD(a, b) ((a) + (b))
+#define __ID(x)x
+`
+	want := []string{
+		"__ADD(a, b)=((a) + (b))",
+		"__EMPTY__=",
+		"__GCC_HAVE_DWARF2_CFI_ASM=1",
+		"__ID(x)=x",
+		`__VERSION__="12.2.0 (Debian)"`,
+		"__x86_64__=1",
+	}
+	if got := parseMacros([]byte(output)); !reflect.DeepEqual(got, want) {
+		t.Errorf("macros mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestConfigClangArgs(t *testing.T) {
+	golden := []struct {
+		config *Config
+		want   []string
+		err    bool
+	}{
+		{
+			config: &Config{},
+			want:   nil,
+		},
+		{
+			config: &Config{
+				Target:            "armv7-none-eabi",
+				Std:               StdC99,
+				Defines:           []string{"FOO", "BAR=2"},
+				Undefines:         []string{"BAZ"},
+				IncludeDirs:       []string{"inc"},
+				SystemIncludeDirs: []string{"/opt/inc"},
+				Sysroot:           "/opt/sysroot",
+				Args:              []string{"-Wall"},
+			},
+			want: []string{"-target", "armv7-none-eabi", "-x", "c", "-std=c99", "--sysroot", "/opt/sysroot", "-DFOO", "-DBAR=2", "-UBAZ", "-Iinc", "-isystem", "/opt/inc", "-Wall"},
+		},
+		{
+			config: &Config{
+				Std: StdCXX17,
+				Host: &Host{
+					Target:      "x86_64-linux-gnu",
+					IncludeDirs: []string{"/usr/include"},
+					Macros:      []string{"__ADD(a, b)=((a) + (b))", "__GNUC_MINOR__=2", "__GNUC__=12", "__STDC_VERSION__=201710L", `__VERSION__="12.2.0"`, "__cplusplus=201703L", "__x86_64__=1"},
+				},
+			},
+			want: []string{"-target", "x86_64-linux-gnu", "-x", "c++", "-std=c++1z", "-nostdinc", "-U__ADD", "-D__ADD(a, b)=((a) + (b))", "-U__x86_64__", "-D__x86_64__=1", "-isystem", "/usr/include"},
+		},
+		{
+			config: &Config{Std: StdC17},
+			want:   []string{"-x", "c", "-std=c11"},
+		},
+		// Language standards unsupported by Clang 3.9.
+		{
+			config: &Config{Std: "c2x"},
+			err:    true,
+		},
+		{
+			config: &Config{Std: "c++20"},
+			err:    true,
+		},
+	}
+	for i, g := range golden {
+		got, err := g.config.ClangArgs()
+		if g.err {
+			if err == nil {
+				t.Errorf("test %d: expected error for language standard %q", i, g.config.Std)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unable to get Clang arguments; %+v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, g.want) {
+			t.Errorf("test %d: Clang arguments mismatch; expected %q, got %q", i, g.want, got)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package impact
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FileChange records the changed lines of a file.
+type FileChange struct {
+	// Path of the changed file. For deleted files, the path of the old file.
+	Path string
+	// Changed line ranges of the new file, in ascending order. Deleted lines
+	// are recorded at the line following the deletion.
+	Lines []Range
+	// File deleted.
+	Deleted bool
+}
+
+// Range is a range of lines.
+type Range struct {
+	// First line (1-indexed).
+	Start uint32
+	// Last line (inclusive).
+	End uint32
+}
+
+// Overlaps reports whether the line range overlaps the given lines.
+func (r Range) Overlaps(start, end uint32) bool {
+	return r.Start <= end && start <= r.End
+}
+
+// addLine adds the given line to the changed lines of the file.
+func (fc *FileChange) addLine(line uint32) {
+	if n := len(fc.Lines); n > 0 {
+		last := &fc.Lines[n-1]
+		if line <= last.End+1 {
+			if line > last.End {
+				last.End = line
+			}
+			return
+		}
+	}
+	fc.Lines = append(fc.Lines, Range{Start: line, End: line})
+}
+
+// Overlaps reports whether any changed line of the file overlaps the given
+// lines.
+func (fc *FileChange) Overlaps(start, end uint32) bool {
+	if fc.Deleted {
+		return true
+	}
+	for _, r := range fc.Lines {
+		if r.Overlaps(start, end) {
+			return true
+		}
+	}
+	return false
+}
+
+// hunkRegexp matches the header of a unified diff hunk, capturing the line
+// count of the old file and the start line and line count of the new file.
+var hunkRegexp = regexp.MustCompile(`^@@ -[0-9]+(?:,([0-9]+))? \+([0-9]+)(?:,([0-9]+))? @@`)
+
+// ParseDiff parses the given unified diff, returning the changed lines of each
+// file. The "a/" and "b/" path prefixes of Git diffs are removed.
+func ParseDiff(r io.Reader) ([]*FileChange, error) {
+	var (
+		changes []*FileChange
+		// Current file.
+		fc *FileChange
+		// Old path of current file.
+		oldPath string
+		// Current line of new file.
+		line uint32
+		// Remaining lines of the old and new file in the current hunk. Lines
+		// of a hunk may start with "--- " or "+++ " (e.g. a removed line
+		// starting with "-- "), and are only interpreted as file headers once
+		// the hunk is consumed.
+		oldLeft, newLeft uint64
+	)
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 16*1024*1024)
+	for s.Scan() {
+		l := s.Text()
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(l, "+"):
+				if newLeft == 0 {
+					return nil, errors.Errorf("hunk line %q exceeds line count of new file", l)
+				}
+				fc.addLine(line)
+				line++
+				newLeft--
+			case strings.HasPrefix(l, "-"):
+				if oldLeft == 0 {
+					return nil, errors.Errorf("hunk line %q exceeds line count of old file", l)
+				}
+				fc.addLine(line)
+				oldLeft--
+			case strings.HasPrefix(l, " "), len(l) == 0:
+				// Context line; empty lines are context lines whose leading
+				// space was stripped (e.g. by an editor).
+				if oldLeft == 0 || newLeft == 0 {
+					return nil, errors.Errorf("hunk line %q exceeds line count of old or new file", l)
+				}
+				line++
+				oldLeft--
+				newLeft--
+			case strings.HasPrefix(l, `\`):
+				// "\ No newline at end of file".
+			default:
+				return nil, errors.Errorf("invalid hunk line %q", l)
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(l, "--- "):
+			oldPath = diffPath(l[len("--- "):], "a/")
+		case strings.HasPrefix(l, "+++ "):
+			fc = &FileChange{Path: diffPath(l[len("+++ "):], "b/")}
+			if fc.Path == "/dev/null" {
+				fc.Path = oldPath
+				fc.Deleted = true
+			}
+			changes = append(changes, fc)
+		case strings.HasPrefix(l, "@@"):
+			if fc == nil {
+				return nil, errors.Errorf("hunk %q without file header", l)
+			}
+			m := hunkRegexp.FindStringSubmatch(l)
+			if m == nil {
+				return nil, errors.Errorf("invalid hunk header %q", l)
+			}
+			start, err := strconv.ParseUint(m[2], 10, 32)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if oldLeft, err = hunkCount(m[1]); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if newLeft, err = hunkCount(m[3]); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			line = uint32(start)
+			if line == 0 {
+				// Pure deletion at the beginning of the file.
+				line = 1
+			}
+		case fc != nil && (strings.HasPrefix(l, "+") || strings.HasPrefix(l, "-") || strings.HasPrefix(l, " ")):
+			return nil, errors.Errorf("hunk line %q exceeds line counts of hunk", l)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if oldLeft > 0 || newLeft > 0 {
+		return nil, errors.New("truncated hunk at end of diff")
+	}
+	return changes, nil
+}
+
+// hunkCount returns the line count of the given hunk range; the count is 1 if
+// omitted.
+func hunkCount(s string) (uint64, error) {
+	if len(s) == 0 {
+		return 1, nil
+	}
+	return strconv.ParseUint(s, 10, 32)
+}
+
+// diffPath returns the file path of the given diff file header, with the given
+// Git path prefix removed.
+func diffPath(header, prefix string) string {
+	// Remove timestamp of non-Git diffs.
+	if i := strings.IndexByte(header, '\t'); i != -1 {
+		header = header[:i]
+	}
+	return strings.TrimPrefix(header, prefix)
+}
+
+// GitDiff returns the changed lines of each file between the given revisions
+// of the Git repository containing dir. If the new revision is empty, the old
+// revision is compared against the working tree. File paths are absolute.
+func GitDiff(dir, oldRev, newRev string) ([]*FileChange, error) {
+	out, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	top := strings.TrimSpace(string(out))
+	args := []string{"diff", "--no-color", "--no-ext-diff", "-U0", oldRev}
+	if len(newRev) > 0 {
+		args = append(args, newRev)
+	}
+	out, err = git(dir, args...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	changes, err := ParseDiff(bytes.NewReader(out))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, fc := range changes {
+		fc.Path = filepath.Join(top, fc.Path)
+	}
+	return changes, nil
+}
+
+// git runs the given Git command in dir, returning its output.
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Errorf("git %s failed: %v\n%s", strings.Join(args, " "), err, stderr.String())
+	}
+	return out, nil
+}
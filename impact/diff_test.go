@@ -0,0 +1,90 @@
+package impact
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDiff(t *testing.T) {
+	golden := []struct {
+		diff string
+		want []*FileChange
+	}{
+		// Modified lines with context.
+		{
+			diff: `diff --git a/foo.c b/foo.c
+index 1111111..2222222 100644
+--- a/foo.c
++++ b/foo.c
+@@ -1,4 +1,4 @@
+ int x;
+-int y;
++long y;
+ int z;
+ int w;
+`,
+			want: []*FileChange{{Path: "foo.c", Lines: []Range{{Start: 2, End: 2}}}},
+		},
+		// Removed line starting with "-- " and added line starting with "++ ",
+		// which resemble file headers.
+		{
+			diff: `--- a/foo.sql
++++ b/foo.sql
+@@ -2,2 +2,2 @@
+--- comment
++++ counter
+ x
+--- a/bar.c
++++ b/bar.c
+@@ -5,0 +6,2 @@
++int a;
++int b;
+`,
+			want: []*FileChange{
+				{Path: "foo.sql", Lines: []Range{{Start: 2, End: 2}}},
+				{Path: "bar.c", Lines: []Range{{Start: 6, End: 7}}},
+			},
+		},
+		// Deleted file and omitted line counts.
+		{
+			diff: `--- a/old.h
++++ /dev/null
+@@ -1 +0,0 @@
+-int old;
+\ No newline at end of file
+`,
+			want: []*FileChange{{Path: "old.h", Lines: []Range{{Start: 1, End: 1}}, Deleted: true}},
+		},
+	}
+	for i, g := range golden {
+		got, err := ParseDiff(strings.NewReader(g.diff))
+		if err != nil {
+			t.Errorf("test %d: unable to parse diff; %+v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, g.want) {
+			t.Errorf("test %d: file changes mismatch; expected %v, got %v", i, g.want, got)
+		}
+	}
+}
+
+func TestParseDiffInvalid(t *testing.T) {
+	golden := []string{
+		// Truncated hunk.
+		"--- a/foo.c\n+++ b/foo.c\n@@ -1,3 +1,3 @@\n int x;\n",
+		// Added lines exceeding the line count of the new file.
+		"--- a/foo.c\n+++ b/foo.c\n@@ -1,2 +1,1 @@\n-int x;\n+int y;\n+int z;\n",
+		// Removed lines exceeding the line count of the old file.
+		"--- a/foo.c\n+++ b/foo.c\n@@ -1,1 +1,2 @@\n-int x;\n-int y;\n",
+		// Context line exceeding the line count of the new file.
+		"--- a/foo.c\n+++ b/foo.c\n@@ -1,2 +1,1 @@\n int x;\n int y;\n",
+		// Lines following a consumed hunk.
+		"--- a/foo.c\n+++ b/foo.c\n@@ -1 +1 @@\n-int x;\n+int y;\n+int z;\n",
+	}
+	for _, diff := range golden {
+		if _, err := ParseDiff(strings.NewReader(diff)); err == nil {
+			t.Errorf("%q: expected error for invalid hunk", diff)
+		}
+	}
+}
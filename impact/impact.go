@@ -0,0 +1,195 @@
+// Package impact maps source code changes to the affected declarations,
+// callers and translation units of a program.
+//
+// Changed line ranges (e.g. from a unified diff or two Git revisions) are
+// mapped to the enclosing declarations using node extents. The impact is then
+// propagated to the transitive callers of changed functions through the call
+// graph, and to the translation units including changed files through the
+// include graph.
+package impact
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/callgraph"
+)
+
+// Report is the impact of a set of changes on a program.
+type Report struct {
+	// Declarations enclosing changed lines, sorted by location.
+	Changed []*cc.Node
+	// Transitive callers of changed functions, sorted by name.
+	Callers []*callgraph.Func
+	// Affected translation units, sorted by path.
+	TUs []string
+}
+
+// Analyze analyzes the impact of the given changes on the program consisting
+// of the given translation units. Changed methods of C++ records are reported
+// along with the enclosing record. The include graph is only complete for
+// translation units parsed with cc.PreprocessingRecordArgs.
+func Analyze(files []*cc.File, changes []*FileChange) *Report {
+	changeFromPath := make(map[string]*FileChange)
+	for _, fc := range changes {
+		changeFromPath[absPath(fc.Path)] = fc
+	}
+	// Locate declarations enclosing changed lines.
+	r := &Report{}
+	seen := make(map[string]bool)
+	var visit func(n *cc.Node)
+	visit = func(n *cc.Node) {
+		switch n.Body.Kind() {
+		case clang.Cursor_Namespace:
+			for _, child := range n.Children {
+				visit(child)
+			}
+			return
+		case clang.Cursor_StructDecl, clang.Cursor_ClassDecl:
+			// Locate changed methods declared inside of C++ records, in
+			// addition to the record itself.
+			for _, child := range n.Children {
+				switch child.Body.Kind() {
+				case clang.Cursor_CXXMethod, clang.Cursor_Constructor, clang.Cursor_Destructor:
+					visit(child)
+				}
+			}
+		}
+		if !n.Body.Kind().IsDeclaration() {
+			// Preprocessing nodes (e.g. macro expansions, inclusion directives).
+			return
+		}
+		start, end := n.Extent()
+		fc, ok := changeFromPath[absPath(start.File)]
+		if !ok || !fc.Overlaps(start.Line, end.Line) {
+			return
+		}
+		key := n.Body.USR()
+		if len(key) == 0 {
+			key = n.Loc.String()
+		}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		r.Changed = append(r.Changed, n)
+	}
+	for _, file := range files {
+		for _, n := range file.Root.Children {
+			visit(n)
+		}
+	}
+	sort.Slice(r.Changed, func(i, j int) bool {
+		a, b := r.Changed[i].Loc, r.Changed[j].Loc
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	// Propagate through the call graph.
+	cg := callgraph.New(files...)
+	var changedFuncs []*callgraph.Func
+	for _, n := range r.Changed {
+		if f, ok := cg.Funcs[n.Body.USR()]; ok {
+			changedFuncs = append(changedFuncs, f)
+		}
+	}
+	r.Callers = callgraph.Callers(changedFuncs...)
+	// Propagate through the include graph.
+	ig := NewIncludeGraph(files...)
+	tus := make(map[string]bool)
+	for path := range changeFromPath {
+		for _, tu := range ig.TUs(path) {
+			tus[tu] = true
+		}
+	}
+	for _, f := range append(changedFuncs, r.Callers...) {
+		if len(f.TU) > 0 {
+			tus[absPath(f.TU)] = true
+		}
+	}
+	for tu := range tus {
+		r.TUs = append(r.TUs, tu)
+	}
+	sort.Strings(r.TUs)
+	return r
+}
+
+// IncludeGraph is an include graph of a program.
+type IncludeGraph struct {
+	// Translation units of the program.
+	tus map[string]bool
+	// Maps from file path to the files directly including the file.
+	includers map[string]map[string]bool
+}
+
+// NewIncludeGraph returns the include graph of the program consisting of the
+// given translation units. Inclusion directives are only recorded for
+// translation units parsed with cc.PreprocessingRecordArgs.
+func NewIncludeGraph(files ...*cc.File) *IncludeGraph {
+	g := &IncludeGraph{
+		tus:       make(map[string]bool),
+		includers: make(map[string]map[string]bool),
+	}
+	for _, file := range files {
+		g.tus[absPath(file.Root.Body.Spelling())] = true
+		for _, n := range file.Root.Children {
+			if n.Body.Kind() != clang.Cursor_InclusionDirective {
+				continue
+			}
+			includer := absPath(n.Loc.File)
+			included := absPath(n.Body.IncludedFile().Name())
+			if g.includers[included] == nil {
+				g.includers[included] = make(map[string]bool)
+			}
+			g.includers[included][includer] = true
+		}
+	}
+	return g
+}
+
+// Includers returns the files directly including the given file, sorted by
+// path.
+func (g *IncludeGraph) Includers(path string) []string {
+	var includers []string
+	for includer := range g.includers[absPath(path)] {
+		includers = append(includers, includer)
+	}
+	sort.Strings(includers)
+	return includers
+}
+
+// TUs returns the translation units transitively including the given file,
+// sorted by path. The file itself is included if it is a translation unit.
+func (g *IncludeGraph) TUs(path string) []string {
+	path = absPath(path)
+	visited := map[string]bool{path: true}
+	queue := []string{path}
+	var tus []string
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if g.tus[p] {
+			tus = append(tus, p)
+		}
+		for includer := range g.includers[p] {
+			if !visited[includer] {
+				visited[includer] = true
+				queue = append(queue, includer)
+			}
+		}
+	}
+	sort.Strings(tus)
+	return tus
+}
+
+// absPath returns the cleaned absolute path of the given file path; or the
+// cleaned path if unable to resolve the absolute path.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,93 @@
+package impact
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+func TestAnalyze(t *testing.T) {
+	const src = `#define TWICE(x) ((x) * 2)
+static int leaf(int x) {
+	return TWICE(x);
+}
+int mid(int x) { return leaf(x); }
+int top(void) { return mid(1); }
+int other(void) { return 0; }
+`
+	path, file := parse(t, "impact.c", src, cc.PreprocessingRecordArgs...)
+	defer file.Close()
+	// Change the line of leaf containing the macro expansion.
+	changes := []*FileChange{{Path: path, Lines: []Range{{Start: 3, End: 3}}}}
+	r := Analyze([]*cc.File{file}, changes)
+	if len(r.Changed) != 1 || r.Changed[0].Body.Spelling() != "leaf" {
+		var names []string
+		for _, n := range r.Changed {
+			names = append(names, n.Body.Kind().String()+" "+n.Body.Spelling())
+		}
+		t.Fatalf("changed declarations mismatch; expected [leaf], got %v", names)
+	}
+	var callers []string
+	for _, f := range r.Callers {
+		callers = append(callers, f.Name)
+	}
+	if len(callers) != 2 || callers[0] != "mid" || callers[1] != "top" {
+		t.Errorf("callers mismatch; expected [mid top], got %v", callers)
+	}
+	if len(r.TUs) != 1 || r.TUs[0] != absPath(path) {
+		t.Errorf("translation units mismatch; expected [%s], got %v", absPath(path), r.TUs)
+	}
+}
+
+func TestAnalyzeMethod(t *testing.T) {
+	const src = `struct S {
+	int get() const {
+		return v;
+	}
+	int v;
+};
+int use(S s) { return s.get(); }
+`
+	path, file := parse(t, "impact.cc", src, "-x", "c++")
+	defer file.Close()
+	// Change the body of the method get.
+	changes := []*FileChange{{Path: path, Lines: []Range{{Start: 3, End: 3}}}}
+	r := Analyze([]*cc.File{file}, changes)
+	var names []string
+	for _, n := range r.Changed {
+		names = append(names, n.Body.Spelling())
+	}
+	if len(names) != 2 || names[0] != "S" || names[1] != "get" {
+		t.Fatalf("changed declarations mismatch; expected [S get], got %v", names)
+	}
+	var callers []string
+	for _, f := range r.Callers {
+		callers = append(callers, f.Name)
+	}
+	if len(callers) != 1 || callers[0] != "use" {
+		t.Errorf("callers mismatch; expected [use], got %v", callers)
+	}
+}
+
+// parse parses the given source, written to a file with the given name in a
+// temporary directory, returning the path of the source file.
+func parse(t *testing.T, name, src string, args ...string) (string, *cc.File) {
+	dir, err := ioutil.TempDir("", "impact")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatalf("unable to write source file; %+v", err)
+	}
+	file, err := cc.ParseFile(path, args...)
+	if err != nil {
+		file.Close()
+		t.Fatalf("unable to parse %q; %+v", name, err)
+	}
+	return path, file
+}
@@ -0,0 +1,140 @@
+// Package index implements a cross translation unit symbol index.
+//
+// Symbols are identified by their Unified Symbol Resolution (USR), which is
+// stable across translation units. Symbols with internal linkage (e.g. static
+// functions) have USRs specific to the file declaring them.
+package index
+
+import (
+	"sort"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+)
+
+// Index is a cross translation unit symbol index.
+type Index struct {
+	// Symbols of the index, indexed by USR.
+	Symbols map[string]*Symbol
+}
+
+// Symbol is a symbol of a program.
+type Symbol struct {
+	// Unified Symbol Resolution of the symbol.
+	USR string
+	// Symbol name.
+	Name string
+	// Kind of declaration.
+	Kind clang.CursorKind
+	// Declarations of the symbol, including definitions, in order of
+	// occurrence.
+	Decls []*Occurrence
+	// References to the symbol, in order of occurrence.
+	Refs []*Occurrence
+}
+
+// Occurrence is an occurrence of a symbol in a translation unit.
+type Occurrence struct {
+	// Node of the declaration or reference.
+	Node *cc.Node
+	// Path of the translation unit.
+	TU string
+}
+
+// Defs returns the definitions of the symbol.
+func (sym *Symbol) Defs() []*Occurrence {
+	var defs []*Occurrence
+	for _, decl := range sym.Decls {
+		if decl.Node.Body.IsCursorDefinition() {
+			defs = append(defs, decl)
+		}
+	}
+	return defs
+}
+
+// New returns the symbol index of the given translation units. Declarations
+// and references of headers included by several translation units are
+// recorded once per translation unit, so that layouts may be compared between
+// translation units.
+func New(files ...*cc.File) *Index {
+	idx := &Index{
+		Symbols: make(map[string]*Symbol),
+	}
+	for _, file := range files {
+		tu := file.Root.Body.Spelling()
+		cc.Walk(file.Root, func(n *cc.Node) {
+			if n == file.Root {
+				return
+			}
+			kind := n.Body.Kind()
+			switch {
+			case kind == clang.Cursor_MacroDefinition, kind.IsDeclaration():
+				usr := n.Body.USR()
+				if len(usr) == 0 {
+					return
+				}
+				sym := idx.symbol(usr, n.Body)
+				sym.Decls = append(sym.Decls, &Occurrence{Node: n, TU: tu})
+			case kind == clang.Cursor_MacroExpansion, kind.IsReference(), kind == clang.Cursor_DeclRefExpr, kind == clang.Cursor_MemberRefExpr:
+				ref := n.Body.Referenced()
+				if ref.IsNull() {
+					return
+				}
+				usr := ref.USR()
+				if len(usr) == 0 {
+					return
+				}
+				sym := idx.symbol(usr, ref)
+				sym.Refs = append(sym.Refs, &Occurrence{Node: n, TU: tu})
+			}
+		})
+	}
+	return idx
+}
+
+// symbol returns the symbol of the given USR, creating a new symbol based on
+// the given declaration if not already present.
+func (idx *Index) symbol(usr string, decl clang.Cursor) *Symbol {
+	if sym, ok := idx.Symbols[usr]; ok {
+		return sym
+	}
+	sym := &Symbol{
+		USR:  usr,
+		Name: decl.Spelling(),
+		Kind: decl.Kind(),
+	}
+	idx.Symbols[usr] = sym
+	return sym
+}
+
+// Lookup returns the symbols with the given name, sorted by USR.
+func (idx *Index) Lookup(name string) []*Symbol {
+	var syms []*Symbol
+	for _, sym := range idx.Symbols {
+		if sym.Name == name {
+			syms = append(syms, sym)
+		}
+	}
+	sortSymbols(syms)
+	return syms
+}
+
+// Sorted returns the symbols of the index sorted by name.
+func (idx *Index) Sorted() []*Symbol {
+	var syms []*Symbol
+	for _, sym := range idx.Symbols {
+		syms = append(syms, sym)
+	}
+	sortSymbols(syms)
+	return syms
+}
+
+// sortSymbols sorts the given symbols by name.
+func sortSymbols(syms []*Symbol) {
+	sort.Slice(syms, func(i, j int) bool {
+		if syms[i].Name != syms[j].Name {
+			return syms[i].Name < syms[j].Name
+		}
+		return syms[i].USR < syms[j].USR
+	})
+}
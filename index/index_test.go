@@ -0,0 +1,54 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+func TestIndex(t *testing.T) {
+	srcs := []struct {
+		path string
+		src  string
+	}{
+		{path: "a.c", src: "int f(int x);\nint g(void) { return f(1) + f(2); }\n"},
+		{path: "b.c", src: "int f(int x) { return x; }\nstatic int h;\n"},
+	}
+	var files []*cc.File
+	for _, s := range srcs {
+		file, err := cc.ParseSource(s.path, s.src)
+		if err != nil {
+			t.Fatalf("unable to parse %q; %+v", s.path, err)
+		}
+		defer file.Close()
+		files = append(files, file)
+	}
+	idx := New(files...)
+	golden := []struct {
+		name  string
+		decls int
+		defs  int
+		refs  int
+	}{
+		{name: "f", decls: 2, defs: 1, refs: 2},
+		{name: "g", decls: 1, defs: 1},
+		{name: "h", decls: 1, defs: 1},
+	}
+	for _, want := range golden {
+		syms := idx.Lookup(want.name)
+		if len(syms) != 1 {
+			t.Errorf("%q: number of symbols mismatch; expected 1, got %d", want.name, len(syms))
+			continue
+		}
+		sym := syms[0]
+		if got := len(sym.Decls); got != want.decls {
+			t.Errorf("%q: number of declarations mismatch; expected %d, got %d", want.name, want.decls, got)
+		}
+		if got := len(sym.Defs()); got != want.defs {
+			t.Errorf("%q: number of definitions mismatch; expected %d, got %d", want.name, want.defs, got)
+		}
+		if got := len(sym.Refs); got != want.refs {
+			t.Errorf("%q: number of references mismatch; expected %d, got %d", want.name, want.refs, got)
+		}
+	}
+}
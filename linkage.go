@@ -0,0 +1,269 @@
+package cc
+
+import (
+	"sort"
+
+	"github.com/go-clang/clang-v3.9/clang"
+)
+
+// Linkage specifies the linkage of a declaration.
+type Linkage uint8
+
+// Linkage kinds.
+const (
+	// Invalid linkage; the node is not a declaration.
+	LinkageInvalid Linkage = iota
+	// No linkage (e.g. local variables, parameters, types).
+	LinkageNone
+	// Internal linkage (e.g. static functions and global variables).
+	LinkageInternal
+	// External linkage within an anonymous namespace (C++).
+	LinkageUniqueExternal
+	// External linkage.
+	LinkageExternal
+)
+
+// String returns a string representation of the linkage.
+func (l Linkage) String() string {
+	switch l {
+	case LinkageInvalid:
+		return "invalid"
+	case LinkageNone:
+		return "none"
+	case LinkageInternal:
+		return "internal"
+	case LinkageUniqueExternal:
+		return "unique"
+	case LinkageExternal:
+		return "external"
+	}
+	return "unknown"
+}
+
+// StorageClass specifies the storage class of a declaration.
+type StorageClass uint8
+
+// Storage classes.
+const (
+	// Invalid storage class; the node is not a declaration.
+	StorageInvalid StorageClass = iota
+	// No storage class specifier.
+	StorageNone
+	// extern storage class.
+	StorageExtern
+	// static storage class.
+	StorageStatic
+	// __private_extern__ storage class.
+	StoragePrivateExtern
+	// auto storage class.
+	StorageAuto
+	// register storage class.
+	StorageRegister
+)
+
+// String returns a string representation of the storage class.
+func (sc StorageClass) String() string {
+	switch sc {
+	case StorageInvalid:
+		return "invalid"
+	case StorageNone:
+		return "none"
+	case StorageExtern:
+		return "extern"
+	case StorageStatic:
+		return "static"
+	case StoragePrivateExtern:
+		return "__private_extern__"
+	case StorageAuto:
+		return "auto"
+	case StorageRegister:
+		return "register"
+	}
+	return "unknown"
+}
+
+// Visibility specifies the symbol visibility of a declaration.
+type Visibility uint8
+
+// Symbol visibilities.
+const (
+	// Invalid visibility; the node is not a declaration.
+	VisibilityInvalid Visibility = iota
+	// Symbol not visible outside of the shared object.
+	VisibilityHidden
+	// Symbol visible outside of the shared object but not preemptable.
+	VisibilityProtected
+	// Symbol visible outside of the shared object.
+	VisibilityDefault
+)
+
+// String returns a string representation of the symbol visibility.
+func (v Visibility) String() string {
+	switch v {
+	case VisibilityInvalid:
+		return "invalid"
+	case VisibilityHidden:
+		return "hidden"
+	case VisibilityProtected:
+		return "protected"
+	case VisibilityDefault:
+		return "default"
+	}
+	return "unknown"
+}
+
+// Linkage returns the linkage of the declaration node.
+func (n *Node) Linkage() Linkage {
+	switch n.Body.Linkage() {
+	case clang.Linkage_NoLinkage:
+		return LinkageNone
+	case clang.Linkage_Internal:
+		return LinkageInternal
+	case clang.Linkage_UniqueExternal:
+		return LinkageUniqueExternal
+	case clang.Linkage_External:
+		return LinkageExternal
+	}
+	return LinkageInvalid
+}
+
+// StorageClass returns the storage class of the declaration node.
+func (n *Node) StorageClass() StorageClass {
+	switch n.Body.StorageClass() {
+	case clang.SC_None:
+		return StorageNone
+	case clang.SC_Extern:
+		return StorageExtern
+	case clang.SC_Static:
+		return StorageStatic
+	case clang.SC_PrivateExtern:
+		return StoragePrivateExtern
+	case clang.SC_Auto:
+		return StorageAuto
+	case clang.SC_Register:
+		return StorageRegister
+	}
+	return StorageInvalid
+}
+
+// Visibility returns the symbol visibility of the declaration node.
+func (n *Node) Visibility() Visibility {
+	switch n.Body.Visibility() {
+	case clang.Visibility_Hidden:
+		return VisibilityHidden
+	case clang.Visibility_Protected:
+		return VisibilityProtected
+	case clang.Visibility_Default:
+		return VisibilityDefault
+	}
+	return VisibilityInvalid
+}
+
+// IsInline reports whether the node is a function declaration marked inline.
+func (n *Node) IsInline() bool {
+	switch n.Body.Kind() {
+	case clang.Cursor_FunctionDecl, clang.Cursor_CXXMethod:
+		return n.Body.IsFunctionInlined()
+	}
+	return false
+}
+
+// IsThreadLocal reports whether the node is a variable declaration with thread
+// storage duration.
+func (n *Node) IsThreadLocal() bool {
+	if n.Body.Kind() != clang.Cursor_VarDecl {
+		return false
+	}
+	// Clang 3.9 does not expose the TLS kind of declarations, so we look for
+	// the thread storage class specifier among the tokens of the declaration.
+	name := n.Body.Spelling()
+	for _, tok := range n.Tokens() {
+		switch tok {
+		case "_Thread_local", "__thread", "thread_local":
+			return true
+		case name:
+			// Specifiers precede the declarator.
+			return false
+		}
+	}
+	return false
+}
+
+// Symbol is an externally visible symbol of a project.
+type Symbol struct {
+	// Symbol name.
+	Name string
+	// Unified Symbol Resolution of the symbol.
+	USR string
+	// Declaration node of the symbol; the definition if present.
+	Decl *Node
+	// Linkage of the symbol.
+	Linkage Linkage
+	// Storage class of the symbol.
+	StorageClass StorageClass
+	// Symbol visibility.
+	Visibility Visibility
+	// Inline function.
+	Inline bool
+	// Thread-local variable.
+	ThreadLocal bool
+	// Symbol is defined in the project.
+	Defined bool
+}
+
+// ExternalSymbols returns the externally visible symbols (functions and global
+// variables with external linkage and non-hidden visibility) declared in the
+// given files, sorted by name. Declarations located in system headers are
+// ignored.
+func ExternalSymbols(files ...*File) []*Symbol {
+	symFromUSR := make(map[string]*Symbol)
+	for _, file := range files {
+		Walk(file.Root, func(n *Node) {
+			switch n.Body.Kind() {
+			case clang.Cursor_FunctionDecl, clang.Cursor_VarDecl, clang.Cursor_CXXMethod:
+				// externally visible symbol candidate.
+			default:
+				return
+			}
+			if n.Body.Location().IsInSystemHeader() {
+				return
+			}
+			if n.Linkage() != LinkageExternal || n.Visibility() == VisibilityHidden {
+				return
+			}
+			usr := n.Body.USR()
+			sym, ok := symFromUSR[usr]
+			if !ok {
+				sym = &Symbol{
+					Name: n.Body.Spelling(),
+					USR:  usr,
+				}
+				symFromUSR[usr] = sym
+			}
+			// Variable declarations without extern are definitions; tentative
+			// definitions (e.g. "int x;") are not definition cursors in every
+			// translation unit.
+			def := n.Body.IsCursorDefinition() || (n.Body.Kind() == clang.Cursor_VarDecl && n.StorageClass() != StorageExtern)
+			if sym.Decl == nil || (def && !sym.Defined) {
+				sym.Decl = n
+				sym.Linkage = n.Linkage()
+				sym.StorageClass = n.StorageClass()
+				sym.Visibility = n.Visibility()
+				sym.Inline = n.IsInline()
+				sym.ThreadLocal = n.IsThreadLocal()
+			}
+			sym.Defined = sym.Defined || def
+		})
+	}
+	var syms []*Symbol
+	for _, sym := range symFromUSR {
+		syms = append(syms, sym)
+	}
+	sort.Slice(syms, func(i, j int) bool {
+		if syms[i].Name != syms[j].Name {
+			return syms[i].Name < syms[j].Name
+		}
+		return syms[i].USR < syms[j].USR
+	})
+	return syms
+}
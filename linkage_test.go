@@ -0,0 +1,173 @@
+package cc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-clang/clang-v3.9/clang"
+)
+
+// parseSource parses the given source, written to a source file of the given
+// name in a temporary directory.
+func parseSource(t *testing.T, name, src string) *File {
+	dir, err := ioutil.TempDir("", "cc")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	srcPath := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(srcPath, []byte(src), 0644); err != nil {
+		t.Fatalf("unable to write source file; %+v", err)
+	}
+	file, err := ParseFile(srcPath)
+	if err != nil {
+		file.Close()
+		t.Fatalf("unable to parse %q; %+v", name, err)
+	}
+	return file
+}
+
+func TestLinkage(t *testing.T) {
+	const src = `
+int global;
+static int internal;
+extern int declared;
+_Thread_local int tls;
+static inline int add(int a, int b) { return a + b; }
+__attribute__((visibility("hidden"))) void hidden(void) {}
+void f(void) {
+	static int counter;
+	register int r;
+	(void)counter;
+	(void)r;
+}
+`
+	file := parseSource(t, "linkage.c", src)
+	defer file.Close()
+	golden := []struct {
+		name         string
+		linkage      Linkage
+		storageClass StorageClass
+		threadLocal  bool
+		inline       bool
+	}{
+		{name: "global", linkage: LinkageExternal, storageClass: StorageNone},
+		{name: "internal", linkage: LinkageInternal, storageClass: StorageStatic},
+		{name: "declared", linkage: LinkageExternal, storageClass: StorageExtern},
+		{name: "tls", linkage: LinkageExternal, storageClass: StorageNone, threadLocal: true},
+		{name: "add", linkage: LinkageInternal, storageClass: StorageStatic, inline: true},
+		{name: "hidden", linkage: LinkageExternal, storageClass: StorageNone},
+		{name: "counter", linkage: LinkageNone, storageClass: StorageStatic},
+		{name: "r", linkage: LinkageNone, storageClass: StorageRegister},
+	}
+	nodes := make(map[string]*Node)
+	Walk(file.Root, func(n *Node) {
+		switch n.Body.Kind() {
+		case clang.Cursor_VarDecl, clang.Cursor_FunctionDecl:
+			nodes[n.Body.Spelling()] = n
+		}
+	})
+	for _, g := range golden {
+		n, ok := nodes[g.name]
+		if !ok {
+			t.Errorf("%q: unable to locate declaration", g.name)
+			continue
+		}
+		if got := n.Linkage(); got != g.linkage {
+			t.Errorf("%q: linkage mismatch; expected %v, got %v", g.name, g.linkage, got)
+		}
+		if got := n.StorageClass(); got != g.storageClass {
+			t.Errorf("%q: storage class mismatch; expected %v, got %v", g.name, g.storageClass, got)
+		}
+		if got := n.IsThreadLocal(); got != g.threadLocal {
+			t.Errorf("%q: thread-local mismatch; expected %v, got %v", g.name, g.threadLocal, got)
+		}
+		if got := n.IsInline(); got != g.inline {
+			t.Errorf("%q: inline mismatch; expected %v, got %v", g.name, g.inline, got)
+		}
+	}
+	if got := nodes["hidden"].Visibility(); got != VisibilityHidden {
+		t.Errorf("%q: visibility mismatch; expected %v, got %v", "hidden", VisibilityHidden, got)
+	}
+}
+
+func TestExternalSymbols(t *testing.T) {
+	const src = `
+extern int x;
+int x = 1;
+extern int y;
+static int z;
+int f(void);
+int f(void) { return x + y + z; }
+__attribute__((visibility("hidden"))) void g(void) {}
+`
+	file := parseSource(t, "syms.c", src)
+	defer file.Close()
+	golden := []struct {
+		name    string
+		defined bool
+	}{
+		{name: "f", defined: true},
+		{name: "x", defined: true},
+		{name: "y", defined: false},
+	}
+	syms := ExternalSymbols(file)
+	if len(syms) != len(golden) {
+		var names []string
+		for _, sym := range syms {
+			names = append(names, sym.Name)
+		}
+		t.Fatalf("number of symbols mismatch; expected %d, got %d (%s)", len(golden), len(syms), strings.Join(names, ", "))
+	}
+	for i, g := range golden {
+		sym := syms[i]
+		if sym.Name != g.name {
+			t.Errorf("symbol %d: name mismatch; expected %q, got %q", i, g.name, sym.Name)
+		}
+		if sym.Defined != g.defined {
+			t.Errorf("%q: defined mismatch; expected %v, got %v", g.name, g.defined, sym.Defined)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	file := parseSource(t, "tokens.c", "int x = 1 + 2;\n")
+	defer file.Close()
+	decl := file.Root.Children[0]
+	// The token following the source range is not included.
+	want := "int x = 1 + 2"
+	if got := strings.Join(decl.Tokens(), " "); got != want {
+		t.Errorf("tokens mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestExternalSymbolsTentative(t *testing.T) {
+	a := parseSource(t, "a.c", "int x;\n")
+	defer a.Close()
+	b := parseSource(t, "b.c", "extern int x;\nint f(void) { return x; }\n")
+	defer b.Close()
+	golden := []struct {
+		files   []*File
+		defined bool
+	}{
+		{files: []*File{a}, defined: true},
+		{files: []*File{b}, defined: false},
+		{files: []*File{b, a}, defined: true},
+	}
+	for i, g := range golden {
+		for _, sym := range ExternalSymbols(g.files...) {
+			if sym.Name != "x" {
+				continue
+			}
+			if sym.Defined != g.defined {
+				t.Errorf("case %d: defined mismatch; expected %v, got %v", i, g.defined, sym.Defined)
+			}
+			if g.defined && !strings.HasSuffix(sym.Decl.Loc.File, "a.c") {
+				t.Errorf("case %d: declaration mismatch; expected tentative definition of a.c, got %v", i, sym.Decl.Loc)
+			}
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Package metrics computes code metrics of C and C++ functions.
+//
+// The following metrics are computed per function:
+//
+//   - cyclomatic complexity (McCabe), the number of decision points plus one;
+//   - nesting depth, the maximum depth of nested control structures;
+//   - statement count;
+//   - parameter count;
+//   - Halstead measures, based on the operator and operand tokens;
+//   - fan-in and fan-out, the number of distinct callers and callees.
+//
+// Per-file metrics aggregate the metrics of the functions defined in each
+// file.
+package metrics
+
+import (
+	"math"
+	"sort"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/callgraph"
+)
+
+// Report is a code metrics report.
+type Report struct {
+	// Function metrics, sorted by location.
+	Funcs []*FuncMetrics `json:"funcs"`
+	// File metrics, sorted by path.
+	Files []*FileMetrics `json:"files"`
+}
+
+// FuncMetrics are the metrics of a function.
+type FuncMetrics struct {
+	// Function name.
+	Name string `json:"name"`
+	// Source location of function definition.
+	Loc cc.Location `json:"-"`
+	// Source file of function definition.
+	File string `json:"file"`
+	// Line of function definition.
+	Line uint32 `json:"line"`
+	// Cyclomatic complexity.
+	Complexity int `json:"complexity"`
+	// Maximum nesting depth of control structures.
+	Nesting int `json:"nesting"`
+	// Number of statements.
+	Statements int `json:"statements"`
+	// Number of parameters.
+	Params int `json:"params"`
+	// Number of distinct callers.
+	FanIn int `json:"fan_in"`
+	// Number of distinct callees.
+	FanOut int `json:"fan_out"`
+	// Halstead measures.
+	Halstead Halstead `json:"halstead"`
+}
+
+// Halstead are the Halstead measures of a function.
+type Halstead struct {
+	// Number of distinct operators (n1).
+	DistinctOperators int `json:"distinct_operators"`
+	// Number of distinct operands (n2).
+	DistinctOperands int `json:"distinct_operands"`
+	// Total number of operators (N1).
+	Operators int `json:"operators"`
+	// Total number of operands (N2).
+	Operands int `json:"operands"`
+	// Program vocabulary (n = n1 + n2).
+	Vocabulary int `json:"vocabulary"`
+	// Program length (N = N1 + N2).
+	Length int `json:"length"`
+	// Volume (V = N * log2(n)).
+	Volume float64 `json:"volume"`
+	// Difficulty (D = n1/2 * N2/n2).
+	Difficulty float64 `json:"difficulty"`
+	// Effort (E = D * V).
+	Effort float64 `json:"effort"`
+}
+
+// FileMetrics are the aggregated metrics of the functions of a file.
+type FileMetrics struct {
+	// Source file.
+	Path string `json:"path"`
+	// Number of functions.
+	Funcs int `json:"funcs"`
+	// Sum of the cyclomatic complexity of functions.
+	Complexity int `json:"complexity"`
+	// Maximum cyclomatic complexity of functions.
+	MaxComplexity int `json:"max_complexity"`
+	// Average cyclomatic complexity of functions.
+	AvgComplexity float64 `json:"avg_complexity"`
+	// Maximum nesting depth of functions.
+	MaxNesting int `json:"max_nesting"`
+	// Number of statements of functions.
+	Statements int `json:"statements"`
+	// Sum of the Halstead volume of functions.
+	Volume float64 `json:"volume"`
+}
+
+// Compute computes the code metrics of the functions defined in the given
+// translation units. Functions defined in system headers are ignored, and
+// functions defined in headers included by several translation units are only
+// reported once.
+func Compute(files ...*cc.File) *Report {
+	cg := callgraph.New(files...)
+	r := &Report{}
+	for _, f := range cg.Sorted() {
+		if f.Def == nil || f.Def.Body.Location().IsInSystemHeader() {
+			continue
+		}
+		r.Funcs = append(r.Funcs, funcMetrics(f))
+	}
+	sort.SliceStable(r.Funcs, func(i, j int) bool {
+		a, b := r.Funcs[i], r.Funcs[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	fileFromPath := make(map[string]*FileMetrics)
+	for _, fm := range r.Funcs {
+		file, ok := fileFromPath[fm.File]
+		if !ok {
+			file = &FileMetrics{Path: fm.File}
+			fileFromPath[fm.File] = file
+			r.Files = append(r.Files, file)
+		}
+		file.Funcs++
+		file.Complexity += fm.Complexity
+		if fm.Complexity > file.MaxComplexity {
+			file.MaxComplexity = fm.Complexity
+		}
+		if fm.Nesting > file.MaxNesting {
+			file.MaxNesting = fm.Nesting
+		}
+		file.Statements += fm.Statements
+		file.Volume += fm.Halstead.Volume
+	}
+	for _, file := range r.Files {
+		file.AvgComplexity = float64(file.Complexity) / float64(file.Funcs)
+	}
+	return r
+}
+
+// funcMetrics returns the metrics of the given function.
+func funcMetrics(f *callgraph.Func) *FuncMetrics {
+	fm := &FuncMetrics{
+		Name:       f.Name,
+		Loc:        f.Def.Loc,
+		File:       f.Def.Loc.File,
+		Line:       f.Def.Loc.Line,
+		Complexity: 1,
+		Params:     int(f.Def.Body.NumArguments()),
+	}
+	if fm.Params < 0 {
+		fm.Params = 0
+	}
+	var body *cc.Node
+	for _, child := range f.Def.Children {
+		if child.Body.Kind() == clang.Cursor_CompoundStmt {
+			body = child
+		}
+	}
+	if body != nil {
+		visit(fm, body, nil, 0)
+		fm.Halstead = halstead(body)
+	}
+	callers := make(map[*callgraph.Func]bool)
+	for _, call := range f.Callers {
+		callers[call.Caller] = true
+	}
+	callees := make(map[*callgraph.Func]bool)
+	for _, call := range f.Callees {
+		callees[call.Callee] = true
+	}
+	fm.FanIn = len(callers)
+	fm.FanOut = len(callees)
+	return fm
+}
+
+// visit accumulates the complexity, nesting depth and statement count of the
+// given node with the given parent node and control structure nesting depth.
+func visit(fm *FuncMetrics, n, parent *cc.Node, depth int) {
+	kind := n.Body.Kind()
+	// Count statements; expressions are statements when in the position of a
+	// statement (e.g. direct children of compound statements).
+	switch {
+	case kind == clang.Cursor_CompoundStmt:
+	case kind.IsStatement():
+		fm.Statements++
+	case isSubStmt(n, parent):
+		fm.Statements++
+	}
+	// Count decision points and nesting.
+	switch kind {
+	case clang.Cursor_IfStmt:
+		fm.Complexity++
+		// The if statement of an else-if chain is at the nesting depth of
+		// the first if statement of the chain.
+		if !isElse(n, parent) {
+			depth++
+		}
+	case clang.Cursor_ForStmt, clang.Cursor_WhileStmt, clang.Cursor_DoStmt:
+		fm.Complexity++
+		depth++
+	case clang.Cursor_SwitchStmt:
+		depth++
+	case clang.Cursor_CaseStmt, clang.Cursor_ConditionalOperator:
+		fm.Complexity++
+	case clang.Cursor_BinaryOperator:
+		switch n.Operator() {
+		case "&&", "||":
+			fm.Complexity++
+		}
+	}
+	if depth > fm.Nesting {
+		fm.Nesting = depth
+	}
+	for _, child := range n.Children {
+		visit(fm, child, n, depth)
+	}
+}
+
+// isSubStmt reports whether the given node is in the position of a statement
+// within the given parent node (e.g. "x++" in "case 1: x++;" and in
+// "while (x) x++;").
+func isSubStmt(n, parent *cc.Node) bool {
+	if parent == nil {
+		return false
+	}
+	first := parent.Children[0] == n
+	last := parent.Children[len(parent.Children)-1] == n
+	switch parent.Body.Kind() {
+	case clang.Cursor_CompoundStmt:
+		return true
+	case clang.Cursor_CaseStmt, clang.Cursor_DefaultStmt, clang.Cursor_LabelStmt:
+		// Sub-statement following the case value.
+		return last
+	case clang.Cursor_IfStmt:
+		// Then and else branches following the condition.
+		return !first
+	case clang.Cursor_ForStmt, clang.Cursor_WhileStmt:
+		// Body following the condition (and the init and increment
+		// expressions of for loops).
+		return last
+	case clang.Cursor_DoStmt:
+		// Body preceding the condition.
+		return first
+	}
+	return false
+}
+
+// isElse reports whether the given node is the else branch of the given
+// parent if statement.
+func isElse(n, parent *cc.Node) bool {
+	if parent == nil || parent.Body.Kind() != clang.Cursor_IfStmt {
+		return false
+	}
+	// Condition, then branch and else branch.
+	return len(parent.Children) == 3 && parent.Children[2] == n
+}
+
+// halstead returns the Halstead measures of the given function body.
+// Identifiers and literals are operands, and keywords and punctuation are
+// operators. Closing brackets are not counted, as they form a single operator
+// with their opening bracket.
+func halstead(body *cc.Node) Halstead {
+	tu := body.Body.TranslationUnit()
+	operators := make(map[string]bool)
+	operands := make(map[string]bool)
+	var h Halstead
+	r := body.Body.Extent()
+	toks := tu.Tokenize(r)
+	defer tu.DisposeTokens(toks)
+	_, _, _, end := r.End().FileLocation()
+	for _, tok := range toks {
+		// Clang 3.9 includes the token following the source range.
+		if _, _, _, off := tu.TokenLocation(tok).FileLocation(); off >= end {
+			break
+		}
+		s := tu.TokenSpelling(tok)
+		switch tok.Kind() {
+		case clang.Token_Identifier, clang.Token_Literal:
+			operands[s] = true
+			h.Operands++
+		case clang.Token_Keyword, clang.Token_Punctuation:
+			switch s {
+			case ")", "]", "}":
+				continue
+			}
+			operators[s] = true
+			h.Operators++
+		}
+	}
+	h.DistinctOperators = len(operators)
+	h.DistinctOperands = len(operands)
+	h.Vocabulary = h.DistinctOperators + h.DistinctOperands
+	h.Length = h.Operators + h.Operands
+	if h.Vocabulary > 0 {
+		h.Volume = float64(h.Length) * math.Log2(float64(h.Vocabulary))
+	}
+	if h.DistinctOperands > 0 {
+		h.Difficulty = float64(h.DistinctOperators) / 2 * float64(h.Operands) / float64(h.DistinctOperands)
+	}
+	h.Effort = h.Difficulty * h.Volume
+	return h
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+func TestCompute(t *testing.T) {
+	const src = `
+int f(int a, int b) { return a + b; }
+int g(int a, int b) {
+	if (a && b) {
+		for (;;) {
+			if (a)
+				break;
+		}
+	}
+	return a ? f(a, b) : 0;
+}
+void h(void) {}
+`
+	file, err := cc.ParseSource("metrics.c", src)
+	if err != nil {
+		t.Fatalf("unable to parse source; %+v", err)
+	}
+	defer file.Close()
+	golden := []struct {
+		name       string
+		complexity int
+		nesting    int
+		params     int
+		fanIn      int
+		fanOut     int
+	}{
+		{name: "f", complexity: 1, params: 2, fanIn: 1},
+		{name: "g", complexity: 6, nesting: 3, params: 2, fanOut: 1},
+		{name: "h", complexity: 1},
+	}
+	r := Compute(file)
+	if len(r.Funcs) != len(golden) {
+		t.Fatalf("number of functions mismatch; expected %d, got %d", len(golden), len(r.Funcs))
+	}
+	for i, want := range golden {
+		got := r.Funcs[i]
+		if got.Name != want.name {
+			t.Errorf("function %d: name mismatch; expected %q, got %q", i, want.name, got.Name)
+			continue
+		}
+		if got.Complexity != want.complexity {
+			t.Errorf("%q: complexity mismatch; expected %d, got %d", want.name, want.complexity, got.Complexity)
+		}
+		if got.Nesting != want.nesting {
+			t.Errorf("%q: nesting mismatch; expected %d, got %d", want.name, want.nesting, got.Nesting)
+		}
+		if got.Params != want.params {
+			t.Errorf("%q: parameters mismatch; expected %d, got %d", want.name, want.params, got.Params)
+		}
+		if got.FanIn != want.fanIn || got.FanOut != want.fanOut {
+			t.Errorf("%q: fan-in/fan-out mismatch; expected %d/%d, got %d/%d", want.name, want.fanIn, want.fanOut, got.FanIn, got.FanOut)
+		}
+	}
+	// Operators of f: { return + ;
+	// Operands of f: a b
+	//
+	// The keyword int following the body of f is not counted.
+	h := r.Funcs[0].Halstead
+	if h.Operators != 4 || h.DistinctOperators != 4 || h.Operands != 2 || h.DistinctOperands != 2 {
+		t.Errorf("Halstead measures of f mismatch; expected 4/4 operators and 2/2 operands, got %d/%d operators and %d/%d operands", h.Operators, h.DistinctOperators, h.Operands, h.DistinctOperands)
+	}
+	if len(r.Files) != 1 || r.Files[0].Funcs != 3 || r.Files[0].MaxComplexity != 6 {
+		t.Errorf("file metrics mismatch; got %+v", r.Files)
+	}
+}
+
+func TestNestingAndStatements(t *testing.T) {
+	const src = `
+int e(int a) {
+	if (a == 1)
+		return 1;
+	else if (a == 2)
+		return 2;
+	else if (a == 3)
+		return 3;
+	return 0;
+}
+int s(int a) {
+	switch (a) {
+	case 1:
+		a++;
+		break;
+	default:
+		a--;
+	}
+	while (a)
+		a--;
+	return a;
+}
+`
+	file, err := cc.ParseSource("metrics.c", src)
+	if err != nil {
+		t.Fatalf("unable to parse source; %+v", err)
+	}
+	defer file.Close()
+	golden := []struct {
+		name       string
+		complexity int
+		nesting    int
+		statements int
+	}{
+		// An else-if chain is a single level of nesting.
+		{name: "e", complexity: 4, nesting: 1, statements: 7},
+		// Expressions following case labels and in loop bodies without
+		// braces are statements.
+		{name: "s", complexity: 3, nesting: 1, statements: 9},
+	}
+	r := Compute(file)
+	if len(r.Funcs) != len(golden) {
+		t.Fatalf("number of functions mismatch; expected %d, got %d", len(golden), len(r.Funcs))
+	}
+	for i, want := range golden {
+		got := r.Funcs[i]
+		if got.Name != want.name {
+			t.Errorf("function %d: name mismatch; expected %q, got %q", i, want.name, got.Name)
+			continue
+		}
+		if got.Complexity != want.complexity {
+			t.Errorf("%q: complexity mismatch; expected %d, got %d", want.name, want.complexity, got.Complexity)
+		}
+		if got.Nesting != want.nesting {
+			t.Errorf("%q: nesting mismatch; expected %d, got %d", want.name, want.nesting, got.Nesting)
+		}
+		if got.Statements != want.statements {
+			t.Errorf("%q: statements mismatch; expected %d, got %d", want.name, want.statements, got.Statements)
+		}
+	}
+}
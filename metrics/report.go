@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// WriteJSON writes the report in JSON format to w.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(r); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// WriteFuncsCSV writes the function metrics of the report in CSV format to w.
+func (r *Report) WriteFuncsCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"file", "line", "name", "complexity", "nesting", "statements", "params", "fan_in", "fan_out", "halstead_volume", "halstead_difficulty", "halstead_effort"}
+	if err := cw.Write(header); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, fm := range r.Funcs {
+		record := []string{
+			fm.File,
+			strconv.FormatUint(uint64(fm.Line), 10),
+			fm.Name,
+			strconv.Itoa(fm.Complexity),
+			strconv.Itoa(fm.Nesting),
+			strconv.Itoa(fm.Statements),
+			strconv.Itoa(fm.Params),
+			strconv.Itoa(fm.FanIn),
+			strconv.Itoa(fm.FanOut),
+			formatFloat(fm.Halstead.Volume),
+			formatFloat(fm.Halstead.Difficulty),
+			formatFloat(fm.Halstead.Effort),
+		}
+		if err := cw.Write(record); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	cw.Flush()
+	return errors.WithStack(cw.Error())
+}
+
+// WriteFilesCSV writes the file metrics of the report in CSV format to w.
+func (r *Report) WriteFilesCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"path", "funcs", "complexity", "max_complexity", "avg_complexity", "max_nesting", "statements", "halstead_volume"}
+	if err := cw.Write(header); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, file := range r.Files {
+		record := []string{
+			file.Path,
+			strconv.Itoa(file.Funcs),
+			strconv.Itoa(file.Complexity),
+			strconv.Itoa(file.MaxComplexity),
+			formatFloat(file.AvgComplexity),
+			strconv.Itoa(file.MaxNesting),
+			strconv.Itoa(file.Statements),
+			formatFloat(file.Volume),
+		}
+		if err := cw.Write(record); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	cw.Flush()
+	return errors.WithStack(cw.Error())
+}
+
+// formatFloat returns a string representation of the given floating-point
+// value with two decimals.
+func formatFloat(x float64) string {
+	return strconv.FormatFloat(x, 'f', 2, 64)
+}
+
+// Thresholds are the maximum allowed metrics of functions. Zero values
+// disable the corresponding check.
+type Thresholds struct {
+	// Maximum cyclomatic complexity.
+	Complexity int
+	// Maximum nesting depth.
+	Nesting int
+	// Maximum number of statements.
+	Statements int
+	// Maximum number of parameters.
+	Params int
+}
+
+// Violation is a function metric exceeding its threshold.
+type Violation struct {
+	// Function metrics.
+	Func *FuncMetrics
+	// Metric name (e.g. "complexity").
+	Metric string
+	// Metric value.
+	Value int
+	// Threshold of metric.
+	Threshold int
+}
+
+// String returns a string representation of the threshold violation.
+func (v *Violation) String() string {
+	return fmt.Sprintf("%s: %s has %s %d (threshold %d)", v.Func.Loc, v.Func.Name, v.Metric, v.Value, v.Threshold)
+}
+
+// Check returns the function metrics of the report exceeding the given
+// thresholds.
+func (r *Report) Check(t *Thresholds) []*Violation {
+	var violations []*Violation
+	check := func(fm *FuncMetrics, metric string, value, threshold int) {
+		if threshold > 0 && value > threshold {
+			violations = append(violations, &Violation{Func: fm, Metric: metric, Value: value, Threshold: threshold})
+		}
+	}
+	for _, fm := range r.Funcs {
+		check(fm, "complexity", fm.Complexity, t.Complexity)
+		check(fm, "nesting", fm.Nesting, t.Nesting)
+		check(fm, "statements", fm.Statements, t.Statements)
+		check(fm, "params", fm.Params, t.Params)
+	}
+	return violations
+}
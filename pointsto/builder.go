@@ -0,0 +1,447 @@
+package pointsto
+
+import (
+	"fmt"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+)
+
+// builder generates the constraints of a program.
+type builder struct {
+	// Result of the analysis.
+	r *Result
+	// Address nodes, by object node.
+	addrs map[int]int
+	// Function definition being visited; or nil at file scope.
+	fn *cc.Node
+}
+
+// decl generates the constraints of the given top-level declaration.
+func (b *builder) decl(n *cc.Node) {
+	switch n.Body.Kind() {
+	case clang.Cursor_FunctionDecl:
+		if !n.Body.IsCursorDefinition() {
+			return
+		}
+		usr := n.Body.USR()
+		b.funcObj(n.Body)
+		i := 0
+		for _, child := range n.Children {
+			if child.Body.Kind() == clang.Cursor_ParmDecl {
+				b.copy(b.varObj(child.Body), b.r.lookup(paramKey(usr, i)))
+				i++
+			}
+		}
+		b.fn = n
+		for _, child := range n.Children {
+			if child.Body.Kind() == clang.Cursor_CompoundStmt {
+				b.stmt(child)
+			}
+		}
+		b.fn = nil
+	case clang.Cursor_VarDecl:
+		b.varDecl(n)
+	case clang.Cursor_UnexposedDecl, clang.Cursor_Namespace:
+		// Linkage specifications (e.g. extern "C") and namespaces.
+		for _, child := range n.Children {
+			b.decl(child)
+		}
+	}
+}
+
+// stmt generates the constraints of the given statement.
+func (b *builder) stmt(n *cc.Node) {
+	kind := n.Body.Kind()
+	switch {
+	case kind == clang.Cursor_VarDecl:
+		b.varDecl(n)
+	case kind == clang.Cursor_FunctionDecl:
+		// Block scope function declaration.
+	case kind == clang.Cursor_ReturnStmt:
+		if b.fn != nil && len(n.Children) == 1 {
+			b.copy(b.r.lookup(retKey(b.fn.Body.USR())), b.value(n.Children[0]))
+		}
+	case kind.IsExpression():
+		b.value(n)
+	default:
+		for _, child := range n.Children {
+			b.stmt(child)
+		}
+	}
+}
+
+// varDecl generates the constraints of the initializer of the given variable
+// declaration.
+func (b *builder) varDecl(n *cc.Node) {
+	obj := b.varObj(n.Body)
+	if len(n.Children) == 0 {
+		return
+	}
+	init := n.Children[len(n.Children)-1]
+	if init.Body.Kind().IsExpression() {
+		b.initObj(obj, init)
+	}
+}
+
+// initObj generates the constraints of initializing the given object with the
+// given initializer.
+func (b *builder) initObj(obj int, init *cc.Node) {
+	if init.Body.Kind() != clang.Cursor_InitListExpr {
+		b.copy(obj, b.value(init))
+		return
+	}
+	t := init.Body.Type().CanonicalType()
+	if t.Kind() != clang.Type_Record {
+		// Array elements are represented by the array object.
+		for _, child := range init.Children {
+			b.initObj(obj, child)
+		}
+		return
+	}
+	fields := recordFields(t)
+	pos := 0
+	designated := ""
+	for _, child := range init.Children {
+		if child.Body.Kind() == clang.Cursor_MemberRef {
+			// Field designator (e.g. ".read = f").
+			designated = child.Body.Spelling()
+			continue
+		}
+		name := designated
+		if len(name) > 0 {
+			for i, field := range fields {
+				if field == name {
+					pos = i
+				}
+			}
+		} else if pos < len(fields) {
+			name = fields[pos]
+		}
+		pos++
+		designated = ""
+		b.initObj(b.r.field(obj, name), child)
+	}
+}
+
+// value generates the constraints of the given expression, returning the node
+// of its value; or -1 if the expression does not evaluate to a pointer into the
+// objects of the program.
+func (b *builder) value(n *cc.Node) int {
+	if id, ok := b.r.values[n]; ok {
+		return id
+	}
+	id := b.eval(n)
+	b.r.values[n] = id
+	return id
+}
+
+// eval generates the constraints of the given expression, returning the node
+// of its value; or -1 if not present.
+func (b *builder) eval(n *cc.Node) int {
+	switch n.Body.Kind() {
+	case clang.Cursor_UnexposedExpr, clang.Cursor_ParenExpr:
+		if len(n.Children) == 1 {
+			return b.value(n.Children[0])
+		}
+	case clang.Cursor_CStyleCastExpr:
+		if len(n.Children) > 0 {
+			// The operand of a cast is its last child; preceded by type
+			// references.
+			return b.value(n.Children[len(n.Children)-1])
+		}
+	case clang.Cursor_DeclRefExpr:
+		ref := n.Body.Referenced()
+		switch ref.Kind() {
+		case clang.Cursor_VarDecl, clang.Cursor_ParmDecl:
+			if isArray(ref.Type()) {
+				// Arrays decay to pointers to their first element.
+				return b.addr(b.varObj(ref))
+			}
+			return b.varObj(ref)
+		case clang.Cursor_FunctionDecl:
+			return b.addr(b.funcObj(ref))
+		}
+		return -1
+	case clang.Cursor_UnaryOperator:
+		if len(n.Children) != 1 {
+			break
+		}
+		operand := n.Children[0]
+		switch n.Operator() {
+		case "&":
+			return b.addrOf(operand)
+		case "*":
+			if isFuncPtr(operand.Body.Type()) {
+				// Dereferencing a function pointer designates the function.
+				return b.value(operand)
+			}
+			return b.lvalue(n)
+		case "++", "--", "+":
+			return b.value(operand)
+		}
+	case clang.Cursor_ArraySubscriptExpr, clang.Cursor_MemberRefExpr:
+		return b.lvalue(n)
+	case clang.Cursor_BinaryOperator:
+		if len(n.Children) != 2 {
+			break
+		}
+		lhs, rhs := n.Children[0], n.Children[1]
+		switch n.Operator() {
+		case "=":
+			v := b.value(rhs)
+			b.assign(lhs, v)
+			return v
+		case ",":
+			b.value(lhs)
+			return b.value(rhs)
+		case "+", "-":
+			// Pointer arithmetic.
+			return b.union(b.value(lhs), b.value(rhs))
+		}
+	case clang.Cursor_CompoundAssignOperator:
+		if len(n.Children) == 2 {
+			b.value(n.Children[1])
+			return b.value(n.Children[0])
+		}
+	case clang.Cursor_ConditionalOperator:
+		if len(n.Children) == 3 {
+			b.value(n.Children[0])
+			return b.union(b.value(n.Children[1]), b.value(n.Children[2]))
+		}
+	case clang.Cursor_CallExpr:
+		return b.call(n)
+	case clang.Cursor_StmtExpr:
+		for _, child := range n.Children {
+			b.stmt(child)
+		}
+		return -1
+	}
+	// Generate the constraints of subexpressions (e.g. assignments within
+	// comparisons).
+	for _, child := range n.Children {
+		if child.Body.Kind().IsExpression() {
+			b.value(child)
+		}
+	}
+	return -1
+}
+
+// lvalue returns the node of the value of the given lvalue expression.
+func (b *builder) lvalue(n *cc.Node) int {
+	addr := b.addrOf(n)
+	if isArray(n.Body.Type()) {
+		// Arrays decay to pointers to their first element.
+		return addr
+	}
+	return b.load(addr, "")
+}
+
+// addrOf generates the constraints of the given lvalue expression, returning
+// the node of its address; or -1 if not present.
+func (b *builder) addrOf(n *cc.Node) int {
+	switch n.Body.Kind() {
+	case clang.Cursor_UnexposedExpr, clang.Cursor_ParenExpr:
+		if len(n.Children) == 1 {
+			return b.addrOf(n.Children[0])
+		}
+	case clang.Cursor_DeclRefExpr:
+		ref := n.Body.Referenced()
+		switch ref.Kind() {
+		case clang.Cursor_VarDecl, clang.Cursor_ParmDecl:
+			return b.addr(b.varObj(ref))
+		case clang.Cursor_FunctionDecl:
+			return b.addr(b.funcObj(ref))
+		}
+	case clang.Cursor_UnaryOperator:
+		if len(n.Children) == 1 && n.Operator() == "*" {
+			return b.value(n.Children[0])
+		}
+	case clang.Cursor_ArraySubscriptExpr:
+		if len(n.Children) != 2 {
+			break
+		}
+		base, index := n.Children[0], n.Children[1]
+		if !isPointerOrArray(base.Body.Type()) {
+			// Reversed subscript (e.g. "i[buf]").
+			base, index = index, base
+		}
+		b.value(index)
+		return b.value(base)
+	case clang.Cursor_MemberRefExpr:
+		if len(n.Children) == 0 {
+			break
+		}
+		base := n.Children[0]
+		field := n.Body.Spelling()
+		if base.Body.Type().CanonicalType().Kind() == clang.Type_Pointer {
+			// p->field
+			return b.gep(b.value(base), field)
+		}
+		// s.field
+		return b.gep(b.addrOf(base), field)
+	}
+	b.value(n)
+	return -1
+}
+
+// assign generates the constraints of assigning the given value to the given
+// lvalue expression.
+func (b *builder) assign(lhs *cc.Node, v int) {
+	b.store(b.addrOf(lhs), "", v)
+}
+
+// call generates the constraints of the given call expression, returning the
+// node of its return value.
+func (b *builder) call(n *cc.Node) int {
+	if len(n.Children) == 0 {
+		return -1
+	}
+	var args []int
+	for _, arg := range n.Children[1:] {
+		args = append(args, b.value(arg))
+	}
+	ret := b.r.newNode("")
+	callee := n.Body.Referenced()
+	if callee.Kind() != clang.Cursor_FunctionDecl {
+		// Call through a function pointer.
+		fp := b.value(n.Children[0])
+		if fp >= 0 {
+			c := &callCons{site: n, args: args, ret: ret, targets: make(map[int]bool)}
+			b.r.nodes[fp].calls = append(b.r.nodes[fp].calls, c)
+			b.r.calls[n] = c
+		}
+		return ret
+	}
+	name := callee.Spelling()
+	if Allocators[name] {
+		b.r.nodes[ret].pts[b.heapObj(n, name)] = true
+		if (name == "realloc" || name == "reallocarray") && len(args) > 0 {
+			// The reallocated object may be returned.
+			b.copy(ret, args[0])
+		}
+	}
+	usr := callee.USR()
+	for i, arg := range args {
+		b.copy(b.r.lookup(paramKey(usr, i)), arg)
+	}
+	b.copy(ret, b.r.lookup(retKey(usr)))
+	return ret
+}
+
+// varObj returns the object node of the given variable or parameter.
+func (b *builder) varObj(decl clang.Cursor) int {
+	return b.r.object(varKey(decl), &Object{Kind: ObjectVar, Name: decl.Spelling(), Decl: decl})
+}
+
+// funcObj returns the object node of the given function.
+func (b *builder) funcObj(decl clang.Cursor) int {
+	return b.r.object(funcKey(decl), &Object{Kind: ObjectFunc, Name: decl.Spelling(), Decl: decl})
+}
+
+// heapObj returns the object node of the heap allocation of the given call to
+// an allocation function.
+func (b *builder) heapObj(site *cc.Node, name string) int {
+	return b.r.object(fmt.Sprintf("heap %v", site.Loc), &Object{Kind: ObjectHeap, Name: fmt.Sprintf("%s@%v", name, site.Loc), Site: site})
+}
+
+// addr returns a node pointing to the given object.
+func (b *builder) addr(obj int) int {
+	if id, ok := b.addrs[obj]; ok {
+		return id
+	}
+	id := b.r.newNode("")
+	b.r.nodes[id].pts[obj] = true
+	b.addrs[obj] = id
+	return id
+}
+
+// union returns a node holding the union of the values of the given nodes.
+func (b *builder) union(x, y int) int {
+	switch {
+	case x < 0:
+		return y
+	case y < 0:
+		return x
+	}
+	id := b.r.newNode("")
+	b.copy(id, x)
+	b.copy(id, y)
+	return id
+}
+
+// copy adds the constraint dst ⊇ src.
+func (b *builder) copy(dst, src int) {
+	if dst < 0 || src < 0 || dst == src {
+		return
+	}
+	b.r.nodes[src].succs[dst] = true
+}
+
+// load adds the constraint t ⊇ *src.field, returning t.
+func (b *builder) load(src int, field string) int {
+	if src < 0 {
+		return -1
+	}
+	t := b.r.newNode("")
+	b.r.nodes[src].loads = append(b.r.nodes[src].loads, &fieldCons{other: t, field: field})
+	return t
+}
+
+// store adds the constraint *dst.field ⊇ src.
+func (b *builder) store(dst int, field string, src int) {
+	if dst < 0 || src < 0 {
+		return
+	}
+	b.r.nodes[dst].stores = append(b.r.nodes[dst].stores, &fieldCons{other: src, field: field})
+}
+
+// gep adds the constraint t ⊇ &src->field, returning t.
+func (b *builder) gep(src int, field string) int {
+	if src < 0 {
+		return -1
+	}
+	t := b.r.newNode("")
+	b.r.nodes[src].geps = append(b.r.nodes[src].geps, &fieldCons{other: t, field: field})
+	return t
+}
+
+// recordFields returns the field names of the given record type, in
+// declaration order.
+func recordFields(t clang.Type) []string {
+	var fields []string
+	t.Declaration().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		if cursor.Kind() == clang.Cursor_FieldDecl {
+			fields = append(fields, cursor.Spelling())
+		}
+		return clang.ChildVisit_Continue
+	})
+	return fields
+}
+
+// isArray reports whether the given type is an array type.
+func isArray(t clang.Type) bool {
+	switch t.CanonicalType().Kind() {
+	case clang.Type_ConstantArray, clang.Type_IncompleteArray, clang.Type_VariableArray:
+		return true
+	}
+	return false
+}
+
+// isPointerOrArray reports whether the given type is a pointer or array type.
+func isPointerOrArray(t clang.Type) bool {
+	return t.CanonicalType().Kind() == clang.Type_Pointer || isArray(t)
+}
+
+// isFuncPtr reports whether the given type is a function pointer type.
+func isFuncPtr(t clang.Type) bool {
+	t = t.CanonicalType()
+	if t.Kind() != clang.Type_Pointer {
+		return false
+	}
+	switch t.PointeeType().CanonicalType().Kind() {
+	case clang.Type_FunctionProto, clang.Type_FunctionNoProto:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,423 @@
+// Package pointsto implements an Andersen-style points-to analysis of C
+// programs.
+//
+// The analysis is flow-insensitive, context-insensitive and field-sensitive.
+// Abstract memory objects are variables, functions, heap allocations (one per
+// allocation site) and the fields of these objects. Arrays are not indexed;
+// all elements of an array are represented by the array object. Pointer
+// assignments of a whole program are translated into inclusion constraints
+// which are solved to a fixed point, resolving the targets of calls through
+// function pointers on the fly. Assignments of whole structures do not copy
+// the points-to sets of their fields.
+//
+// Calls to functions defined outside the analyzed program, other than
+// allocation functions, are assumed not to return pointers to objects of the
+// program.
+package pointsto
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/callgraph"
+)
+
+// Allocators holds the names of functions returning pointers to newly
+// allocated heap objects.
+var Allocators = map[string]bool{
+	"malloc":        true,
+	"calloc":        true,
+	"realloc":       true,
+	"reallocarray":  true,
+	"aligned_alloc": true,
+	"valloc":        true,
+	"memalign":      true,
+	"strdup":        true,
+	"strndup":       true,
+}
+
+// ObjectKind is the kind of an abstract memory object.
+type ObjectKind uint8
+
+// Object kinds.
+const (
+	// Variable.
+	ObjectVar ObjectKind = iota
+	// Function.
+	ObjectFunc
+	// Heap allocation.
+	ObjectHeap
+	// Field of an object.
+	ObjectField
+)
+
+// String returns the string representation of the object kind.
+func (kind ObjectKind) String() string {
+	switch kind {
+	case ObjectVar:
+		return "variable"
+	case ObjectFunc:
+		return "function"
+	case ObjectHeap:
+		return "heap"
+	case ObjectField:
+		return "field"
+	}
+	return fmt.Sprintf("ObjectKind(%d)", uint8(kind))
+}
+
+// Object is an abstract memory object.
+type Object struct {
+	// Object kind.
+	Kind ObjectKind
+	// Object name (e.g. "buf", "malloc@foo.c:12:7", "conf.ops").
+	Name string
+	// Declaration of variables and functions.
+	Decl clang.Cursor
+	// Allocation call of heap objects; or nil.
+	Site *cc.Node
+	// Parent object of fields; or nil.
+	Parent *Object
+}
+
+// String returns the name of the object.
+func (obj *Object) String() string {
+	return obj.Name
+}
+
+// Result is the result of a points-to analysis.
+type Result struct {
+	// Constraint graph nodes.
+	nodes []*node
+	// Node IDs, by key.
+	ids map[string]int
+	// Value nodes of expressions.
+	values map[*cc.Node]int
+	// Calls through function pointers, by call expression.
+	calls map[*cc.Node]*callCons
+}
+
+// node is a node of the constraint graph; a pointer-valued variable, object
+// contents or intermediate value.
+type node struct {
+	// Objects pointed to.
+	pts map[int]bool
+	// Nodes whose points-to sets include the points-to set of the node.
+	succs map[int]bool
+	// Load constraints; other ⊇ *n.field.
+	loads []*fieldCons
+	// Store constraints; *n.field ⊇ other.
+	stores []*fieldCons
+	// Field address constraints; other ⊇ &n->field.
+	geps []*fieldCons
+	// Calls through the node.
+	calls []*callCons
+	// Memory object whose contents the node represents; or nil.
+	obj *Object
+}
+
+// fieldCons is a complex constraint involving the objects pointed to by a
+// node.
+type fieldCons struct {
+	// Other node of the constraint.
+	other int
+	// Field name; or empty for the object itself.
+	field string
+}
+
+// callCons is a call through a function pointer.
+type callCons struct {
+	// Call expression.
+	site *cc.Node
+	// Argument value nodes; or -1 for non-pointer arguments.
+	args []int
+	// Return value node.
+	ret int
+	// Functions bound to the call, by object node.
+	targets map[int]bool
+}
+
+// maxFieldDepth is the maximum nesting depth of field objects; deeper fields
+// are represented by their parent object.
+const maxFieldDepth = 4
+
+// Analyze performs a points-to analysis of the program consisting of the given
+// translation units.
+func Analyze(files ...*cc.File) *Result {
+	r := &Result{
+		ids:    make(map[string]int),
+		values: make(map[*cc.Node]int),
+		calls:  make(map[*cc.Node]*callCons),
+	}
+	b := &builder{r: r, addrs: make(map[int]int)}
+	for _, file := range files {
+		for _, n := range file.Root.Children {
+			b.decl(n)
+		}
+	}
+	r.solve()
+	return r
+}
+
+// PointsTo returns the objects pointed to by the given expression, sorted by
+// name. Expressions of the analyzed program are looked up through implicit
+// casts and parentheses.
+func (r *Result) PointsTo(expr *cc.Node) []*Object {
+	for {
+		if id, ok := r.values[expr]; ok {
+			return r.objects(id)
+		}
+		switch expr.Body.Kind() {
+		case clang.Cursor_UnexposedExpr, clang.Cursor_ParenExpr:
+			if len(expr.Children) == 1 {
+				expr = expr.Children[0]
+				continue
+			}
+		}
+		return nil
+	}
+}
+
+// VarPointsTo returns the objects pointed to by the given variable or
+// parameter, sorted by name.
+func (r *Result) VarPointsTo(decl clang.Cursor) []*Object {
+	id, ok := r.ids[varKey(decl)]
+	if !ok {
+		return nil
+	}
+	return r.objects(id)
+}
+
+// MayAlias reports whether the given pointer expressions may point to the same
+// object.
+func (r *Result) MayAlias(x, y *cc.Node) bool {
+	objs := make(map[*Object]bool)
+	for _, obj := range r.PointsTo(x) {
+		objs[obj] = true
+	}
+	for _, obj := range r.PointsTo(y) {
+		if objs[obj] || (obj.Parent != nil && objs[obj.Parent]) {
+			return true
+		}
+		for parent := range objs {
+			if parent.Parent == obj {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Callees returns the functions called by the given call expression through a
+// function pointer, sorted by name.
+func (r *Result) Callees(site *cc.Node) []*Object {
+	c, ok := r.calls[site]
+	if !ok {
+		return nil
+	}
+	var fs []*Object
+	for id := range c.targets {
+		fs = append(fs, r.nodes[id].obj)
+	}
+	sortObjects(fs)
+	return fs
+}
+
+// ResolveCalls adds the resolved targets of calls through function pointers to
+// the given call graph. Resolved call sites are removed from the indirect call
+// sites of their callers.
+func (r *Result) ResolveCalls(g *callgraph.Graph) {
+	for _, f := range g.Sorted() {
+		var unresolved []*cc.Node
+		for _, site := range f.Indirect {
+			targets := r.Callees(site)
+			if len(targets) == 0 {
+				unresolved = append(unresolved, site)
+				continue
+			}
+			for _, target := range targets {
+				g.AddCall(f, g.Func(target.Decl), site)
+			}
+		}
+		f.Indirect = unresolved
+	}
+}
+
+// objects returns the objects pointed to by the given node, sorted by name.
+func (r *Result) objects(id int) []*Object {
+	if id < 0 {
+		return nil
+	}
+	var objs []*Object
+	for obj := range r.nodes[id].pts {
+		objs = append(objs, r.nodes[obj].obj)
+	}
+	sortObjects(objs)
+	return objs
+}
+
+// sortObjects sorts the given objects by name.
+func sortObjects(objs []*Object) {
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].Name < objs[j].Name
+	})
+}
+
+// newNode adds a new node with the given key to the constraint graph; an
+// empty key adds an anonymous node.
+func (r *Result) newNode(key string) int {
+	id := len(r.nodes)
+	r.nodes = append(r.nodes, &node{pts: make(map[int]bool), succs: make(map[int]bool)})
+	if len(key) > 0 {
+		r.ids[key] = id
+	}
+	return id
+}
+
+// lookup returns the node of the given key, creating it if not present.
+func (r *Result) lookup(key string) int {
+	if id, ok := r.ids[key]; ok {
+		return id
+	}
+	return r.newNode(key)
+}
+
+// object returns the node of the given object, creating the object if not
+// present.
+func (r *Result) object(key string, obj *Object) int {
+	id := r.lookup(key)
+	if r.nodes[id].obj == nil {
+		r.nodes[id].obj = obj
+	}
+	return id
+}
+
+// field returns the node of the given field of the given object; or the object
+// itself if the field is empty or nested too deeply.
+func (r *Result) field(obj int, field string) int {
+	if len(field) == 0 {
+		return obj
+	}
+	parent := r.nodes[obj].obj
+	if parent == nil {
+		return obj
+	}
+	depth := 0
+	for p := parent; p.Parent != nil; p = p.Parent {
+		depth++
+	}
+	if depth >= maxFieldDepth {
+		return obj
+	}
+	key := fmt.Sprintf("field %d %s", obj, field)
+	return r.object(key, &Object{Kind: ObjectField, Name: parent.Name + "." + field, Decl: parent.Decl, Parent: parent})
+}
+
+// solve solves the constraints of the constraint graph to a fixed point.
+func (r *Result) solve() {
+	var worklist []int
+	queued := make(map[int]bool)
+	push := func(id int) {
+		if !queued[id] {
+			queued[id] = true
+			worklist = append(worklist, id)
+		}
+	}
+	// addEdge adds an inclusion edge, propagating the points-to set of from.
+	addEdge := func(from, to int) {
+		if from == to || r.nodes[from].succs[to] {
+			return
+		}
+		r.nodes[from].succs[to] = true
+		if r.include(to, from) {
+			push(to)
+		}
+	}
+	for id, n := range r.nodes {
+		if len(n.pts) > 0 {
+			push(id)
+		}
+	}
+	for len(worklist) > 0 {
+		id := worklist[0]
+		worklist = worklist[1:]
+		queued[id] = false
+		n := r.nodes[id]
+		var objs []int
+		for obj := range n.pts {
+			objs = append(objs, obj)
+		}
+		sort.Ints(objs)
+		for _, obj := range objs {
+			for _, c := range n.loads {
+				addEdge(r.field(obj, c.field), c.other)
+			}
+			for _, c := range n.stores {
+				addEdge(c.other, r.field(obj, c.field))
+			}
+			for _, c := range n.geps {
+				f := r.field(obj, c.field)
+				if !r.nodes[c.other].pts[f] {
+					r.nodes[c.other].pts[f] = true
+					push(c.other)
+				}
+			}
+			for _, c := range n.calls {
+				target := r.nodes[obj].obj
+				if target == nil || target.Kind != ObjectFunc || c.targets[obj] {
+					continue
+				}
+				c.targets[obj] = true
+				usr := target.Decl.USR()
+				for i, arg := range c.args {
+					if arg >= 0 {
+						addEdge(arg, r.lookup(paramKey(usr, i)))
+					}
+				}
+				addEdge(r.lookup(retKey(usr)), c.ret)
+			}
+		}
+		for succ := range n.succs {
+			if r.include(succ, id) {
+				push(succ)
+			}
+		}
+	}
+}
+
+// include adds the points-to set of src to the points-to set of dst, reporting
+// whether the points-to set of dst changed.
+func (r *Result) include(dst, src int) bool {
+	changed := false
+	for obj := range r.nodes[src].pts {
+		if !r.nodes[dst].pts[obj] {
+			r.nodes[dst].pts[obj] = true
+			changed = true
+		}
+	}
+	return changed
+}
+
+// varKey returns the node key of the given variable or parameter.
+func varKey(decl clang.Cursor) string {
+	return "var " + decl.USR()
+}
+
+// funcKey returns the object key of the given function.
+func funcKey(decl clang.Cursor) string {
+	return "func " + decl.USR()
+}
+
+// paramKey returns the node key of the parameter of the given index of the
+// function with the given USR.
+func paramKey(usr string, i int) string {
+	return fmt.Sprintf("param %s %d", usr, i)
+}
+
+// retKey returns the node key of the return value of the function with the
+// given USR.
+func retKey(usr string) string {
+	return "ret " + usr
+}
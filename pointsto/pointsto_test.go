@@ -0,0 +1,111 @@
+package pointsto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/callgraph"
+)
+
+const src = `
+typedef __SIZE_TYPE__ size_t;
+void *malloc(size_t);
+int a, b;
+struct s { int *f; int *g; };
+static int one(void) { return 1; }
+static int two(void) { return 2; }
+static void set(int **pp) { *pp = &b; }
+int main(void) {
+	char *h = malloc(4);
+	int *p = &a;
+	int *q = p;
+	int *r, *t, *u, *w;
+	struct s s;
+	int (*fp)(void) = one;
+	if (a) r = &a; else r = &b;
+	s.f = &b;
+	t = s.f;
+	u = s.g;
+	set(&w);
+	if (b) fp = two;
+	return fp() + *q + *r + *t + *w + (u == 0) + (h == 0);
+}
+`
+
+// names returns the comma-separated names of the given objects.
+func names(objs []*Object) string {
+	var ss []string
+	for _, obj := range objs {
+		ss = append(ss, obj.Name)
+	}
+	return strings.Join(ss, ",")
+}
+
+func TestAnalyze(t *testing.T) {
+	file, err := cc.ParseSource("pointsto.c", src)
+	if err != nil {
+		t.Fatalf("unable to parse source; %+v", err)
+	}
+	defer file.Close()
+	r := Analyze(file)
+	vars := make(map[string]clang.Cursor)
+	var indirect *cc.Node
+	cc.Walk(file.Root, func(n *cc.Node) {
+		switch n.Body.Kind() {
+		case clang.Cursor_VarDecl:
+			vars[n.Body.Spelling()] = n.Body
+		case clang.Cursor_CallExpr:
+			if n.Body.Referenced().Kind() != clang.Cursor_FunctionDecl {
+				indirect = n
+			}
+		}
+	})
+	golden := []struct {
+		name string
+		want string
+	}{
+		{name: "h", want: "malloc@pointsto.c:10:12"},
+		{name: "p", want: "a"},
+		{name: "q", want: "a"},
+		{name: "r", want: "a,b"},
+		{name: "t", want: "b"},
+		{name: "u", want: ""},
+		{name: "w", want: "b"},
+		{name: "fp", want: "one,two"},
+	}
+	for _, g := range golden {
+		decl, ok := vars[g.name]
+		if !ok {
+			t.Errorf("%q: unable to locate variable", g.name)
+			continue
+		}
+		if got := names(r.VarPointsTo(decl)); got != g.want {
+			t.Errorf("%q: points-to set mismatch; expected %q, got %q", g.name, g.want, got)
+		}
+	}
+	if indirect == nil {
+		t.Fatalf("unable to locate call through function pointer")
+	}
+	if got, want := names(r.Callees(indirect)), "one,two"; got != want {
+		t.Errorf("callees mismatch; expected %q, got %q", want, got)
+	}
+	g := callgraph.New(file)
+	r.ResolveCalls(g)
+	for _, f := range g.Sorted() {
+		if f.Name != "main" {
+			continue
+		}
+		if len(f.Indirect) != 0 {
+			t.Errorf("number of unresolved indirect calls mismatch; expected 0, got %d", len(f.Indirect))
+		}
+		var callees []string
+		for _, callee := range callgraph.Callees(f) {
+			callees = append(callees, callee.Name)
+		}
+		if got, want := strings.Join(callees, ","), "malloc,one,set,two"; got != want {
+			t.Errorf("callees of main mismatch; expected %q, got %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+// Package portability reports portability differences of source files between
+// compilation targets.
+//
+// The same source files are parsed once per target triple, and the size,
+// alignment and layout of declared types, the underlying types of enums and
+// type definitions, and the conditionally compiled code are compared between
+// targets.
+package portability
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/variability"
+	"github.com/pkg/errors"
+)
+
+// Report is a portability report.
+type Report struct {
+	// Target triples, in analysis order.
+	Triples []string
+	// Types whose layout differs between targets, sorted by name.
+	Types []*Type
+	// Declarations and statements present for some but not all targets.
+	Code []*variability.Entity
+	// Conditionally compiled code never compiled for any target.
+	Dead []*variability.Branch
+}
+
+// Type is a type declared in the analyzed source files.
+type Type struct {
+	// Type name (e.g. "struct foo", "enum bar", "baz").
+	Name string
+	// Source location of type declaration.
+	Loc cc.Location
+	// Layout of the type, indexed by target; nil if not present for target.
+	Layouts []*Layout
+}
+
+// Layout is the layout of a type for a specific target.
+type Layout struct {
+	// Size in bytes; or -1 if incomplete.
+	Size int64
+	// Alignment in bytes; or -1 if incomplete.
+	Align int64
+	// Canonical underlying type of type definitions, and underlying integer
+	// type of enums; or empty otherwise.
+	Underlying string
+	// Fields of struct and union types.
+	Fields []Field
+}
+
+// Field is the layout of a field of a struct or union type.
+type Field struct {
+	// Field name.
+	Name string
+	// Canonical field type.
+	Type string
+	// Offset in bits.
+	Offset int64
+}
+
+// Differences returns descriptions of the layout differences of the type
+// between the given targets.
+func (t *Type) Differences(triples []string) []string {
+	var diffs []string
+	prop := func(desc string, f func(l *Layout) string) {
+		vals := make([]string, len(t.Layouts))
+		same := true
+		for i, l := range t.Layouts {
+			if l == nil {
+				vals[i] = "-"
+			} else {
+				vals[i] = f(l)
+			}
+			same = same && vals[i] == vals[0]
+		}
+		if same {
+			return
+		}
+		var ss []string
+		for i, val := range vals {
+			ss = append(ss, fmt.Sprintf("%s=%s", triples[i], val))
+		}
+		diffs = append(diffs, fmt.Sprintf("%s: %s", desc, strings.Join(ss, ", ")))
+	}
+	prop("size", func(l *Layout) string { return fmt.Sprint(l.Size) })
+	prop("alignment", func(l *Layout) string { return fmt.Sprint(l.Align) })
+	prop("underlying type", func(l *Layout) string { return l.Underlying })
+	// Fields are compared by name.
+	var names []string
+	seen := make(map[string]bool)
+	for _, l := range t.Layouts {
+		if l == nil {
+			continue
+		}
+		for _, f := range l.Fields {
+			if !seen[f.Name] {
+				seen[f.Name] = true
+				names = append(names, f.Name)
+			}
+		}
+	}
+	for _, name := range names {
+		field := func(l *Layout) *Field {
+			for i := range l.Fields {
+				if l.Fields[i].Name == name {
+					return &l.Fields[i]
+				}
+			}
+			return nil
+		}
+		prop(fmt.Sprintf("offset of %s", name), func(l *Layout) string {
+			if f := field(l); f != nil {
+				return fmt.Sprint(f.Offset)
+			}
+			return "-"
+		})
+		prop(fmt.Sprintf("type of %s", name), func(l *Layout) string {
+			if f := field(l); f != nil {
+				return f.Type
+			}
+			return "-"
+		})
+	}
+	return diffs
+}
+
+// Analyze parses the given source files for each target triple using the
+// given compiler environment configuration, and reports the differences
+// between targets. The target of the configuration is ignored, and a host
+// compiler environment should not be used as its predefined macros are
+// specific to the host target.
+func Analyze(srcPaths []string, triples []string, config *cc.Config) (*Report, error) {
+	// Arguments of the target independent configuration; also validates the
+	// configuration before parsing.
+	args, err := withoutTarget(config).ClangArgs()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r := &Report{Triples: triples}
+	// Compare type layouts.
+	typeFromKey := make(map[string]*Type)
+	for i, triple := range triples {
+		c := *config
+		c.Target = triple
+		for _, srcPath := range srcPaths {
+			file, e := cc.ParseFileWithConfig(srcPath, &c)
+			if e != nil && err == nil {
+				// Keep the first error but continue with the partial AST.
+				err = e
+			}
+			cc.Walk(file.Root, func(n *cc.Node) {
+				name, layout, ok := typeLayout(n)
+				if !ok {
+					return
+				}
+				key := typeKey(n)
+				t, ok := typeFromKey[key]
+				if !ok {
+					t = &Type{
+						Name:    name,
+						Loc:     n.Loc,
+						Layouts: make([]*Layout, len(triples)),
+					}
+					typeFromKey[key] = t
+				}
+				// Keep the complete definition if present.
+				if prev := t.Layouts[i]; prev == nil || prev.Size < 0 {
+					t.Layouts[i] = layout
+				}
+			})
+			file.Close()
+		}
+	}
+	for _, t := range typeFromKey {
+		if !sameLayouts(t.Layouts) {
+			r.Types = append(r.Types, t)
+		}
+	}
+	sort.Slice(r.Types, func(i, j int) bool {
+		a, b := r.Types[i], r.Types[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Loc.String() < b.Loc.String()
+	})
+	// Compare conditionally compiled code.
+	var configs []*variability.Config
+	for _, triple := range triples {
+		configs = append(configs, &variability.Config{
+			Name: triple,
+			Args: []string{"-target", triple},
+		})
+	}
+	seenEntity := make(map[string]bool)
+	seenBranch := make(map[string]bool)
+	for _, srcPath := range srcPaths {
+		res, e := variability.Analyze(srcPath, configs, args...)
+		if e != nil && err == nil {
+			err = e
+		}
+		// Headers shared by several source files are only reported once.
+		for _, ent := range res.Variable {
+			key := fmt.Sprintf("%s %s", ent.Loc, ent.Kind)
+			if !seenEntity[key] {
+				seenEntity[key] = true
+				r.Code = append(r.Code, ent)
+			}
+		}
+		for _, b := range res.Dead {
+			if key := b.String(); !seenBranch[key] {
+				seenBranch[key] = true
+				r.Dead = append(r.Dead, b)
+			}
+		}
+	}
+	return r, err
+}
+
+// withoutTarget returns a copy of the given compiler environment
+// configuration without target triple, neither of its own nor of its host
+// compiler environment.
+func withoutTarget(config *cc.Config) *cc.Config {
+	c := *config
+	c.Target = ""
+	if c.Host != nil {
+		host := *c.Host
+		host.Target = ""
+		c.Host = &host
+	}
+	return &c
+}
+
+// typeKey returns the key identifying the given type declaration node across
+// targets and translation units. Types declared in headers are identified by
+// USR, and types declared in source files by USR and file, as the USRs of
+// same-named file-local types of different source files may coincide.
+func typeKey(n *cc.Node) string {
+	usr := n.Body.USR()
+	if n.Body.Location().IsFromMainFile() {
+		return n.Loc.File + " " + usr
+	}
+	return usr
+}
+
+// typeLayout returns the name and layout of the given type declaration node.
+// The boolean return value indicates success.
+func typeLayout(n *cc.Node) (string, *Layout, bool) {
+	if n.Body.Location().IsInSystemHeader() {
+		return "", nil, false
+	}
+	name := n.Body.Spelling()
+	var prefix, underlying string
+	switch n.Body.Kind() {
+	case clang.Cursor_StructDecl:
+		prefix = "struct "
+	case clang.Cursor_UnionDecl:
+		prefix = "union "
+	case clang.Cursor_EnumDecl:
+		prefix = "enum "
+		underlying = n.Body.EnumDeclIntegerType().CanonicalType().Spelling()
+	case clang.Cursor_TypedefDecl:
+		underlying = n.Body.TypedefDeclUnderlyingType().CanonicalType().Spelling()
+	default:
+		return "", nil, false
+	}
+	if len(name) == 0 {
+		// Anonymous types are compared through their type definitions.
+		return "", nil, false
+	}
+	t := n.Body.Type()
+	l := &Layout{
+		Size:       -1,
+		Align:      -1,
+		Underlying: underlying,
+	}
+	// Negative sizes and alignments denote layout errors (e.g. incomplete
+	// types).
+	if size := t.SizeOf(); size >= 0 {
+		l.Size = size
+	}
+	if align := t.AlignOf(); align >= 0 {
+		l.Align = align
+	}
+	// Fields of struct and union types, and of anonymous struct and union
+	// types underlying type definitions.
+	decl := t.CanonicalType().Declaration()
+	switch decl.Kind() {
+	case clang.Cursor_StructDecl, clang.Cursor_UnionDecl:
+		if n.Body.Kind() == clang.Cursor_TypedefDecl && len(decl.Spelling()) > 0 {
+			// Named record compared separately.
+			break
+		}
+		decl.Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+			if cursor.Kind() == clang.Cursor_FieldDecl {
+				f := Field{
+					Name:   cursor.Spelling(),
+					Type:   cursor.Type().CanonicalType().Spelling(),
+					Offset: cursor.OffsetOfField(),
+				}
+				l.Fields = append(l.Fields, f)
+			}
+			return clang.ChildVisit_Continue
+		})
+	}
+	return prefix + name, l, true
+}
+
+// sameLayouts reports whether the given layouts are identical.
+func sameLayouts(layouts []*Layout) bool {
+	for _, l := range layouts[1:] {
+		if !reflect.DeepEqual(l, layouts[0]) {
+			return false
+		}
+	}
+	return true
+}
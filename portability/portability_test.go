@@ -0,0 +1,87 @@
+package portability
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+func TestAnalyze(t *testing.T) {
+	sources := map[string]string{
+		"common.h": `struct shared { long n; };
+typedef int word;
+`,
+		"a.c": `#include "common.h"
+struct local { int a; };
+struct shared s;
+#ifdef __x86_64__
+int wide;
+#endif
+`,
+		"b.c": `#include "common.h"
+struct local { char c[8]; };
+struct shared t;
+`,
+	}
+	dir, err := ioutil.TempDir("", "portability")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	for name, src := range sources {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatalf("unable to write source file; %+v", err)
+		}
+	}
+	srcPaths := []string{filepath.Join(dir, "a.c"), filepath.Join(dir, "b.c")}
+	triples := []string{"x86_64-linux-gnu", "i386-linux-gnu"}
+	r, err := Analyze(srcPaths, triples, &cc.Config{})
+	if err != nil {
+		t.Fatalf("unable to analyze source files; %+v", err)
+	}
+	// The file-local struct local types have the same layout on both targets
+	// and must not be compared with each other.
+	if len(r.Types) != 1 || r.Types[0].Name != "struct shared" {
+		var names []string
+		for _, typ := range r.Types {
+			names = append(names, typ.Name)
+		}
+		t.Fatalf("types mismatch; expected [struct shared], got %v", names)
+	}
+	if diffs := r.Types[0].Differences(triples); len(diffs) != 2 {
+		t.Errorf("differences of %q mismatch; expected size and alignment, got %v", "struct shared", diffs)
+	}
+	found := false
+	for _, e := range r.Code {
+		if e.Name == "wide" {
+			found = true
+			if !e.Present[0] || e.Present[1] {
+				t.Errorf("presence of %q mismatch; expected [true false], got %v", "wide", e.Present)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("unable to locate conditionally compiled variable %q", "wide")
+	}
+}
+
+func TestWithoutTarget(t *testing.T) {
+	// The target of the host compiler environment must not be passed in
+	// addition to the target of each configuration.
+	config := &cc.Config{Target: "i386-linux-gnu", Host: &cc.Host{Target: "x86_64-linux-gnu"}}
+	args, err := withoutTarget(config).ClangArgs()
+	if err != nil {
+		t.Fatalf("unable to get Clang arguments; %+v", err)
+	}
+	for _, arg := range args {
+		if arg == "-target" {
+			t.Fatalf("unexpected target argument in %q", args)
+		}
+	}
+	if config.Target != "i386-linux-gnu" || config.Host.Target != "x86_64-linux-gnu" {
+		t.Errorf("target of original configuration modified")
+	}
+}
@@ -0,0 +1,192 @@
+// Package rewrite implements source code rewriting based on the locations of
+// AST nodes.
+//
+// Edits are recorded for source locations and applied to the contents of the
+// source files in a single pass. Before applying an edit, the text at its
+// location is verified to match the expected text; mismatching edits (e.g.
+// within macro expansions) cannot be applied and are reported by Skipped.
+package rewrite
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/index"
+	"github.com/pkg/errors"
+)
+
+// Edit is a textual replacement at a source location.
+type Edit struct {
+	// Source location of the replaced text.
+	Loc cc.Location
+	// Replaced text.
+	Old string
+	// Replacement text.
+	New string
+}
+
+// Rewriter records edits of source files.
+type Rewriter struct {
+	// Edits, by source file and location.
+	edits map[string]map[cc.Location]*Edit
+}
+
+// New returns a new rewriter.
+func New() *Rewriter {
+	return &Rewriter{edits: make(map[string]map[cc.Location]*Edit)}
+}
+
+// Replace records an edit replacing the given text at the given source
+// location. Duplicate edits (e.g. of headers included by several translation
+// units) are recorded once.
+func (r *Rewriter) Replace(loc cc.Location, old, repl string) error {
+	edits, ok := r.edits[loc.File]
+	if !ok {
+		edits = make(map[cc.Location]*Edit)
+		r.edits[loc.File] = edits
+	}
+	if prev, ok := edits[loc]; ok {
+		if prev.Old != old || prev.New != repl {
+			return errors.Errorf("conflicting edits at %v; %q -> %q and %q -> %q", loc, prev.Old, prev.New, old, repl)
+		}
+		return nil
+	}
+	edits[loc] = &Edit{Loc: loc, Old: old, New: repl}
+	return nil
+}
+
+// Rename records edits renaming the declarations and references of the given
+// symbol. Occurrences located in system headers are not renamed. The locations
+// of declarations and references point to the symbol name (e.g. the location of
+// a type reference to "struct foo" points to "foo").
+func (r *Rewriter) Rename(sym *index.Symbol, newName string) error {
+	occs := append(append([]*index.Occurrence{}, sym.Decls...), sym.Refs...)
+	for _, occ := range occs {
+		n := occ.Node
+		if n.Body.Location().IsInSystemHeader() {
+			continue
+		}
+		if err := r.Replace(n.Loc, sym.Name, newName); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// Edits returns the recorded edits of the given source file, sorted by
+// location.
+func (r *Rewriter) Edits(path string) []*Edit {
+	var edits []*Edit
+	for _, edit := range r.edits[path] {
+		edits = append(edits, edit)
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		a, b := edits[i].Loc, edits[j].Loc
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return edits
+}
+
+// Skipped returns the recorded edits which cannot be applied, as their
+// replaced text does not match the contents of the source file (e.g. within
+// macro expansions) or they overlap a previous edit, sorted by source file and
+// location.
+func (r *Rewriter) Skipped() ([]*Edit, error) {
+	var skipped []*Edit
+	for _, path := range r.paths() {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		_, s := apply(buf, r.Edits(path))
+		skipped = append(skipped, s...)
+	}
+	return skipped, nil
+}
+
+// Files returns the rewritten contents of the edited source files, by path. An
+// error is returned if any edit cannot be applied (see Skipped).
+func (r *Rewriter) Files() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, path := range r.paths() {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		out, skipped := apply(buf, r.Edits(path))
+		if len(skipped) > 0 {
+			edit := skipped[0]
+			return nil, errors.Errorf("unable to apply edit at %v; %q not found (%d edits skipped)", edit.Loc, edit.Old, len(skipped))
+		}
+		files[path] = out
+	}
+	return files, nil
+}
+
+// paths returns the paths of the edited source files, sorted by path.
+func (r *Rewriter) paths() []string {
+	var paths []string
+	for path := range r.edits {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// WriteFiles writes the rewritten contents of the edited source files to disk.
+func (r *Rewriter) WriteFiles() error {
+	files, err := r.Files()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for path, buf := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := ioutil.WriteFile(path, buf, info.Mode()); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// apply applies the given edits, sorted by location, to the given source file
+// contents. Edits whose replaced text does not match the contents or which
+// overlap a previous edit are skipped, and returned.
+func apply(buf []byte, edits []*Edit) ([]byte, []*Edit) {
+	// Offsets of the start of each line.
+	lineStarts := []int{0}
+	for i, b := range buf {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	out := &bytes.Buffer{}
+	prev := 0
+	var skipped []*Edit
+	for _, edit := range edits {
+		if edit.Loc.Line < 1 || int(edit.Loc.Line) > len(lineStarts) || edit.Loc.Col < 1 {
+			skipped = append(skipped, edit)
+			continue
+		}
+		// Columns are byte offsets (1-indexed).
+		start := lineStarts[edit.Loc.Line-1] + int(edit.Loc.Col) - 1
+		end := start + len(edit.Old)
+		if start < prev || end > len(buf) || string(buf[start:end]) != edit.Old {
+			skipped = append(skipped, edit)
+			continue
+		}
+		out.Write(buf[prev:start])
+		out.WriteString(edit.New)
+		prev = end
+	}
+	out.Write(buf[prev:])
+	return out.Bytes(), skipped
+}
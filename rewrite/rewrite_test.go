@@ -0,0 +1,83 @@
+package rewrite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+func TestRewriter(t *testing.T) {
+	const src = "int foo;\nDECL(foo);\nint g(void) { return foo; }\n"
+	dir, err := ioutil.TempDir("", "rewrite")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a.c")
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatalf("unable to write source file; %+v", err)
+	}
+	golden := []struct {
+		edits []*Edit
+		// Rewritten contents; or empty if edits are skipped.
+		want string
+		// Number of skipped edits.
+		skipped int
+	}{
+		{
+			edits: []*Edit{
+				{Loc: cc.Location{File: path, Line: 1, Col: 5}, Old: "foo", New: "bar"},
+				{Loc: cc.Location{File: path, Line: 3, Col: 22}, Old: "foo", New: "bar"},
+			},
+			want: "int bar;\nDECL(foo);\nint g(void) { return bar; }\n",
+		},
+		// Replaced text mismatch (e.g. location of macro expansion).
+		{
+			edits: []*Edit{
+				{Loc: cc.Location{File: path, Line: 1, Col: 5}, Old: "foo", New: "bar"},
+				{Loc: cc.Location{File: path, Line: 2, Col: 1}, Old: "foo", New: "bar"},
+			},
+			skipped: 1,
+		},
+		// Location outside of source file.
+		{
+			edits: []*Edit{
+				{Loc: cc.Location{File: path, Line: 10, Col: 1}, Old: "foo", New: "bar"},
+			},
+			skipped: 1,
+		},
+	}
+	for i, g := range golden {
+		r := New()
+		for _, edit := range g.edits {
+			if err := r.Replace(edit.Loc, edit.Old, edit.New); err != nil {
+				t.Fatalf("test %d: unable to record edit; %+v", i, err)
+			}
+		}
+		skipped, err := r.Skipped()
+		if err != nil {
+			t.Errorf("test %d: unable to locate skipped edits; %+v", i, err)
+			continue
+		}
+		if len(skipped) != g.skipped {
+			t.Errorf("test %d: number of skipped edits mismatch; expected %d, got %d", i, g.skipped, len(skipped))
+		}
+		files, err := r.Files()
+		if g.skipped > 0 {
+			if err == nil {
+				t.Errorf("test %d: expected error for skipped edits", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unable to rewrite files; %+v", i, err)
+			continue
+		}
+		if got := string(files[path]); got != g.want {
+			t.Errorf("test %d: rewritten contents mismatch; expected %q, got %q", i, g.want, got)
+		}
+	}
+}
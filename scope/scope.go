@@ -0,0 +1,236 @@
+// Package scope constructs scoped symbol tables of translation units.
+//
+// A scope tree is built for each translation unit, with the file scope at the
+// root. Function definitions introduce a function scope, holding the
+// parameters and the declarations of the outermost block of the function body.
+// Compound statements and the for, if, while and switch statements introduce
+// block scopes. Declarations are added to their scope in source order, so
+// lookups performed while building the tree follow the scoping rules of C.
+package scope
+
+import (
+	"fmt"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+)
+
+// Kind is the kind of a scope.
+type Kind uint8
+
+// Scope kinds.
+const (
+	// File scope.
+	KindFile Kind = iota
+	// Function scope.
+	KindFunc
+	// Block scope.
+	KindBlock
+)
+
+// String returns the string representation of the scope kind.
+func (kind Kind) String() string {
+	switch kind {
+	case KindFile:
+		return "file"
+	case KindFunc:
+		return "function"
+	case KindBlock:
+		return "block"
+	}
+	return fmt.Sprintf("Kind(%d)", uint8(kind))
+}
+
+// Scope is a lexical scope.
+type Scope struct {
+	// Kind of scope.
+	Kind Kind
+	// Node introducing the scope (translation unit, function definition or
+	// statement).
+	Node *cc.Node
+	// Parent scope; or nil for file scope.
+	Parent *Scope
+	// Nested scopes, in source order.
+	Children []*Scope
+	// Declarations of the scope, in source order.
+	Decls []*Decl
+	// Declarations of the scope, by name.
+	names map[string]*Decl
+}
+
+// Decl is a declaration of a variable, parameter, function, type definition or
+// enum constant.
+type Decl struct {
+	// Declared name.
+	Name string
+	// Declaration node.
+	Node *cc.Node
+	// Scope of the declaration.
+	Scope *Scope
+	// Declaration of the same name in an enclosing scope hidden by the
+	// declaration; or nil if not shadowing.
+	Shadows *Decl
+	// References to the declaration, in source order.
+	Refs []*Ref
+}
+
+// Ref is a reference to a declaration.
+type Ref struct {
+	// Reference node.
+	Node *cc.Node
+	// Innermost scope of the reference.
+	Scope *Scope
+}
+
+// Build returns the file scope of the given translation unit.
+func Build(file *cc.File) *Scope {
+	b := &builder{declFromKey: make(map[string]*Decl)}
+	root := newScope(KindFile, file.Root, nil)
+	for _, child := range file.Root.Children {
+		b.walk(child, root)
+	}
+	for _, ref := range b.refs {
+		target := ref.Node.Body.Referenced()
+		if decl, ok := b.declFromKey[declKey(target.Spelling(), cc.NewLocation(target.Location()))]; ok {
+			decl.Refs = append(decl.Refs, ref)
+		}
+	}
+	return root
+}
+
+// Lookup returns the declaration of the given name visible in the scope; or
+// nil if not present.
+func (s *Scope) Lookup(name string) *Decl {
+	for ; s != nil; s = s.Parent {
+		if decl, ok := s.names[name]; ok {
+			return decl
+		}
+	}
+	return nil
+}
+
+// Contains reports whether the given scope is the scope or nested within it.
+func (s *Scope) Contains(t *Scope) bool {
+	for ; t != nil; t = t.Parent {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Func returns the enclosing function scope of the scope; or nil if not
+// within a function.
+func (s *Scope) Func() *Scope {
+	for ; s != nil; s = s.Parent {
+		if s.Kind == KindFunc {
+			return s
+		}
+	}
+	return nil
+}
+
+// Walk walks the scope tree rooted at the scope, invoking f for each scope
+// visited.
+func (s *Scope) Walk(f func(s *Scope)) {
+	f(s)
+	for _, child := range s.Children {
+		child.Walk(f)
+	}
+}
+
+// newScope returns a new scope of the given kind, nested within parent.
+func newScope(kind Kind, n *cc.Node, parent *Scope) *Scope {
+	s := &Scope{Kind: kind, Node: n, Parent: parent, names: make(map[string]*Decl)}
+	if parent != nil {
+		parent.Children = append(parent.Children, s)
+	}
+	return s
+}
+
+// builder tracks the state of scope tree construction.
+type builder struct {
+	// Declarations, by name and location.
+	declFromKey map[string]*Decl
+	// References to be resolved.
+	refs []*Ref
+}
+
+// declare adds a declaration of the given node to the scope.
+func (b *builder) declare(n *cc.Node, s *Scope) {
+	name := n.Body.Spelling()
+	if len(name) == 0 {
+		return
+	}
+	key := declKey(name, n.Loc)
+	if _, ok := b.declFromKey[key]; ok {
+		// Redeclaration (e.g. header included twice).
+		return
+	}
+	decl := &Decl{Name: name, Node: n, Scope: s}
+	if s.Parent != nil {
+		decl.Shadows = s.Parent.Lookup(name)
+	}
+	// Keep the first declaration for lookup on redeclaration in the same scope
+	// (e.g. extern declaration followed by definition).
+	if _, ok := s.names[name]; !ok {
+		s.names[name] = decl
+	}
+	s.Decls = append(s.Decls, decl)
+	b.declFromKey[key] = decl
+}
+
+// walk adds the declarations, references and scopes of the given node to the
+// scope tree.
+func (b *builder) walk(n *cc.Node, s *Scope) {
+	switch n.Body.Kind() {
+	case clang.Cursor_FunctionDecl, clang.Cursor_CXXMethod, clang.Cursor_Constructor, clang.Cursor_Destructor:
+		b.declare(n, s)
+		if !n.Body.IsCursorDefinition() {
+			// Parameters of function prototypes have prototype scope.
+			return
+		}
+		fs := newScope(KindFunc, n, s)
+		for _, child := range n.Children {
+			switch child.Body.Kind() {
+			case clang.Cursor_ParmDecl:
+				b.declare(child, fs)
+			case clang.Cursor_CompoundStmt:
+				// The outermost block of a function body shares the scope of
+				// the parameters.
+				for _, stmt := range child.Children {
+					b.walk(stmt, fs)
+				}
+			default:
+				b.walk(child, fs)
+			}
+		}
+	case clang.Cursor_CompoundStmt, clang.Cursor_ForStmt, clang.Cursor_IfStmt, clang.Cursor_WhileStmt, clang.Cursor_SwitchStmt:
+		bs := newScope(KindBlock, n, s)
+		for _, child := range n.Children {
+			b.walk(child, bs)
+		}
+	case clang.Cursor_VarDecl, clang.Cursor_TypedefDecl, clang.Cursor_EnumConstantDecl:
+		b.declare(n, s)
+		for _, child := range n.Children {
+			b.walk(child, s)
+		}
+	case clang.Cursor_ParmDecl:
+		// Parameters of function pointer types have prototype scope.
+	case clang.Cursor_DeclRefExpr:
+		b.refs = append(b.refs, &Ref{Node: n, Scope: s})
+		for _, child := range n.Children {
+			b.walk(child, s)
+		}
+	default:
+		for _, child := range n.Children {
+			b.walk(child, s)
+		}
+	}
+}
+
+// declKey returns a key identifying the declaration of the given name at the
+// given location.
+func declKey(name string, loc cc.Location) string {
+	return fmt.Sprintf("%s %s", name, loc)
+}
@@ -0,0 +1,68 @@
+package scope
+
+import (
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+func TestBuild(t *testing.T) {
+	const src = `
+int g;
+int f(int p);
+int f(int p) {
+	int g = p;
+	{
+		int p = g;
+		return p;
+	}
+}
+`
+	file, err := cc.ParseSource("scope.c", src)
+	if err != nil {
+		t.Fatalf("unable to parse source; %+v", err)
+	}
+	defer file.Close()
+	golden := []struct {
+		name string
+		line uint32
+		kind Kind
+		// Line of shadowed declaration; or 0 if not shadowing.
+		shadows uint32
+		refs    int
+	}{
+		{name: "g", line: 2, kind: KindFile},
+		{name: "f", line: 3, kind: KindFile},
+		{name: "f", line: 4, kind: KindFile},
+		{name: "p", line: 4, kind: KindFunc, refs: 1},
+		{name: "g", line: 5, kind: KindFunc, shadows: 2, refs: 1},
+		{name: "p", line: 7, kind: KindBlock, shadows: 4, refs: 1},
+	}
+	var decls []*Decl
+	Build(file).Walk(func(s *Scope) {
+		decls = append(decls, s.Decls...)
+	})
+	if len(decls) != len(golden) {
+		t.Fatalf("number of declarations mismatch; expected %d, got %d", len(golden), len(decls))
+	}
+	for i, want := range golden {
+		decl := decls[i]
+		if decl.Name != want.name || decl.Node.Loc.Line != want.line {
+			t.Errorf("declaration %d mismatch; expected %s at line %d, got %s at line %d", i, want.name, want.line, decl.Name, decl.Node.Loc.Line)
+			continue
+		}
+		if decl.Scope.Kind != want.kind {
+			t.Errorf("%s at line %d: scope kind mismatch; expected %v, got %v", want.name, want.line, want.kind, decl.Scope.Kind)
+		}
+		var shadows uint32
+		if decl.Shadows != nil {
+			shadows = decl.Shadows.Node.Loc.Line
+		}
+		if shadows != want.shadows {
+			t.Errorf("%s at line %d: shadowed declaration mismatch; expected line %d, got line %d", want.name, want.line, want.shadows, shadows)
+		}
+		if len(decl.Refs) != want.refs {
+			t.Errorf("%s at line %d: number of references mismatch; expected %d, got %d", want.name, want.line, want.refs, len(decl.Refs))
+		}
+	}
+}
@@ -0,0 +1,339 @@
+// Package stack estimates the worst-case stack usage of C programs.
+//
+// The stack frame of each function is estimated from the types of its
+// parameters and local variables, laid out according to their sizes and
+// alignments. Variables of sibling blocks are assumed to share stack slots, as
+// their lifetimes do not overlap. Temporaries and spilled registers are not
+// accounted for, other than by a fixed per-call overhead.
+//
+// The worst-case stack depth of each entry point is the maximum sum of frame
+// sizes along the call chains of the call graph starting at the entry point.
+// Targets of calls through function pointers are resolved by points-to
+// analysis. The stack depth is unbounded if recursion, unresolved indirect
+// calls, variable-length arrays or alloca are reachable from the entry point,
+// in which case the reported depth is a lower bound.
+package stack
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/callgraph"
+	"github.com/mewspring/cc/pointsto"
+	"github.com/mewspring/cc/scope"
+)
+
+// Config is the configuration of the stack usage estimation.
+type Config struct {
+	// Names of entry points (e.g. "main", interrupt handlers); or empty to use
+	// the defined functions without callers.
+	Entries []string `json:"entries"`
+	// Size in bytes of the fixed overhead of each call (e.g. return address,
+	// saved registers).
+	CallOverhead int64 `json:"call_overhead"`
+	// Alignment in bytes of stack frames.
+	Align int64 `json:"align"`
+	// Stack frame sizes in bytes of functions defined outside the program
+	// (e.g. library functions), by name.
+	External map[string]int64 `json:"external"`
+}
+
+// DefaultConfig is the default configuration of the stack usage estimation;
+// the call overhead and frame alignment of a 64-bit target.
+var DefaultConfig = &Config{
+	CallOverhead: 8,
+	Align:        8,
+}
+
+// Frame is the estimated stack frame of a function.
+type Frame struct {
+	// Function.
+	Func *callgraph.Func
+	// Frame size in bytes, including the call overhead.
+	Size int64
+	// Parameters and local variables allocated on the stack, in source order.
+	Locals []*Local
+	// Dynamic stack allocations (variable-length arrays and calls to alloca).
+	Dynamic []*cc.Node
+}
+
+// Local is a parameter or local variable allocated on the stack.
+type Local struct {
+	// Variable name.
+	Name string
+	// Declaration node.
+	Node *cc.Node
+	// Size in bytes.
+	Size int64
+	// Offset in bytes within the stack frame.
+	Offset int64
+}
+
+// Usage is the worst-case stack usage of an entry point.
+type Usage struct {
+	// Entry point.
+	Entry *callgraph.Func
+	// Worst-case stack depth in bytes; a lower bound if unbounded.
+	Depth int64
+	// Call chain of the worst-case stack depth, starting at the entry point.
+	Path []*callgraph.Func
+	// Reasons the stack depth is unbounded; empty if bounded.
+	Unbounded []*Reason
+	// Reachable functions of unknown stack usage (i.e. functions defined
+	// outside the program without a configured frame size), sorted by name.
+	Unknown []*callgraph.Func
+}
+
+// Reason is a reason for unbounded stack depth.
+type Reason struct {
+	// Function containing the reason.
+	Func *callgraph.Func
+	// Source location of the reason.
+	Loc cc.Location
+	// Description (e.g. "recursive call to eval").
+	Message string
+}
+
+// String returns a string representation of the reason.
+func (r *Reason) String() string {
+	return fmt.Sprintf("%s: %s in %s", r.Loc, r.Message, r.Func.Name)
+}
+
+// Result is the result of a stack usage estimation.
+type Result struct {
+	// Stack frames of the functions defined in the program, sorted by name.
+	Frames []*Frame
+	// Worst-case stack usage of the entry points, sorted by name.
+	Usages []*Usage
+}
+
+// Analyze estimates the stack usage of the program consisting of the given
+// translation units, using the given configuration; or DefaultConfig if nil.
+func Analyze(files []*cc.File, config *Config) *Result {
+	if config == nil {
+		config = DefaultConfig
+	}
+	g := callgraph.New(files...)
+	pointsto.Analyze(files...).ResolveCalls(g)
+	frames := make(map[*callgraph.Func]*Frame)
+	for _, file := range files {
+		scope.Build(file).Walk(func(s *scope.Scope) {
+			if s.Kind != scope.KindFunc {
+				return
+			}
+			f, ok := g.Funcs[s.Node.Body.USR()]
+			if !ok || frames[f] != nil {
+				// Definition already visited (e.g. inline function of header
+				// included by several translation units).
+				return
+			}
+			frames[f] = newFrame(f, s, config)
+		})
+	}
+	r := &Result{}
+	for _, f := range g.Sorted() {
+		if frame, ok := frames[f]; ok {
+			r.Frames = append(r.Frames, frame)
+		}
+	}
+	ws := worstCase(g, frames, config)
+	for _, f := range entries(g, config) {
+		r.Usages = append(r.Usages, ws[f].usage(f))
+	}
+	return r
+}
+
+// entries returns the entry points of the call graph, sorted by name.
+func entries(g *callgraph.Graph, config *Config) []*callgraph.Func {
+	names := make(map[string]bool)
+	for _, name := range config.Entries {
+		names[name] = true
+	}
+	var fs []*callgraph.Func
+	for _, f := range g.Sorted() {
+		if f.Def == nil || f.Def.Body.Location().IsInSystemHeader() {
+			continue
+		}
+		if names[f.Name] || (len(names) == 0 && len(f.Callers) == 0) {
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
+
+// newFrame returns the estimated stack frame of the given function, based on
+// its function scope.
+func newFrame(f *callgraph.Func, s *scope.Scope, config *Config) *Frame {
+	frame := &Frame{Func: f}
+	end := frame.layout(s, 0)
+	frame.Size = alignTo(end+config.CallOverhead, config.Align)
+	cc.Walk(f.Def, func(n *cc.Node) {
+		if n.Body.Kind() != clang.Cursor_CallExpr {
+			return
+		}
+		switch n.Body.Referenced().Spelling() {
+		case "alloca", "__builtin_alloca":
+			frame.Dynamic = append(frame.Dynamic, n)
+		}
+	})
+	sort.SliceStable(frame.Locals, func(i, j int) bool {
+		a, b := frame.Locals[i].Node.Loc, frame.Locals[j].Node.Loc
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return frame
+}
+
+// layout lays out the variables of the given scope starting at the given
+// offset, followed by the variables of its nested scopes, which share stack
+// slots. The end offset of the layout is returned.
+func (frame *Frame) layout(s *scope.Scope, offset int64) int64 {
+	for _, decl := range s.Decls {
+		n := decl.Node
+		switch n.Body.Kind() {
+		case clang.Cursor_VarDecl:
+			switch n.StorageClass() {
+			case cc.StorageStatic, cc.StorageExtern:
+				continue
+			}
+		case clang.Cursor_ParmDecl:
+		default:
+			continue
+		}
+		t := n.Body.Type()
+		if t.CanonicalType().Kind() == clang.Type_VariableArray {
+			frame.Dynamic = append(frame.Dynamic, n)
+			continue
+		}
+		// Negative sizes and alignments denote errors (e.g. incomplete types).
+		size := t.SizeOf()
+		if size <= 0 {
+			continue
+		}
+		align := t.AlignOf()
+		if align <= 0 {
+			align = 1
+		}
+		offset = alignTo(offset, align)
+		frame.Locals = append(frame.Locals, &Local{Name: decl.Name, Node: n, Size: size, Offset: offset})
+		offset += size
+	}
+	end := offset
+	for _, child := range s.Children {
+		if childEnd := frame.layout(child, offset); childEnd > end {
+			end = childEnd
+		}
+	}
+	return end
+}
+
+// alignTo returns the given offset rounded up to a multiple of align.
+func alignTo(offset, align int64) int64 {
+	if align <= 1 {
+		return offset
+	}
+	return (offset + align - 1) / align * align
+}
+
+// worst is the worst-case stack usage of a function.
+type worst struct {
+	// Worst-case stack depth in bytes, including the frame of the function.
+	depth int64
+	// Callee of the worst-case call chain; or nil if not present.
+	next *worst
+	// Function.
+	f *callgraph.Func
+	// Reasons of unbounded stack depth, by location and message.
+	unbounded map[string]*Reason
+	// Reachable functions of unknown stack usage.
+	unknown map[*callgraph.Func]bool
+}
+
+// worstCase returns the worst-case stack usage of the functions of the call
+// graph. Calls within recursive functions are not followed; the recursion is
+// recorded as a reason of unbounded stack depth.
+func worstCase(g *callgraph.Graph, frames map[*callgraph.Func]*Frame, config *Config) map[*callgraph.Func]*worst {
+	ws := make(map[*callgraph.Func]*worst)
+	// Strongly connected components are ordered callees first.
+	for _, scc := range g.SCCs() {
+		inSCC := make(map[*callgraph.Func]bool)
+		for _, f := range scc {
+			inSCC[f] = true
+		}
+		for _, f := range scc {
+			w := &worst{f: f, unbounded: make(map[string]*Reason), unknown: make(map[*callgraph.Func]bool)}
+			ws[f] = w
+			addReason := func(loc cc.Location, format string, args ...interface{}) {
+				reason := &Reason{Func: f, Loc: loc, Message: fmt.Sprintf(format, args...)}
+				w.unbounded[reason.String()] = reason
+			}
+			frame, ok := frames[f]
+			if !ok {
+				if size, ok := config.External[f.Name]; ok {
+					w.depth = size
+				} else {
+					w.unknown[f] = true
+				}
+				continue
+			}
+			w.depth = frame.Size
+			for _, n := range frame.Dynamic {
+				if n.Body.Kind() == clang.Cursor_CallExpr {
+					addReason(n.Loc, "dynamic stack allocation")
+				} else {
+					addReason(n.Loc, "variable-length array %s", n.Body.Spelling())
+				}
+			}
+			for _, site := range f.Indirect {
+				addReason(site.Loc, "unresolved indirect call")
+			}
+			var maxCallee *worst
+			for _, call := range f.Callees {
+				if inSCC[call.Callee] {
+					addReason(call.Site.Loc, "recursive call to %s", call.Callee.Name)
+					continue
+				}
+				callee := ws[call.Callee]
+				for key, reason := range callee.unbounded {
+					w.unbounded[key] = reason
+				}
+				for u := range callee.unknown {
+					w.unknown[u] = true
+				}
+				if maxCallee == nil || callee.depth > maxCallee.depth {
+					maxCallee = callee
+				}
+			}
+			if maxCallee != nil {
+				w.depth += maxCallee.depth
+				w.next = maxCallee
+			}
+		}
+	}
+	return ws
+}
+
+// usage returns the worst-case stack usage of the given entry point.
+func (w *worst) usage(entry *callgraph.Func) *Usage {
+	u := &Usage{Entry: entry, Depth: w.depth}
+	for v := w; v != nil; v = v.next {
+		u.Path = append(u.Path, v.f)
+	}
+	for _, reason := range w.unbounded {
+		u.Unbounded = append(u.Unbounded, reason)
+	}
+	sort.Slice(u.Unbounded, func(i, j int) bool {
+		return u.Unbounded[i].String() < u.Unbounded[j].String()
+	})
+	for f := range w.unknown {
+		u.Unknown = append(u.Unknown, f)
+	}
+	sort.Slice(u.Unknown, func(i, j int) bool {
+		return u.Unknown[i].Name < u.Unknown[j].Name
+	})
+	return u
+}
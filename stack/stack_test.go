@@ -0,0 +1,102 @@
+package stack
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+func TestFrame(t *testing.T) {
+	golden := []struct {
+		src    string
+		config *Config
+		// Frame size.
+		size int64
+		// Locals, as name@offset.
+		locals string
+		// Number of dynamic stack allocations.
+		dynamic int
+	}{
+		{src: "void f(void) { char c; int i; }", config: &Config{}, size: 8, locals: "c@0,i@4"},
+		{src: "void f(int a) { char buf[10]; }", config: &Config{}, size: 14, locals: "a@0,buf@4"},
+		{src: "void f(int x) { if (x) { char a[8]; } else { char b[16]; } }", config: &Config{}, size: 20, locals: "x@0,a@4,b@4"},
+		{src: "void f(void) { static int s; extern int e; long l; }", config: &Config{}, size: 8, locals: "l@0"},
+		{src: "void f(void) { struct {} empty; int i; }", config: &Config{}, size: 4, locals: "i@0"},
+		{src: "void f(void) { char c; }", config: &Config{CallOverhead: 8, Align: 16}, size: 16, locals: "c@0"},
+		{src: "void f(int n) { char v[n]; }", config: &Config{}, size: 4, locals: "n@0", dynamic: 1},
+		{src: "void *alloca(unsigned long); void f(void) { alloca(8); }", config: &Config{}, size: 0, dynamic: 1},
+		// Default configuration; call overhead of 8 bytes aligned to 8 bytes.
+		{src: "void f(void) { char c; }", config: nil, size: 16, locals: "c@0"},
+	}
+	for _, g := range golden {
+		file, err := cc.ParseSource("stack.c", g.src)
+		if err != nil {
+			t.Errorf("unable to parse %q; %+v", g.src, err)
+			continue
+		}
+		r := Analyze([]*cc.File{file}, g.config)
+		if len(r.Frames) != 1 {
+			t.Errorf("%q: number of frames mismatch; expected 1, got %d", g.src, len(r.Frames))
+			file.Close()
+			continue
+		}
+		frame := r.Frames[0]
+		if frame.Size != g.size {
+			t.Errorf("%q: frame size mismatch; expected %d, got %d", g.src, g.size, frame.Size)
+		}
+		var locals []string
+		for _, local := range frame.Locals {
+			locals = append(locals, fmt.Sprintf("%s@%d", local.Name, local.Offset))
+		}
+		if got := strings.Join(locals, ","); got != g.locals {
+			t.Errorf("%q: locals mismatch; expected %q, got %q", g.src, g.locals, got)
+		}
+		if got := len(frame.Dynamic); got != g.dynamic {
+			t.Errorf("%q: number of dynamic stack allocations mismatch; expected %d, got %d", g.src, g.dynamic, got)
+		}
+		file.Close()
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	const src = `
+int puts(const char *);
+static void leaf(void) { char buf[64]; puts(buf); }
+static void mid(void) { int x; leaf(); }
+static int rec(int n) { return n ? rec(n - 1) : 0; }
+int main(void) { mid(); return rec(3); }
+`
+	file, err := cc.ParseSource("stack.c", src)
+	if err != nil {
+		t.Fatalf("unable to parse source; %+v", err)
+	}
+	defer file.Close()
+	config := &Config{CallOverhead: 16, Align: 16, External: map[string]int64{"puts": 32}}
+	r := Analyze([]*cc.File{file}, config)
+	if len(r.Usages) != 1 {
+		t.Fatalf("number of entry points mismatch; expected 1, got %d", len(r.Usages))
+	}
+	u := r.Usages[0]
+	if u.Entry.Name != "main" {
+		t.Errorf("entry point mismatch; expected %q, got %q", "main", u.Entry.Name)
+	}
+	// main (16) + mid (32) + leaf (80) + puts (32).
+	if u.Depth != 160 {
+		t.Errorf("stack depth mismatch; expected 160, got %d", u.Depth)
+	}
+	var path []string
+	for _, f := range u.Path {
+		path = append(path, f.Name)
+	}
+	if got, want := strings.Join(path, ","), "main,mid,leaf,puts"; got != want {
+		t.Errorf("call chain mismatch; expected %q, got %q", want, got)
+	}
+	if len(u.Unbounded) != 1 || !strings.HasSuffix(u.Unbounded[0].Message, "recursive call to rec") {
+		t.Errorf("unbounded reasons mismatch; expected recursive call to rec, got %v", u.Unbounded)
+	}
+	if len(u.Unknown) != 0 {
+		t.Errorf("number of functions of unknown stack usage mismatch; expected 0, got %d", len(u.Unknown))
+	}
+}
@@ -0,0 +1,695 @@
+package taint
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/callgraph"
+	"github.com/mewspring/cc/cfg"
+	"github.com/mewspring/cc/dataflow"
+)
+
+// label is a taint label; the taint of a value by untrusted data of a single
+// origin.
+type label struct {
+	// Origin of the taint; the location of a source call, or a parameter of
+	// the analyzed function.
+	origin string
+	// Parameter index, if the origin is a parameter of the analyzed function
+	// which is not an entry function parameter; or -1 otherwise.
+	param int
+	// Source of the untrusted data.
+	source string
+	// Steps of the flow, from the origin to the tainted value.
+	trace []*Step
+}
+
+// withStep returns a copy of the taint with the given step appended to its
+// trace.
+func (t *label) withStep(loc cc.Location, format string, args ...interface{}) *label {
+	c := *t
+	c.trace = append(append([]*Step(nil), t.trace...), &Step{Loc: loc, Message: fmt.Sprintf(format, args...)})
+	return &c
+}
+
+// taints is the taint of a value, by origin.
+type taints map[string]*label
+
+// String returns the sorted origins of the taints.
+func (ts taints) String() string {
+	var origins []string
+	for origin := range ts {
+		origins = append(origins, origin)
+	}
+	sort.Strings(origins)
+	return strings.Join(origins, ",")
+}
+
+// union returns the union of the given taints, keeping the taint of the first
+// occurrence of each origin.
+func union(tss ...taints) taints {
+	var u taints
+	for _, ts := range tss {
+		for origin, t := range ts {
+			if u == nil {
+				u = make(taints)
+			}
+			if _, ok := u[origin]; !ok {
+				u[origin] = t
+			}
+		}
+	}
+	return u
+}
+
+// withStep returns a copy of the taints with the given step appended to their
+// traces.
+func (ts taints) withStep(loc cc.Location, format string, args ...interface{}) taints {
+	if len(ts) == 0 {
+		return nil
+	}
+	c := make(taints, len(ts))
+	for origin, t := range ts {
+		c[origin] = t.withStep(loc, format, args...)
+	}
+	return c
+}
+
+// state is the dataflow fact of the taint analysis; the taint of variables,
+// by USR.
+type state map[string]taints
+
+// funcAnalysis is the taint analysis of a function definition.
+type funcAnalysis struct {
+	*analyzer
+	// Analyzed function.
+	f *callgraph.Func
+	// Record the summary of the function while visiting nodes.
+	record bool
+	// Summary of the function.
+	sum *summary
+}
+
+// summarize analyzes the given function definition, returning its summary.
+func (a *analyzer) summarize(f *callgraph.Func) *summary {
+	fa := &funcAnalysis{
+		analyzer: a,
+		f:        f,
+		sum: &summary{
+			params: make(map[int]taints),
+			sinks:  make(map[int][]*paramSink),
+		},
+	}
+	g := cfg.New(f.Def)
+	if g == nil {
+		return fa.sum
+	}
+	r := dataflow.Forward(g, fa)
+	r.Visit(func(n *cc.Node, in dataflow.Fact) {
+		fa.record = true
+		fa.exec(n, in.(state))
+		fa.record = false
+	})
+	// Taint written through pointer parameters.
+	if exit, ok := r.In[g.Exit]; ok {
+		for i, param := range params(f.Def) {
+			if param.Body.Type().CanonicalType().Kind() != clang.Type_Pointer {
+				continue
+			}
+			var written taints
+			for origin, t := range exit.(state)[param.Body.USR()] {
+				if t.param != i {
+					written = union(written, taints{origin: t})
+				}
+			}
+			if len(written) > 0 {
+				fa.sum.params[i] = written
+			}
+		}
+	}
+	return fa.sum
+}
+
+// Entry returns the taint holding at function entry; the taint of entry
+// function parameters and the symbolic taint of other parameters.
+func (fa *funcAnalysis) Entry() dataflow.Fact {
+	st := make(state)
+	for i, param := range params(fa.f.Def) {
+		name := param.Body.Spelling()
+		if fa.params[fa.f.Name][i] {
+			source := fmt.Sprintf("parameter %s of %s", name, fa.f.Name)
+			t := &label{origin: fmt.Sprintf("%s %s", param.Loc, name), param: -1, source: source}
+			st[param.Body.USR()] = taints{t.origin: t.withStep(param.Loc, "untrusted %s", source)}
+			continue
+		}
+		t := &label{origin: fmt.Sprintf("param %d", i), param: i, source: name}
+		st[param.Body.USR()] = taints{t.origin: t}
+	}
+	return st
+}
+
+// Join returns the union of the given taint states.
+func (fa *funcAnalysis) Join(a, b dataflow.Fact) dataflow.Fact {
+	x, y := a.(state), b.(state)
+	st := make(state, len(x))
+	for key, ts := range x {
+		st[key] = ts
+	}
+	for key, ts := range y {
+		st[key] = union(st[key], ts)
+	}
+	return st
+}
+
+// Equal reports whether the given taint states have the same origins.
+func (fa *funcAnalysis) Equal(a, b dataflow.Fact) bool {
+	x, y := a.(state), b.(state)
+	if len(x) != len(y) {
+		return false
+	}
+	for key, ts := range x {
+		if ts.String() != y[key].String() {
+			return false
+		}
+	}
+	return true
+}
+
+// Transfer returns the taint state after the given statement-level node.
+func (fa *funcAnalysis) Transfer(n *cc.Node, in dataflow.Fact) dataflow.Fact {
+	return fa.exec(n, in.(state))
+}
+
+// Branch removes the taint of integer variables bounded on the given edge of a
+// branch condition (e.g. n on the true edge of "n >= 0 && n < size"). Variables
+// are only bounded from above by untainted values (e.g. constants and sizeof
+// expressions) in the taint state before the branch. Signed variables must be
+// bounded from below by a non-negative constant in addition to being bounded
+// from above, unless compared as unsigned values.
+func (fa *funcAnalysis) Branch(cond *cc.Node, taken bool, out dataflow.Fact) dataflow.Fact {
+	st := out.(state)
+	bounded := fa.boundedVars(cond, taken, st)
+	if len(bounded) == 0 {
+		return st
+	}
+	c := make(state, len(st))
+	for key, ts := range st {
+		if !bounded[key] {
+			c[key] = ts
+		}
+	}
+	return c
+}
+
+// bounds is a bitfield of the bounds of an integer variable.
+type bounds uint8
+
+// Bounds of integer variables.
+const (
+	// Bounded from above.
+	boundUpper bounds = 1 << iota
+	// Bounded from below by a non-negative constant.
+	boundLower
+)
+
+// boundedVars returns the USRs of integer variables bounded from above and
+// below on the given edge of the branch condition, in the given taint state.
+func (fa *funcAnalysis) boundedVars(cond *cc.Node, taken bool, st state) map[string]bool {
+	var bounded map[string]bool
+	for key, b := range fa.varBounds(cond, taken, st) {
+		if b == boundUpper|boundLower {
+			if bounded == nil {
+				bounded = make(map[string]bool)
+			}
+			bounded[key] = true
+		}
+	}
+	return bounded
+}
+
+// varBounds returns the bounds of integer variables, by USR, on the given edge
+// of the branch condition, in the given taint state.
+func (fa *funcAnalysis) varBounds(cond *cc.Node, taken bool, st state) map[string]bounds {
+	cond = stripCasts(cond)
+	if cond.Body.Kind() != clang.Cursor_BinaryOperator || len(cond.Children) != 2 {
+		return nil
+	}
+	op := cond.Operator()
+	switch {
+	case op == "&&" && taken, op == "||" && !taken:
+		bs := fa.varBounds(cond.Children[0], taken, st)
+		for key, b := range fa.varBounds(cond.Children[1], taken, st) {
+			if bs == nil {
+				bs = make(map[string]bounds)
+			}
+			bs[key] |= b
+		}
+		return bs
+	}
+	// Index of the operand bounded from above; the other operand is bounded
+	// from below.
+	var upper int
+	switch op {
+	case "<", "<=":
+		// x < n bounds x from above when taken; n < x when not taken.
+		if !taken {
+			upper = 1
+		}
+	case ">", ">=":
+		// n > x bounds x from above when taken; x > n when not taken.
+		if taken {
+			upper = 1
+		}
+	default:
+		return nil
+	}
+	// Minimum value of the constant bounding a variable from below; the bound
+	// is strict (x > c) for "<" and ">" when taken, and for "<=" and ">=" when
+	// not taken.
+	var min int64
+	if (op == "<" || op == ">") == taken {
+		min = -1
+	}
+	var bs map[string]bounds
+	for i, operand := range cond.Children {
+		v := stripCasts(operand)
+		if v.Body.Kind() != clang.Cursor_DeclRefExpr || !isIntegerType(v.Body.Type()) {
+			continue
+		}
+		var b bounds
+		if i == upper {
+			if len(fa.eval(cond.Children[1-upper], st)) > 0 {
+				// Bounded from above by a tainted value (e.g. a length read
+				// from input).
+				continue
+			}
+			b = boundUpper
+			// Unsigned variables and variables compared as unsigned values
+			// (e.g. "n < sizeof(buf)") are non-negative.
+			if isUnsignedType(v.Body.Type()) || isUnsignedType(operand.Body.Type()) {
+				b |= boundLower
+			}
+		} else if c, ok := intConstant(cond.Children[upper]); ok && c >= min {
+			b = boundLower
+		}
+		if b != 0 {
+			if bs == nil {
+				bs = make(map[string]bounds)
+			}
+			bs[varKey(v)] |= b
+		}
+	}
+	return bs
+}
+
+// exec returns the taint state after the given statement-level node. Sinks
+// reached by tainted values are recorded in the summary when visiting nodes.
+func (fa *funcAnalysis) exec(n *cc.Node, in state) state {
+	st := make(state, len(in))
+	for key, ts := range in {
+		st[key] = ts
+	}
+	fa.effects(n, st)
+	return st
+}
+
+// effects applies the effects of the given node and its children on the taint
+// state, in evaluation order.
+func (fa *funcAnalysis) effects(n *cc.Node, st state) {
+	for _, child := range n.Children {
+		if isStmt(child) {
+			// Nested statements are separate nodes of the control flow graph.
+			continue
+		}
+		fa.effects(child, st)
+	}
+	switch n.Body.Kind() {
+	case clang.Cursor_BinaryOperator:
+		if n.Operator() != "=" || len(n.Children) != 2 {
+			return
+		}
+		lhs := stripCasts(n.Children[0])
+		v := baseVar(lhs)
+		if v == nil {
+			return
+		}
+		ts := fa.eval(n.Children[1], st).withStep(n.Loc, "assigned to %s", lhs.Body.Spelling())
+		if lhs == v {
+			// Strong update of the variable.
+			st[varKey(v)] = ts
+		} else {
+			st[varKey(v)] = union(st[varKey(v)], ts)
+		}
+	case clang.Cursor_CompoundAssignOperator:
+		if len(n.Children) != 2 {
+			return
+		}
+		if v := baseVar(n.Children[0]); v != nil {
+			ts := fa.eval(n.Children[1], st).withStep(n.Loc, "assigned to %s", v.Body.Spelling())
+			st[varKey(v)] = union(st[varKey(v)], ts)
+		}
+	case clang.Cursor_VarDecl:
+		if len(n.Children) == 0 {
+			return
+		}
+		init := n.Children[len(n.Children)-1]
+		if init.Body.Kind().IsExpression() {
+			st[n.Body.USR()] = fa.eval(init, st).withStep(n.Loc, "assigned to %s", n.Body.Spelling())
+		}
+	case clang.Cursor_ReturnStmt:
+		if fa.record && len(n.Children) == 1 {
+			fa.sum.ret = union(fa.sum.ret, fa.eval(n.Children[0], st).withStep(n.Loc, "returned by %s", fa.f.Name))
+		}
+	case clang.Cursor_ArraySubscriptExpr:
+		if fa.record && fa.arrayIndex && len(n.Children) == 2 {
+			index := n.Children[1]
+			if !isIntegerType(index.Body.Type()) {
+				// Reversed subscript (e.g. "i[buf]").
+				index = n.Children[0]
+			}
+			fa.sink(n, index, fa.eval(index, st), "array index", "array index")
+		}
+	case clang.Cursor_CallExpr:
+		fa.call(n, st)
+	}
+}
+
+// call applies the effects of the given call expression on the taint state.
+func (fa *funcAnalysis) call(call *cc.Node, st state) {
+	callee := call.Body.Referenced()
+	if callee.IsNull() || len(call.Children) == 0 {
+		return
+	}
+	name := callee.Spelling()
+	args := call.Children[1:]
+	variadic := callee.IsVariadic()
+	// Untrusted data written through arguments of source functions.
+	if src, ok := fa.sources[name]; ok {
+		for _, i := range argIndices(src.Args, len(args), variadic) {
+			if v := baseVar(args[i]); v != nil {
+				t := &label{origin: fmt.Sprintf("%s %s", call.Loc, name), param: -1, source: name}
+				t = t.withStep(call.Loc, "untrusted data written to %s by %s", v.Body.Spelling(), name)
+				st[varKey(v)] = union(st[varKey(v)], taints{t.origin: t})
+			}
+		}
+	}
+	// Data copied by propagation functions.
+	for _, p := range fa.propagators[name] {
+		if p.To >= len(args) {
+			continue
+		}
+		v := baseVar(args[p.To])
+		if v == nil {
+			continue
+		}
+		var ts taints
+		for _, i := range argIndices(p.From, len(args), variadic) {
+			ts = union(ts, fa.eval(args[i], st))
+		}
+		st[varKey(v)] = union(st[varKey(v)], ts.withStep(call.Loc, "copied to %s by %s", v.Body.Spelling(), name))
+	}
+	// Sink arguments.
+	if fa.record {
+		for _, sink := range fa.sinks[name] {
+			for _, i := range sink.Args {
+				if i < len(args) {
+					desc := fmt.Sprintf("argument %d of %s", i+1, name)
+					fa.sink(call, args[i], fa.eval(args[i], st), sink.Kind, desc)
+				}
+			}
+		}
+	}
+	f, ok := fa.graph.Funcs[callee.USR()]
+	if !ok {
+		return
+	}
+	s, ok := fa.summaries[f]
+	if !ok {
+		return
+	}
+	// Taint written through pointer parameters of the callee.
+	for i, written := range s.params {
+		if i >= len(args) {
+			continue
+		}
+		if v := baseVar(args[i]); v != nil {
+			ts := fa.mapTaints(written, args, st).withStep(call.Loc, "written to %s by %s", v.Body.Spelling(), name)
+			st[varKey(v)] = union(st[varKey(v)], ts)
+		}
+	}
+	// Sinks reached by parameters of the callee.
+	if fa.record {
+		for i, sinks := range s.sinks {
+			if i >= len(args) {
+				continue
+			}
+			ts := fa.eval(args[i], st).withStep(args[i].Loc, "passed to %s as argument %d", name, i+1)
+			for _, ps := range sinks {
+				for _, t := range ts {
+					t = &label{origin: t.origin, param: t.param, source: t.source, trace: append(append([]*Step(nil), t.trace...), ps.trace...)}
+					fa.reach(t, ps.site, ps.kind, ps.sink)
+				}
+			}
+		}
+	}
+}
+
+// mapTaints maps the given taints of a callee summary to the call site with
+// the given arguments; the taints of callee parameters are replaced by the
+// taints of the corresponding arguments.
+func (fa *funcAnalysis) mapTaints(ts taints, args []*cc.Node, st state) taints {
+	var mapped taints
+	for origin, t := range ts {
+		if t.param < 0 {
+			mapped = union(mapped, taints{origin: t})
+			continue
+		}
+		if t.param >= len(args) {
+			continue
+		}
+		for argOrigin, at := range fa.eval(args[t.param], st) {
+			c := *at
+			c.trace = append(append([]*Step(nil), at.trace...), t.trace...)
+			mapped = union(mapped, taints{argOrigin: &c})
+		}
+	}
+	return mapped
+}
+
+// sink records the flow of the given taints of an expression into a sink.
+func (fa *funcAnalysis) sink(site, expr *cc.Node, ts taints, kind, desc string) {
+	for _, t := range ts {
+		fa.reach(t.withStep(expr.Loc, "reaches %s", desc), site, kind, desc)
+	}
+}
+
+// reach records that the given taint reaches a sink; as a flow if the origin
+// is a source, or as a parameter sink of the summary if the origin is a
+// parameter.
+func (fa *funcAnalysis) reach(t *label, site *cc.Node, kind, desc string) {
+	if t.param >= 0 {
+		ps := &paramSink{kind: kind, sink: desc, site: site, trace: t.trace}
+		fa.sum.sinks[t.param] = append(fa.sum.sinks[t.param], ps)
+		return
+	}
+	flow := &Flow{
+		Source: t.source,
+		Kind:   kind,
+		Sink:   desc,
+		Site:   site,
+		Trace:  t.trace,
+	}
+	fa.sum.flows = append(fa.sum.flows, flow)
+}
+
+// eval returns the taint of the value of the given expression.
+func (fa *funcAnalysis) eval(n *cc.Node, st state) taints {
+	n = stripCasts(n)
+	switch n.Body.Kind() {
+	case clang.Cursor_DeclRefExpr:
+		return st[n.Body.Referenced().USR()]
+	case clang.Cursor_UnaryExpr, clang.Cursor_IntegerLiteral, clang.Cursor_FloatingLiteral, clang.Cursor_CharacterLiteral, clang.Cursor_StringLiteral:
+		// sizeof and alignof expressions, and literals.
+		return nil
+	case clang.Cursor_BinaryOperator:
+		if len(n.Children) == 2 {
+			switch n.Operator() {
+			case "=", ",":
+				return fa.eval(n.Children[1], st)
+			}
+		}
+	case clang.Cursor_CallExpr:
+		return fa.evalCall(n, st)
+	}
+	var ts taints
+	for _, child := range n.Children {
+		if !isStmt(child) {
+			ts = union(ts, fa.eval(child, st))
+		}
+	}
+	return ts
+}
+
+// evalCall returns the taint of the return value of the given call
+// expression.
+func (fa *funcAnalysis) evalCall(call *cc.Node, st state) taints {
+	callee := call.Body.Referenced()
+	if callee.IsNull() || len(call.Children) == 0 {
+		return nil
+	}
+	name := callee.Spelling()
+	args := call.Children[1:]
+	if fa.sanitizers[name] {
+		return nil
+	}
+	if src, ok := fa.sources[name]; ok && src.Return {
+		t := &label{origin: fmt.Sprintf("%s %s", call.Loc, name), param: -1, source: name}
+		return taints{t.origin: t.withStep(call.Loc, "untrusted data returned by %s", name)}
+	}
+	if f, ok := fa.graph.Funcs[callee.USR()]; ok && f.Def != nil {
+		if s, ok := fa.summaries[f]; ok {
+			return fa.mapTaints(s.ret, args, st).withStep(call.Loc, "returned by call to %s", name)
+		}
+	}
+	// The return value of functions without summaries (e.g. library functions
+	// such as atoi or strdup) is derived from their arguments.
+	var ts taints
+	for _, arg := range args {
+		ts = union(ts, fa.eval(arg, st))
+	}
+	return ts
+}
+
+// params returns the parameters of the given function definition.
+func params(fn *cc.Node) []*cc.Node {
+	var ps []*cc.Node
+	for _, child := range fn.Children {
+		if child.Body.Kind() == clang.Cursor_ParmDecl {
+			ps = append(ps, child)
+		}
+	}
+	return ps
+}
+
+// argIndices returns the valid argument indices of the given indices, for a
+// call with the given number of arguments. The last index of a variadic
+// function also covers the subsequent arguments.
+func argIndices(indices []int, nargs int, variadic bool) []int {
+	var valid []int
+	for _, i := range indices {
+		if i < nargs {
+			valid = append(valid, i)
+		}
+	}
+	if variadic && len(indices) > 0 {
+		for i := indices[len(indices)-1] + 1; i < nargs; i++ {
+			valid = append(valid, i)
+		}
+	}
+	return valid
+}
+
+// baseVar returns the reference to the variable whose value or pointed-to data
+// is designated by the given expression (e.g. buf of "&buf[i]"); or nil if not
+// present.
+func baseVar(n *cc.Node) *cc.Node {
+	for {
+		n = stripCasts(n)
+		switch n.Body.Kind() {
+		case clang.Cursor_DeclRefExpr:
+			switch n.Body.Referenced().Kind() {
+			case clang.Cursor_VarDecl, clang.Cursor_ParmDecl:
+				return n
+			}
+			return nil
+		case clang.Cursor_UnaryOperator, clang.Cursor_ArraySubscriptExpr, clang.Cursor_MemberRefExpr:
+			if len(n.Children) == 0 {
+				return nil
+			}
+			n = n.Children[0]
+		default:
+			return nil
+		}
+	}
+}
+
+// varKey returns the key of the variable of the given variable reference.
+func varKey(ref *cc.Node) string {
+	return ref.Body.Referenced().USR()
+}
+
+// isStmt reports whether the given node is a statement.
+func isStmt(n *cc.Node) bool {
+	return n.Body.Kind().IsStatement()
+}
+
+// stripCasts returns the given expression with implicit and explicit casts and
+// parentheses removed.
+func stripCasts(n *cc.Node) *cc.Node {
+	for {
+		switch n.Body.Kind() {
+		case clang.Cursor_UnexposedExpr, clang.Cursor_ParenExpr:
+			if len(n.Children) != 1 {
+				return n
+			}
+			n = n.Children[0]
+		case clang.Cursor_CStyleCastExpr:
+			if len(n.Children) == 0 {
+				return n
+			}
+			// The operand of a cast is its last child; preceded by type
+			// references.
+			n = n.Children[len(n.Children)-1]
+		default:
+			return n
+		}
+	}
+}
+
+// isIntegerType reports whether the given type is an integer type.
+func isIntegerType(t clang.Type) bool {
+	switch t.CanonicalType().Kind() {
+	case clang.Type_Bool, clang.Type_Char_U, clang.Type_UChar, clang.Type_Char16, clang.Type_Char32, clang.Type_UShort, clang.Type_UInt, clang.Type_ULong, clang.Type_ULongLong, clang.Type_UInt128, clang.Type_Char_S, clang.Type_SChar, clang.Type_WChar, clang.Type_Short, clang.Type_Int, clang.Type_Long, clang.Type_LongLong, clang.Type_Int128, clang.Type_Enum:
+		return true
+	}
+	return false
+}
+
+// isUnsignedType reports whether the given type is an unsigned integer type.
+func isUnsignedType(t clang.Type) bool {
+	switch t.CanonicalType().Kind() {
+	case clang.Type_Bool, clang.Type_Char_U, clang.Type_UChar, clang.Type_Char16, clang.Type_Char32, clang.Type_UShort, clang.Type_UInt, clang.Type_ULong, clang.Type_ULongLong, clang.Type_UInt128:
+		return true
+	}
+	return false
+}
+
+// intConstant returns the value of the given expression, if it is an integer
+// literal, optionally negated.
+func intConstant(expr *cc.Node) (int64, bool) {
+	expr = stripCasts(expr)
+	neg := false
+	if expr.Body.Kind() == clang.Cursor_UnaryOperator && expr.Operator() == "-" && len(expr.Children) == 1 {
+		neg = true
+		expr = stripCasts(expr.Children[0])
+	}
+	if expr.Body.Kind() != clang.Cursor_IntegerLiteral {
+		return 0, false
+	}
+	toks := expr.Tokens()
+	if len(toks) == 0 {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(strings.TrimRight(toks[0], "uUlL"), 0, 64)
+	if err != nil {
+		return 0, false
+	}
+	if neg {
+		v = -v
+	}
+	return v, true
+}
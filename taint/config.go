@@ -0,0 +1,124 @@
+package taint
+
+// Config is the configuration of the taint analysis.
+type Config struct {
+	// Functions returning or writing untrusted data.
+	Sources []*Source `json:"sources"`
+	// Parameters of entry functions holding untrusted data.
+	Params []*Param `json:"params"`
+	// Function arguments which must not receive untrusted data.
+	Sinks []*Sink `json:"sinks"`
+	// Functions copying data between their arguments.
+	Propagators []*Propagator `json:"propagators"`
+	// Functions returning sanitized data (e.g. validated paths).
+	Sanitizers []string `json:"sanitizers"`
+	// Report untrusted array indices.
+	ArrayIndex bool `json:"array_index"`
+}
+
+// Source is a function returning or writing untrusted data.
+type Source struct {
+	// Function name.
+	Func string `json:"func"`
+	// The return value of the function is untrusted.
+	Return bool `json:"return"`
+	// Indices of pointer arguments through which untrusted data is written.
+	// The last index of a variadic function also covers the subsequent
+	// arguments.
+	Args []int `json:"args"`
+}
+
+// Param is a parameter of an entry function holding untrusted data.
+type Param struct {
+	// Function name.
+	Func string `json:"func"`
+	// Parameter index.
+	Param int `json:"param"`
+}
+
+// Sink is a function whose arguments must not receive untrusted data.
+type Sink struct {
+	// Function name.
+	Func string `json:"func"`
+	// Indices of sink arguments.
+	Args []int `json:"args"`
+	// Kind of sink (e.g. "command", "format string").
+	Kind string `json:"kind"`
+}
+
+// Propagator is a function copying data from source arguments to a
+// destination argument (e.g. strcpy).
+type Propagator struct {
+	// Function name.
+	Func string `json:"func"`
+	// Indices of source arguments. The last index of a variadic function also
+	// covers the subsequent arguments.
+	From []int `json:"from"`
+	// Index of the destination argument.
+	To int `json:"to"`
+}
+
+// DefaultConfig is the default configuration of the taint analysis.
+var DefaultConfig = &Config{
+	Sources: []*Source{
+		{Func: "read", Args: []int{1}},
+		{Func: "pread", Args: []int{1}},
+		{Func: "recv", Args: []int{1}},
+		{Func: "recvfrom", Args: []int{1}},
+		{Func: "recvmsg", Args: []int{1}},
+		{Func: "fread", Args: []int{0}},
+		{Func: "fgets", Args: []int{0}, Return: true},
+		{Func: "gets", Args: []int{0}, Return: true},
+		{Func: "getline", Args: []int{0}},
+		{Func: "getdelim", Args: []int{0}},
+		{Func: "getc", Return: true},
+		{Func: "fgetc", Return: true},
+		{Func: "getchar", Return: true},
+		{Func: "scanf", Args: []int{1}},
+		{Func: "fscanf", Args: []int{2}},
+		{Func: "getenv", Return: true},
+		{Func: "secure_getenv", Return: true},
+	},
+	Params: []*Param{
+		{Func: "main", Param: 1},
+		{Func: "main", Param: 2},
+	},
+	Sinks: []*Sink{
+		{Func: "system", Args: []int{0}, Kind: "command"},
+		{Func: "popen", Args: []int{0}, Kind: "command"},
+		{Func: "execl", Args: []int{0}, Kind: "command"},
+		{Func: "execlp", Args: []int{0}, Kind: "command"},
+		{Func: "execv", Args: []int{0}, Kind: "command"},
+		{Func: "execvp", Args: []int{0}, Kind: "command"},
+		{Func: "strcpy", Args: []int{1}, Kind: "unbounded copy"},
+		{Func: "strcat", Args: []int{1}, Kind: "unbounded copy"},
+		{Func: "strncpy", Args: []int{2}, Kind: "copy size"},
+		{Func: "strncat", Args: []int{2}, Kind: "copy size"},
+		{Func: "memcpy", Args: []int{2}, Kind: "copy size"},
+		{Func: "memmove", Args: []int{2}, Kind: "copy size"},
+		{Func: "memset", Args: []int{2}, Kind: "copy size"},
+		{Func: "malloc", Args: []int{0}, Kind: "allocation size"},
+		{Func: "alloca", Args: []int{0}, Kind: "allocation size"},
+		{Func: "printf", Args: []int{0}, Kind: "format string"},
+		{Func: "fprintf", Args: []int{1}, Kind: "format string"},
+		{Func: "dprintf", Args: []int{1}, Kind: "format string"},
+		{Func: "sprintf", Args: []int{1}, Kind: "format string"},
+		{Func: "snprintf", Args: []int{2}, Kind: "format string"},
+		{Func: "vprintf", Args: []int{0}, Kind: "format string"},
+		{Func: "vfprintf", Args: []int{1}, Kind: "format string"},
+		{Func: "vsprintf", Args: []int{1}, Kind: "format string"},
+		{Func: "vsnprintf", Args: []int{2}, Kind: "format string"},
+		{Func: "syslog", Args: []int{1}, Kind: "format string"},
+	},
+	Propagators: []*Propagator{
+		{Func: "strcpy", From: []int{1}, To: 0},
+		{Func: "strncpy", From: []int{1}, To: 0},
+		{Func: "strcat", From: []int{1}, To: 0},
+		{Func: "strncat", From: []int{1}, To: 0},
+		{Func: "memcpy", From: []int{1}, To: 0},
+		{Func: "memmove", From: []int{1}, To: 0},
+		{Func: "sprintf", From: []int{2}, To: 0},
+		{Func: "snprintf", From: []int{3}, To: 0},
+	},
+	ArrayIndex: true,
+}
@@ -0,0 +1,219 @@
+// Package taint implements an interprocedural taint analysis of untrusted
+// input in C programs.
+//
+// Data returned or written by source functions (e.g. read, recv, getenv) and
+// the parameters of entry functions (e.g. argv of main) is untrusted. Taint
+// propagates through assignments, expressions, propagation functions (e.g.
+// strcpy) and the arguments and return values of called functions, and is
+// removed by sanitizer functions and by bounds checks of integer variables
+// (e.g. "if (n >= 0 && n < size)"); signed variables must be bounded from
+// below by a non-negative constant as well as from above. Flows of untrusted
+// data into sink arguments (e.g. the command of system) and array indices are
+// reported.
+//
+// Taint is tracked per variable, without distinguishing the value of a
+// variable from the data it points to. Within functions, the analysis is
+// flow-sensitive, based on the dataflow framework of the cc/dataflow package.
+// Across functions, summaries of how the taint of parameters flows into
+// return values, pointer parameters and sinks are computed bottom-up over the
+// call graph. Calls through function pointers are not followed.
+package taint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/mewspring/cc/callgraph"
+)
+
+// Flow is a flow of untrusted data from a source to a sink.
+type Flow struct {
+	// Source of the untrusted data (e.g. "read", "parameter argv of main").
+	Source string
+	// Kind of sink (e.g. "command", "array index").
+	Kind string
+	// Sink description (e.g. "argument 1 of system").
+	Sink string
+	// Sink node; call or array subscript expression.
+	Site *cc.Node
+	// Steps of the flow, from source to sink.
+	Trace []*Step
+}
+
+// Step is a step of the flow of untrusted data.
+type Step struct {
+	// Source location of the step.
+	Loc cc.Location
+	// Step description.
+	Message string
+}
+
+// maxSummaryIterations is the maximum number of iterations of the summary
+// computation of recursive functions.
+const maxSummaryIterations = 10
+
+// Analyze analyzes the program consisting of the given translation units,
+// returning the flows of untrusted data to sinks, sorted by sink location.
+// The default configuration is used if config is nil.
+func Analyze(files []*cc.File, config *Config) []*Flow {
+	if config == nil {
+		config = DefaultConfig
+	}
+	a := newAnalyzer(config, callgraph.New(files...))
+	// Strongly connected components are ordered callees first, so the
+	// summaries of callees are computed before their callers.
+	for _, scc := range a.graph.SCCs() {
+		for i := 0; i < maxSummaryIterations; i++ {
+			changed := false
+			for _, f := range scc {
+				if f.Def == nil {
+					continue
+				}
+				s := a.summarize(f)
+				if prev, ok := a.summaries[f]; !ok || prev.signature() != s.signature() {
+					changed = true
+				}
+				a.summaries[f] = s
+			}
+			if !changed || len(scc) == 1 && !recursive(scc[0]) {
+				break
+			}
+		}
+	}
+	var flows []*Flow
+	seen := make(map[string]bool)
+	for _, f := range a.graph.Sorted() {
+		s, ok := a.summaries[f]
+		if !ok {
+			continue
+		}
+		for _, flow := range s.flows {
+			key := fmt.Sprintf("%s %s %s %s", flow.Site.Loc, flow.Sink, flow.Source, flow.Trace[0].Loc)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			flows = append(flows, flow)
+		}
+	}
+	sort.SliceStable(flows, func(i, j int) bool {
+		a, b := flows[i].Site.Loc, flows[j].Site.Loc
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return flows
+}
+
+// recursive reports whether the given function calls itself directly.
+func recursive(f *callgraph.Func) bool {
+	for _, call := range f.Callees {
+		if call.Callee == f {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzer tracks the state of the taint analysis.
+type analyzer struct {
+	// Call graph of the program.
+	graph *callgraph.Graph
+	// Function summaries.
+	summaries map[*callgraph.Func]*summary
+	// Sources by function name.
+	sources map[string]*Source
+	// Entry function parameter indices, by function name.
+	params map[string]map[int]bool
+	// Sinks by function name.
+	sinks map[string][]*Sink
+	// Propagators by function name.
+	propagators map[string][]*Propagator
+	// Sanitizer function names.
+	sanitizers map[string]bool
+	// Report untrusted array indices.
+	arrayIndex bool
+}
+
+// newAnalyzer returns a new taint analyzer of the given call graph.
+func newAnalyzer(config *Config, graph *callgraph.Graph) *analyzer {
+	a := &analyzer{
+		graph:       graph,
+		summaries:   make(map[*callgraph.Func]*summary),
+		sources:     make(map[string]*Source),
+		params:      make(map[string]map[int]bool),
+		sinks:       make(map[string][]*Sink),
+		propagators: make(map[string][]*Propagator),
+		sanitizers:  make(map[string]bool),
+		arrayIndex:  config.ArrayIndex,
+	}
+	for _, src := range config.Sources {
+		a.sources[src.Func] = src
+	}
+	for _, p := range config.Params {
+		if a.params[p.Func] == nil {
+			a.params[p.Func] = make(map[int]bool)
+		}
+		a.params[p.Func][p.Param] = true
+	}
+	for _, sink := range config.Sinks {
+		a.sinks[sink.Func] = append(a.sinks[sink.Func], sink)
+	}
+	for _, p := range config.Propagators {
+		a.propagators[p.Func] = append(a.propagators[p.Func], p)
+	}
+	for _, name := range config.Sanitizers {
+		a.sanitizers[name] = true
+	}
+	return a
+}
+
+// summary is a summary of the taint flow of a function.
+type summary struct {
+	// Taint of the return value.
+	ret taints
+	// Taint written through pointer parameters, by parameter index.
+	params map[int]taints
+	// Sinks reached by parameters, by parameter index.
+	sinks map[int][]*paramSink
+	// Flows of untrusted data to sinks within the function or its callees.
+	flows []*Flow
+}
+
+// paramSink is a sink reached by a parameter of a function.
+type paramSink struct {
+	// Kind of sink.
+	kind string
+	// Sink description.
+	sink string
+	// Sink node.
+	site *cc.Node
+	// Steps of the flow, from the parameter to the sink.
+	trace []*Step
+}
+
+// signature returns a string identifying the taint flow of the summary, used
+// to detect the fixed point of summaries of recursive functions.
+func (s *summary) signature() string {
+	var parts []string
+	parts = append(parts, "ret:"+s.ret.String())
+	for i, ts := range s.params {
+		parts = append(parts, fmt.Sprintf("param %d:%s", i, ts))
+	}
+	for i, sinks := range s.sinks {
+		for _, ps := range sinks {
+			parts = append(parts, fmt.Sprintf("sink %d:%s@%s", i, ps.sink, ps.site.Loc))
+		}
+	}
+	for _, flow := range s.flows {
+		parts = append(parts, fmt.Sprintf("flow %s:%s@%s", flow.Source, flow.Sink, flow.Site.Loc))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ";")
+}
@@ -0,0 +1,51 @@
+package taint
+
+import (
+	"testing"
+
+	"github.com/mewspring/cc"
+)
+
+func TestAnalyzeBounds(t *testing.T) {
+	config := &Config{
+		Sources:    []*Source{{Func: "input", Return: true}},
+		ArrayIndex: true,
+	}
+	golden := []struct {
+		// Body of function f, with the untrusted variable n of the given type.
+		typ  string
+		body string
+		// Number of flows.
+		want int
+	}{
+		{typ: "int", body: "return buf[n];", want: 1},
+		{typ: "int", body: "if (n < 16) return buf[n]; return 0;", want: 1},
+		{typ: "int", body: "if (n >= 0 && n < 16) return buf[n]; return 0;", want: 0},
+		{typ: "int", body: "if (16 > n && 0 <= n) return buf[n]; return 0;", want: 0},
+		{typ: "int", body: "if (n > -1 && n < 16) return buf[n]; return 0;", want: 0},
+		{typ: "int", body: "if (n > -2 && n < 16) return buf[n]; return 0;", want: 1},
+		{typ: "int", body: "if (n >= -1 && n < 16) return buf[n]; return 0;", want: 1},
+		{typ: "int", body: "if (n < 0 || n >= 16) return 0; return buf[n];", want: 0},
+		{typ: "int", body: "if (n >= 16) return 0; return buf[n];", want: 1},
+		{typ: "int", body: "if (n < sizeof(buf) / sizeof(buf[0])) return buf[n]; return 0;", want: 0},
+		{typ: "unsigned", body: "if (n < 16) return buf[n]; return 0;", want: 0},
+		{typ: "unsigned", body: "if (n > 16) return buf[n]; return 0;", want: 1},
+		// Bounded by untainted and tainted variables.
+		{typ: "unsigned", body: "unsigned len = 16; if (n < len) return buf[n]; return 0;", want: 0},
+		{typ: "unsigned", body: "unsigned len = input(); if (n < len) return buf[n]; return 0;", want: 1},
+		{typ: "int", body: "int len = input(); if (n >= 0 && n < len) return buf[n]; return 0;", want: 1},
+	}
+	for _, g := range golden {
+		src := "int input(void);\nint buf[16];\nint f(void) {\n\t" + g.typ + " n = input();\n\t" + g.body + "\n}\n"
+		file, err := cc.ParseSource("taint.c", src)
+		if err != nil {
+			t.Errorf("unable to parse %q; %+v", src, err)
+			continue
+		}
+		flows := Analyze([]*cc.File{file}, config)
+		if got := len(flows); got != g.want {
+			t.Errorf("%s n; %s: number of flows mismatch; expected %d, got %d", g.typ, g.body, g.want, got)
+		}
+		file.Close()
+	}
+}
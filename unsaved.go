@@ -0,0 +1,69 @@
+package cc
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/pkg/errors"
+)
+
+// cUnsavedFile mirrors the memory layout of struct CXUnsavedFile, which is
+// wrapped by clang.UnsavedFile.
+//
+// clang.NewUnsavedFile allocates C strings which are never released, and
+// clang.UnsavedFile provides no means of releasing them. Unsaved files are
+// therefore constructed from C strings owned by this package, by converting a
+// cUnsavedFile into a clang.UnsavedFile. As clang.UnsavedFile is opaque, the
+// memory layout is verified for each unsaved file constructed.
+type cUnsavedFile struct {
+	filename *C.char
+	contents *C.char
+	length   C.ulong
+}
+
+// unsavedFile is an unsaved file backed by C strings owned by this package.
+type unsavedFile struct {
+	c cUnsavedFile
+	// File name and contents length, for verification of the memory layout.
+	filename string
+	length   int
+}
+
+// newUnsavedFile returns a new unsaved file with the given file name and
+// contents. The caller is responsible for disposing of the unsaved file after
+// use.
+func newUnsavedFile(filename, contents string) *unsavedFile {
+	return &unsavedFile{
+		c: cUnsavedFile{
+			filename: C.CString(filename),
+			contents: C.CString(contents),
+			length:   C.ulong(len(contents)),
+		},
+		filename: filename,
+		length:   len(contents),
+	}
+}
+
+// clangUnsavedFile returns the clang.UnsavedFile corresponding to the unsaved
+// file, or an error if the memory layout of clang.UnsavedFile does not match
+// struct CXUnsavedFile.
+func (uf *unsavedFile) clangUnsavedFile() (clang.UnsavedFile, error) {
+	if unsafe.Sizeof(clang.UnsavedFile{}) != unsafe.Sizeof(cUnsavedFile{}) {
+		return clang.UnsavedFile{}, errors.Errorf("size mismatch between clang.UnsavedFile (%d bytes) and struct CXUnsavedFile (%d bytes)", unsafe.Sizeof(clang.UnsavedFile{}), unsafe.Sizeof(cUnsavedFile{}))
+	}
+	u := *(*clang.UnsavedFile)(unsafe.Pointer(&uf.c))
+	if u.Filename() != uf.filename || u.Length() != uint64(uf.length) {
+		return clang.UnsavedFile{}, errors.New("memory layout mismatch between clang.UnsavedFile and struct CXUnsavedFile")
+	}
+	return u, nil
+}
+
+// dispose releases the C strings of the unsaved file.
+func (uf *unsavedFile) dispose() {
+	C.free(unsafe.Pointer(uf.c.filename))
+	C.free(unsafe.Pointer(uf.c.contents))
+	uf.c.filename, uf.c.contents = nil, nil
+}
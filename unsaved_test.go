@@ -0,0 +1,25 @@
+package cc
+
+import "testing"
+
+func TestUnsavedFile(t *testing.T) {
+	const (
+		filename = "foo.c"
+		contents = "int x;\n"
+	)
+	uf := newUnsavedFile(filename, contents)
+	defer uf.dispose()
+	u, err := uf.clangUnsavedFile()
+	if err != nil {
+		t.Fatalf("unable to convert unsaved file; %+v", err)
+	}
+	if got := u.Filename(); got != filename {
+		t.Errorf("file name mismatch; expected %q, got %q", filename, got)
+	}
+	if got := u.Contents(); got != contents {
+		t.Errorf("contents mismatch; expected %q, got %q", contents, got)
+	}
+	if got := u.Length(); got != uint64(len(contents)) {
+		t.Errorf("length mismatch; expected %d, got %d", len(contents), got)
+	}
+}
@@ -0,0 +1,87 @@
+package variability
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/mewspring/cc"
+	"github.com/pkg/errors"
+)
+
+// Branch is a branch of a conditional preprocessing directive (e.g. the lines
+// between #ifdef FOO and #else).
+type Branch struct {
+	// Source file.
+	File string
+	// Line of the directive starting the branch (e.g. #if, #elif, #else).
+	Start uint32
+	// Line of the directive ending the branch (e.g. #elif, #else, #endif).
+	End uint32
+	// Directive starting the branch (e.g. "#ifdef FOO").
+	Cond string
+	// The branch contains code; i.e. lines other than blank lines, comments
+	// and directives other than #define and #include.
+	HasCode bool
+}
+
+// String returns a string representation of the branch.
+func (b *Branch) String() string {
+	return fmt.Sprintf("%s:%d-%d: %s", b.File, b.Start, b.End, b.Cond)
+}
+
+// ScanBranches scans the given source file for branches of conditional
+// preprocessing directives, in order of the end of each branch.
+func ScanBranches(path string) ([]*Branch, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var (
+		branches []*Branch
+		// Stack of open branches.
+		open []*Branch
+	)
+	// Line breaks of block comments are preserved, and thus line numbers.
+	lines := strings.Split(cc.StripComments(string(buf)), "\n")
+	for i, l := range lines {
+		line := uint32(i + 1)
+		l = strings.TrimSpace(l)
+		if !strings.HasPrefix(l, "#") {
+			if len(l) > 0 {
+				for _, b := range open {
+					b.HasCode = true
+				}
+			}
+			continue
+		}
+		directive := strings.TrimSpace(l[1:])
+		name := directive
+		if i := strings.IndexAny(directive, " \t("); i != -1 {
+			name = directive[:i]
+		}
+		switch name {
+		case "if", "ifdef", "ifndef":
+			open = append(open, &Branch{File: path, Start: line, Cond: "#" + directive})
+		case "elif", "else", "endif":
+			if len(open) == 0 {
+				// Unbalanced directive; ignore.
+				continue
+			}
+			b := open[len(open)-1]
+			b.End = line
+			branches = append(branches, b)
+			open = open[:len(open)-1]
+			if name != "endif" {
+				open = append(open, &Branch{File: path, Start: line, Cond: "#" + directive})
+			}
+		case "define", "include":
+			// Macro definitions and inclusions are code of enclosing branches;
+			// other directives (e.g. #undef, #pragma, #error) are not.
+			for _, b := range open {
+				b.HasCode = true
+			}
+		}
+	}
+	return branches, nil
+}
@@ -0,0 +1,195 @@
+// Package variability analyzes the variability of source files parsed under
+// several macro configurations.
+//
+// The same source file is parsed once per configuration. As preprocessing does
+// not change the position of tokens within source files, the resulting trees
+// are aligned by the kind and source location of declarations and statements.
+// Conditionally compiled code is located by scanning the source files for
+// conditional preprocessing directives; branches containing neither the start
+// nor the end of the extent of a node in any configuration are reported as
+// never compiled. Lines of skipped branches are never covered by the start or
+// end of an extent, whereas closing braces (e.g. "} else {") and continuation
+// lines of compiled branches are.
+package variability
+
+import (
+	"sort"
+
+	"github.com/go-clang/clang-v3.9/clang"
+	"github.com/mewspring/cc"
+)
+
+// Config is a macro configuration.
+type Config struct {
+	// Configuration name.
+	Name string
+	// Clang arguments of the configuration (e.g. "-DFOO", "-DBAR=1", "-UBAZ").
+	Args []string
+}
+
+// Result is the result of a variability analysis.
+type Result struct {
+	// Configurations, in analysis order.
+	Configs []*Config
+	// Declarations and statements present in some but not all
+	// configurations, sorted by location.
+	Variable []*Entity
+	// Declarations and statements present in all configurations, sorted by
+	// location.
+	Common []*Entity
+	// Conditionally compiled branches never compiled under any configuration,
+	// sorted by location.
+	Dead []*Branch
+}
+
+// Entity is a declaration or statement of a source file.
+type Entity struct {
+	// Node kind.
+	Kind string
+	// Name of declaration; or empty if statement.
+	Name string
+	// Source location.
+	Loc cc.Location
+	// Presence in each configuration, indexed by configuration.
+	Present []bool
+}
+
+// Configs returns the names of the configurations in which the entity is
+// present.
+func (e *Entity) Configs(configs []*Config) []string {
+	var names []string
+	for i, present := range e.Present {
+		if present {
+			names = append(names, configs[i].Name)
+		}
+	}
+	return names
+}
+
+// Analyze parses the given source file under each configuration and reports
+// which declarations and statements exist in which configurations. The given
+// Clang arguments are shared by all configurations. Declarations and
+// statements located in system headers are ignored.
+func Analyze(srcPath string, configs []*Config, clangArgs ...string) (*Result, error) {
+	entityFromKey := make(map[key]*Entity)
+	// Lines containing the start or end of the extent of a node in at least
+	// one configuration, indexed by file.
+	used := make(map[string]map[uint32]bool)
+	var err error
+	for i, config := range configs {
+		args := append(append(append([]string(nil), cc.PreprocessingRecordArgs...), clangArgs...), config.Args...)
+		file, e := cc.ParseFile(srcPath, args...)
+		if e != nil {
+			// Keep the first error but continue with the partial AST.
+			if err == nil {
+				err = e
+			}
+		}
+		cc.Walk(file.Root, func(n *cc.Node) {
+			if n == file.Root || len(n.Loc.File) == 0 || n.Body.Location().IsInSystemHeader() {
+				return
+			}
+			for _, loc := range extentLocs(n) {
+				if used[loc.File] == nil {
+					used[loc.File] = make(map[uint32]bool)
+				}
+				used[loc.File][loc.Line] = true
+			}
+			kind := n.Body.Kind()
+			if !kind.IsDeclaration() && !kind.IsStatement() && kind != clang.Cursor_MacroDefinition {
+				return
+			}
+			k := key{kind: kind.Spelling(), loc: n.Loc}
+			e, ok := entityFromKey[k]
+			if !ok {
+				e = &Entity{
+					Kind:    k.kind,
+					Loc:     n.Loc,
+					Present: make([]bool, len(configs)),
+				}
+				if kind.IsDeclaration() || kind == clang.Cursor_MacroDefinition {
+					e.Name = n.Body.Spelling()
+				}
+				entityFromKey[k] = e
+			}
+			e.Present[i] = true
+		})
+		file.Close()
+	}
+	r := &Result{Configs: configs}
+	for _, e := range entityFromKey {
+		all := true
+		for _, present := range e.Present {
+			all = all && present
+		}
+		if all {
+			r.Common = append(r.Common, e)
+		} else {
+			r.Variable = append(r.Variable, e)
+		}
+	}
+	sortEntities(r.Common)
+	sortEntities(r.Variable)
+	// Locate branches never compiled.
+	var paths []string
+	for path := range used {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		branches, e := ScanBranches(path)
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		for _, b := range branches {
+			if !b.HasCode {
+				continue
+			}
+			compiled := false
+			for line := b.Start + 1; line < b.End; line++ {
+				if used[path][line] {
+					compiled = true
+					break
+				}
+			}
+			if !compiled {
+				r.Dead = append(r.Dead, b)
+			}
+		}
+	}
+	return r, err
+}
+
+// extentLocs returns the start and end location of the extent of the given
+// node, and the location of the node.
+func extentLocs(n *cc.Node) []cc.Location {
+	start, end := n.Extent()
+	return []cc.Location{start, end, n.Loc}
+}
+
+// key identifies an entity across configurations.
+type key struct {
+	// Node kind.
+	kind string
+	// Source location.
+	loc cc.Location
+}
+
+// sortEntities sorts the given entities by location.
+func sortEntities(es []*Entity) {
+	sort.Slice(es, func(i, j int) bool {
+		a, b := es[i].Loc, es[j].Loc
+		switch {
+		case a.File != b.File:
+			return a.File < b.File
+		case a.Line != b.Line:
+			return a.Line < b.Line
+		case a.Col != b.Col:
+			return a.Col < b.Col
+		}
+		return es[i].Kind < es[j].Kind
+	})
+}
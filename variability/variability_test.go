@@ -0,0 +1,127 @@
+package variability
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	const src = `int common;
+#ifdef FOO
+int foo;
+#endif
+#ifdef NEVER
+int never;
+#endif
+int f(int x) {
+	if (x) {
+		x++;
+#ifdef FOO
+	} else {
+#else
+	} else if (x > 1) {
+#endif
+		x--;
+	}
+#if defined(FOO) && defined(NEVER)
+	/* only a comment */
+#endif
+	return x;
+}
+`
+	dir, err := ioutil.TempDir("", "variability")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	srcPath := filepath.Join(dir, "var.c")
+	if err := ioutil.WriteFile(srcPath, []byte(src), 0644); err != nil {
+		t.Fatalf("unable to write source file; %+v", err)
+	}
+	configs := []*Config{
+		{Name: "default"},
+		{Name: "foo", Args: []string{"-DFOO"}},
+	}
+	r, err := Analyze(srcPath, configs)
+	if err != nil {
+		t.Fatalf("unable to analyze source file; %+v", err)
+	}
+	// Only the #ifdef NEVER branch is dead; the "} else {" branches are
+	// compiled in one configuration each and the comment-only branch contains
+	// no code.
+	if len(r.Dead) != 1 || r.Dead[0].Start != 5 {
+		t.Errorf("dead branches mismatch; expected [#ifdef NEVER at line 5], got %v", r.Dead)
+	}
+	variable := make(map[string][]string)
+	for _, e := range r.Variable {
+		if len(e.Name) > 0 {
+			variable[e.Name] = e.Configs(configs)
+		}
+	}
+	if got := variable["foo"]; len(got) != 1 || got[0] != "foo" {
+		t.Errorf("configurations of %q mismatch; expected [foo], got %v", "foo", got)
+	}
+	if _, ok := variable["common"]; ok {
+		t.Errorf("%q reported as variable", "common")
+	}
+}
+
+func TestScanBranches(t *testing.T) {
+	const src = `#ifdef A
+#undef B
+#pragma once
+#error unsupported
+#line 10
+#endif
+#ifdef C
+#define D 1
+#endif
+#ifdef E
+#include <stddef.h>
+#endif
+#ifdef F
+/* comment
+   spanning lines */
+#endif
+#ifdef G
+const char *s = "/*";
+#endif
+`
+	dir, err := ioutil.TempDir("", "variability")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory; %+v", err)
+	}
+	defer os.RemoveAll(dir)
+	srcPath := filepath.Join(dir, "branch.c")
+	if err := ioutil.WriteFile(srcPath, []byte(src), 0644); err != nil {
+		t.Fatalf("unable to write source file; %+v", err)
+	}
+	branches, err := ScanBranches(srcPath)
+	if err != nil {
+		t.Fatalf("unable to scan branches; %+v", err)
+	}
+	golden := []struct {
+		start, end uint32
+		hasCode    bool
+	}{
+		{start: 1, end: 6, hasCode: false},
+		{start: 7, end: 9, hasCode: true},
+		{start: 10, end: 12, hasCode: true},
+		{start: 13, end: 16, hasCode: false},
+		{start: 17, end: 19, hasCode: true},
+	}
+	if len(branches) != len(golden) {
+		t.Fatalf("number of branches mismatch; expected %d, got %d", len(golden), len(branches))
+	}
+	for i, g := range golden {
+		b := branches[i]
+		if b.Start != g.start || b.End != g.end {
+			t.Errorf("test %d: branch lines mismatch; expected %d-%d, got %d-%d", i, g.start, g.end, b.Start, b.End)
+		}
+		if b.HasCode != g.hasCode {
+			t.Errorf("test %d: code of branch %v mismatch; expected %v, got %v", i, b, g.hasCode, b.HasCode)
+		}
+	}
+}